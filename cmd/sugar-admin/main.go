@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -9,12 +10,17 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/ardanlabs/conf"
 	"github.com/pkg/errors"
 
+	"github.com/igomonov88/sugar/internal/fdc/bulk"
+	"github.com/igomonov88/sugar/internal/glycemic"
 	"github.com/igomonov88/sugar/internal/platform/database"
 	schema2 "github.com/igomonov88/sugar/internal/schema"
+	"github.com/igomonov88/sugar/internal/storage"
 )
 
 func main() {
@@ -37,6 +43,9 @@ func run() error {
 			Name       string `conf:"default:sugar"`
 			DisableTLS bool   `conf:"default:true"`
 		}
+		Import struct {
+			BatchSize int `conf:"default:5000"`
+		}
 		Args conf.Args
 	}
 
@@ -69,6 +78,10 @@ func run() error {
 		err = seed(dbConfig)
 	case "keygen":
 		err = keygen(cfg.Args.Num(1))
+	case "import-fdc":
+		err = importFDC(dbConfig, cfg.Args.Num(1), cfg.Import.BatchSize)
+	case "import-gi":
+		err = importGI(dbConfig, cfg.Args.Num(1), cfg.Args.Num(2))
 	default:
 		err = errors.New("Must specify a command")
 	}
@@ -110,6 +123,82 @@ func seed(cfg database.Config) error {
 	return nil
 }
 
+// importFDC imports FoodData Central CSV dataset unpacked to dir. It can be
+// interrupted and run again, it continues from the last imported batch.
+// Recipes are computed again once the import is complete.
+func importFDC(cfg database.Config, dir string, batchSize int) error {
+	if dir == "" {
+		return errors.New("import-fdc missing argument for dataset directory")
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	importCfg := bulk.Config{
+		Dir:       dir,
+		BatchSize: batchSize,
+		Progress:  os.Stdout,
+	}
+	if err := bulk.Import(context.Background(), db, importCfg); err != nil {
+		return err
+	}
+
+	// Details of the foods recipes are made of may have changed, so every
+	// recipe is computed again.
+	recipes, err := storage.ListRecipes(context.Background(), db, "")
+	if err != nil {
+		return err
+	}
+	ids := make([]int, len(recipes))
+	for i := range recipes {
+		ids[i] = recipes[i].FDCID
+	}
+	if err := storage.ComputeRecipes(context.Background(), db, ids); err != nil {
+		return err
+	}
+
+	fmt.Println("Import complete")
+	return nil
+}
+
+// importGI imports glycemic index table from the CSV file at path. Rows which
+// do not name their source are given source, the name of the file by default.
+func importGI(cfg database.Config, path, source string) error {
+	if path == "" {
+		return errors.New("import-gi missing argument for table file")
+	}
+	if source == "" {
+		source = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "opening table file")
+	}
+	defer f.Close()
+
+	indexes, err := glycemic.Read(f, source)
+	if err != nil {
+		return errors.Wrap(err, "reading table file")
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := storage.SaveGlycemicIndexes(context.Background(), db, indexes); err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d glycemic indexes\n", len(indexes))
+	return nil
+}
+
 func useradd(cfg database.Config, email, password string) error {
 	db, err := database.Open(cfg)
 	if err != nil {
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/sugar/internal/platform/web"
+	"github.com/igomonov88/sugar/internal/provider"
+	"github.com/igomonov88/sugar/internal/storage"
+)
+
+// Barcode returns info about product with given EAN-8, UPC-A, EAN-13 or
+// GTIN-14 code, in the same shape as Details does.
+func (f *Food) Barcode(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Food.Barcode")
+	defer span.End()
+
+	gtin, err := provider.GTIN(strings.TrimSpace(params["gtin"]))
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	opts, err := parseDetailsOptions(r)
+	if err != nil {
+		return err
+	}
+
+	resp, err := f.barcode(ctx, gtin)
+	if err != nil {
+		return upstreamError(w, err, http.StatusNotFound)
+	}
+
+	return web.Respond(ctx, w, opts.apply(resp), http.StatusOK)
+}
+
+// barcode returns info about product with given GTIN-14 code from cache,
+// storage or providers, in this order. Products of Food Data Central are
+// saved to storage.
+func (f *Food) barcode(ctx context.Context, gtin string) (DetailsResponse, error) {
+	key := "gtin:" + gtin
+	if value, exist := f.cache.Get(key); exist {
+		return value.(DetailsResponse), nil
+	}
+
+	food, err := storage.FindByGTIN(ctx, f.db, gtin)
+	switch err {
+	case nil:
+		// The food could be saved by search without its details, details
+		// takes them from external api then.
+		resp, err := f.details(ctx, food.FDCID)
+		if err != nil {
+			return DetailsResponse{}, err
+		}
+		if resp.Barcode == "" {
+			resp.Barcode = food.Barcode
+		}
+		f.cache.Add(key, resp)
+		return resp, nil
+	case sql.ErrNoRows:
+	default:
+		return DetailsResponse{}, web.NewRequestError(err, http.StatusInternalServerError)
+	}
+
+	v, err, _ := f.flight.Do("barcode", gtin, func() (interface{}, error) {
+		ctx, cancel := detach(ctx)
+		defer cancel()
+
+		fd, err := f.lookup(ctx, gtin)
+		if err != nil {
+			return DetailsResponse{}, err
+		}
+
+		resp := f.detailsFromFood(fd)
+		fdcID, err := strconv.Atoi(fd.ID)
+		if fd.Provider != provider.FDC || err != nil {
+			f.cache.Add(key, resp)
+			return resp, nil
+		}
+
+		f.withGlycemic(ctx, map[int]*DetailsResponse{fdcID: &resp})
+		f.cache.Add(key, resp)
+		f.cache.Add(fd.ID, resp)
+		f.saveDetailsAsync(ctx, fdcID, resp)
+
+		return resp, nil
+	})
+	if err != nil {
+		return DetailsResponse{}, err
+	}
+
+	return v.(DetailsResponse), nil
+}
+
+// lookup looks the barcode up in every provider in priority order, and
+// returns the food of the first provider which knows it. When none does, the
+// first error other than provider.ErrNotFound is returned, so failures of
+// providers are not reported as unknown barcode.
+func (f *Food) lookup(ctx context.Context, gtin string) (*provider.Food, error) {
+	err := errors.Wrapf(provider.ErrNotFound, "barcode %s", gtin)
+	for _, p := range f.providers {
+		fd, perr := p.Lookup(ctx, gtin)
+		if perr == nil {
+			return fd, nil
+		}
+		if errors.Cause(perr) != provider.ErrNotFound && errors.Cause(err) == provider.ErrNotFound {
+			err = perr
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/sugar/internal/bolus"
+	"github.com/igomonov88/sugar/internal/insulin"
+	"github.com/igomonov88/sugar/internal/platform/web"
+)
+
+// Bolus calculates the insulin dose for the meal. Carbohydrates of the meal
+// are given in grams, or by the foods of the meal which are looked up like
+// Details does.
+func (f *Food) Bolus(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Food.Bolus")
+	defer span.End()
+
+	var req BolusRequest
+	if err := web.Decode(r, &req); err != nil {
+		return err
+	}
+	if (req.Carbs == nil) == (len(req.Foods) == 0) {
+		return web.NewRequestError(errors.New("either carbs or foods must be given"), http.StatusBadRequest)
+	}
+
+	resp := BolusResponse{Foods: make([]BolusFoodCarbs, len(req.Foods))}
+	if req.Carbs != nil {
+		resp.Carbs = *req.Carbs
+	}
+	for i, food := range req.Foods {
+		details, err := f.details(ctx, food.FDCID)
+		if err != nil {
+			return upstreamError(w, err, http.StatusNotFound)
+		}
+		if details.UnitName == "" {
+			err := errors.Errorf("carbohydrates of fdc id %d are not known", food.FDCID)
+			return web.NewRequestError(err, http.StatusUnprocessableEntity)
+		}
+
+		carbs := details.Carbohydrates.ForWeight(food.Grams)
+		fc := BolusFoodCarbs{
+			FDCID:       food.FDCID,
+			Description: details.Description,
+			Grams:       food.Grams,
+			Carbs:       carbs.Amount,
+		}
+		if req.NetCarbs && carbs.NetCarbs != nil {
+			fc.Carbs = *carbs.NetCarbs
+		}
+		resp.Foods[i] = fc
+		resp.Carbs += fc.Carbs
+	}
+
+	iob := req.InsulinOnBoard
+	if len(req.Doses) > 0 {
+		if iob > 0 {
+			return web.NewRequestError(errors.New("either insulin_on_board or doses must be given"), http.StatusBadRequest)
+		}
+		var settings InsulinSettings
+		if req.Insulin != nil {
+			settings = *req.Insulin
+		}
+		_, curve, err := settings.curve()
+		if err != nil {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		iob = insulin.At(curve, toDoses(req.Doses), time.Now()).OnBoard
+	}
+
+	cfg := f.dosing
+	if req.Increment > 0 {
+		cfg.Increment = req.Increment
+	}
+	dose, err := cfg.Calculate(bolus.Input{
+		Carbs:            resp.Carbs,
+		CarbRatio:        req.CarbRatio,
+		CorrectionFactor: req.CorrectionFactor,
+		Glucose:          req.Glucose,
+		Target:           req.TargetGlucose,
+		InsulinOnBoard:   iob,
+	})
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+	resp.Dose = dose
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
@@ -6,90 +6,557 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
 	"github.com/igomonov88/sugar/internal/carbohydrates"
-	api "github.com/igomonov88/sugar/internal/fdc"
+	"github.com/igomonov88/sugar/internal/fpu"
+	"github.com/igomonov88/sugar/internal/platform/flight"
 	"github.com/igomonov88/sugar/internal/platform/web"
+	"github.com/igomonov88/sugar/internal/provider"
 	"github.com/igomonov88/sugar/internal/storage"
 )
 
-// Details returns info about product with given food detail
+// maxBatchIDs is the number of foods which can be requested in one details
+// batch request.
+const maxBatchIDs = 50
+
+// Details returns info about product with given food detail. The nutrient
+// profile of the product is returned when asked by nutrients query parameter,
+// carbohydrates in the weight of the product asked by grams and in exchange
+// units asked by units.
 func (f *Food) Details(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
 	ctx, span := trace.StartSpan(ctx, "handlers.Food.Details")
 	defer span.End()
 
-	value, exist := f.cache.Get(strings.TrimSpace(params["fdcID"]))
-	if exist {
-		return web.Respond(ctx, w, value, http.StatusOK)
+	fdcID, err := strconv.Atoi(strings.TrimSpace(params["fdcID"]))
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
 	}
 
-	fdcID, err := strconv.Atoi(strings.TrimSpace(params["fdcID"]))
+	opts, err := parseDetailsOptions(r)
 	if err != nil {
+		return err
+	}
+
+	resp, err := f.details(ctx, fdcID)
+	if err != nil {
+		return upstreamError(w, err, http.StatusNotFound)
+	}
+
+	return web.Respond(ctx, w, opts.apply(resp), http.StatusOK)
+}
+
+// DetailsBatch returns info about products with given fdcIDs. Ids are taken
+// from ids query parameter of GET request or from the body of POST request.
+func (f *Food) DetailsBatch(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Food.DetailsBatch")
+	defer span.End()
+
+	var req DetailsBatchRequest
+	if r.Method == http.MethodPost {
+		if err := web.Decode(r, &req); err != nil {
+			return err
+		}
+	} else {
+		for _, v := range r.URL.Query()["ids"] {
+			for _, s := range strings.Split(v, ",") {
+				if s = strings.TrimSpace(s); s == "" {
+					continue
+				}
+				id, err := strconv.Atoi(s)
+				if err != nil || id <= 0 {
+					return web.NewRequestError(errors.New("ids must be positive integers"), http.StatusBadRequest)
+				}
+				req.IDs = append(req.IDs, id)
+			}
+		}
+	}
+
+	opts, err := parseDetailsOptions(r)
+	if err != nil {
+		return err
+	}
+
+	ids := uniqueIDs(req.IDs)
+	if len(ids) == 0 || len(ids) > maxBatchIDs {
+		err := errors.New("ids must hold from 1 to " + strconv.Itoa(maxBatchIDs) + " fdc ids")
 		return web.NewRequestError(err, http.StatusBadRequest)
 	}
 
+	resp, err := f.detailsBatch(ctx, ids, true)
+	if err != nil {
+		return err
+	}
+	for i := range resp.Foods {
+		if d := resp.Foods[i].Details; d != nil {
+			selected := opts.apply(*d)
+			resp.Foods[i].Details = &selected
+		}
+	}
+	if resp.Degraded {
+		w.Header().Set(degradedHeader, "true")
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// details returns info about product with given fdcID from cache, storage or
+// external api, in this order. Products got from external api are saved to
+// storage.
+func (f *Food) details(ctx context.Context, fdcID int) (DetailsResponse, error) {
+	if value, exist := f.cache.Get(strconv.Itoa(fdcID)); exist {
+		return value.(DetailsResponse), nil
+	}
+
 	d, err := storage.RetrieveDetails(ctx, f.db, fdcID)
+	switch err {
+	case nil:
+		resp := f.detailsFromStorage(d)
+		f.withGlycemic(ctx, map[int]*DetailsResponse{fdcID: &resp})
+		f.cache.Add(strconv.Itoa(fdcID), resp)
+		return resp, nil
+	case sql.ErrNoRows:
+	default:
+		return DetailsResponse{}, web.NewRequestError(err, http.StatusInternalServerError)
+	}
+
+	// Concurrent misses of the same food share one call to external api. The
+	// result is cached before the call is over, so lookups coming after it do
+	// not call the api and save the food again.
+	fdc := f.provider(provider.FDC)
+	if fdc == nil {
+		return DetailsResponse{}, web.NewRequestError(errors.New("food data central provider is not configured"), http.StatusNotFound)
+	}
+
+	v, err, _ := f.flight.Do("details", strconv.Itoa(fdcID), func() (interface{}, error) {
+		ctx, cancel := detach(ctx)
+		defer cancel()
+
+		fd, err := fdc.Details(ctx, strconv.Itoa(fdcID))
+		if err != nil {
+			return DetailsResponse{}, err
+		}
+
+		resp := f.detailsFromFood(fd)
+		f.withGlycemic(ctx, map[int]*DetailsResponse{fdcID: &resp})
+		f.cache.Add(strconv.Itoa(fdcID), resp)
+		f.saveDetailsAsync(ctx, fdcID, resp)
+
+		return resp, nil
+	})
 	if err != nil {
-		switch err {
-		case sql.ErrNoRows:
-			d, err := api.Details(ctx, f.apiClient, fdcID)
-			if err != nil {
-				return web.NewRequestError(err, http.StatusNotFound)
+		return DetailsResponse{}, err
+	}
+
+	return v.(DetailsResponse), nil
+}
+
+// detailsBatch returns info about products with given fdcIDs. Products are
+// taken from cache first, then from storage in one query, and the rest is
+// requested from external api in one batch call. Products got from external
+// api are saved to storage in background when save is set, callers which
+// need them in storage before they go on save them on their own.
+func (f *Food) detailsBatch(ctx context.Context, fdcIDs []int, save bool) (DetailsBatchResponse, error) {
+	found := make(map[int]DetailsResponse, len(fdcIDs))
+
+	var missing []int
+	for _, id := range fdcIDs {
+		if value, exist := f.cache.Get(strconv.Itoa(id)); exist {
+			found[id] = value.(DetailsResponse)
+			continue
+		}
+		missing = append(missing, id)
+	}
+
+	if len(missing) != 0 {
+		refs, err := storage.RetrieveDetailsBatch(ctx, f.db, missing)
+		if err != nil {
+			return DetailsBatchResponse{}, web.NewRequestError(err, http.StatusInternalServerError)
+		}
+
+		stored := make(map[int]*DetailsResponse, len(refs))
+		rest := missing[:0]
+		for _, id := range missing {
+			d, ok := refs[id]
+			if !ok {
+				rest = append(rest, id)
+				continue
 			}
+			resp := f.detailsFromStorage(d)
+			stored[id] = &resp
+		}
+		missing = rest
+
+		f.withGlycemic(ctx, stored)
+		for id, resp := range stored {
+			found[id] = *resp
+			f.cache.Add(strconv.Itoa(id), *resp)
+		}
+	}
+
+	// failed holds errors of ids which were not looked up in external api,
+	// they may exist even though they were not found. Misses are coalesced by
+	// id with the ones of details, so a food is requested from external api
+	// once however many lookups miss it at the same time.
+	var upstreamErr error
+	failed := make(map[int]error)
+	if len(missing) != 0 {
+		keys := make([]string, len(missing))
+		for i, id := range missing {
+			keys[i] = strconv.Itoa(id)
+		}
+		results := f.flight.DoBatch("details", keys, func(keys []string) map[string]flight.Result {
+			ctx, cancel := detach(ctx)
+			defer cancel()
+
+			return f.fetchDetails(ctx, keys, save)
+		})
+
+		for _, id := range missing {
+			r := results[strconv.Itoa(id)]
+			switch {
+			case r.Err == nil:
+				found[id] = r.Value.(DetailsResponse)
+			case errors.Cause(r.Err) == provider.ErrNotFound:
+			default:
+				upstreamErr = r.Err
+				failed[id] = r.Err
+			}
+		}
+	}
+
+	batch := DetailsBatchResponse{
+		Foods:    make([]DetailsBatchItem, len(fdcIDs)),
+		Degraded: circuitOpen(upstreamErr),
+		err:      upstreamErr,
+	}
+	for i, id := range fdcIDs {
+		item := DetailsBatchItem{FDCID: id}
+		if resp, ok := found[id]; ok {
+			item.Found = true
+			item.Details = &resp
+		} else if err, ok := failed[id]; ok {
+			item.Error = err.Error()
+			batch.Failed = append(batch.Failed, id)
+		} else {
+			batch.NotFound = append(batch.NotFound, id)
+		}
+		batch.Foods[i] = item
+	}
+
+	return batch, nil
+}
 
-			// Get information about carbohydrates from FDC API response
-			carbs := carbohydrates.Retrieve(d.FoodNutrients)
-			resp := DetailsResponse{
-				Description:   d.Description,
-				Carbohydrates: carbs,
-				Portions:      make([]Portion, len(d.FoodPortions)),
+// fetchDetails requests foods with given fdcIDs from external api, in one call
+// when the provider can do it, and returns their details by id. Details are
+// cached and saved to storage in background when save is set. Foods the api
+// does not know get provider.ErrNotFound, foods which were not looked up get
+// the error they were not looked up because of.
+func (f *Food) fetchDetails(ctx context.Context, ids []string, save bool) map[string]flight.Result {
+	results := make(map[string]flight.Result, len(ids))
+
+	fdc := f.provider(provider.FDC)
+	if fdc == nil {
+		for _, id := range ids {
+			results[id] = flight.Result{Err: errors.Wrap(provider.ErrNotFound, "food data central provider is not configured")}
+		}
+		return results
+	}
+
+	var foods []*provider.Food
+	if bp, ok := fdc.(provider.BatchProvider); ok {
+		list, err := bp.DetailsBatch(ctx, ids)
+		if be, ok := err.(*provider.BatchError); ok {
+			for _, id := range be.IDs {
+				results[id] = flight.Result{Err: be}
 			}
-			for i := range d.FoodPortions {
-				resp.Portions[i].GramWeight = d.FoodPortions[i].GramWeight
-				resp.Portions[i].Description = d.FoodPortions[i].PortionDescription
+		} else if err != nil {
+			for _, id := range ids {
+				results[id] = flight.Result{Err: err}
 			}
+		}
+		foods = list
+	} else {
+		for _, id := range ids {
+			fd, err := fdc.Details(ctx, id)
+			if err != nil {
+				results[id] = flight.Result{Err: err}
+				continue
+			}
+			foods = append(foods, fd)
+		}
+	}
 
-			go saveDetails(ctx, f.db, fdcID, carbs, resp.Portions)
-			go f.cache.Add(strconv.Itoa(fdcID), resp)
+	fetched := make(map[int]*DetailsResponse, len(foods))
+	for _, fd := range foods {
+		id, err := strconv.Atoi(fd.ID)
+		if err != nil {
+			continue
+		}
+		resp := f.detailsFromFood(fd)
+		fetched[id] = &resp
+	}
 
-			return web.Respond(ctx, w, resp, http.StatusOK)
-		default:
-			return web.NewRequestError(err, http.StatusInternalServerError)
+	f.withGlycemic(ctx, fetched)
+	for id, resp := range fetched {
+		f.cache.Add(strconv.Itoa(id), *resp)
+		if save {
+			f.saveDetailsAsync(ctx, id, *resp)
 		}
+		results[strconv.Itoa(id)] = flight.Result{Value: *resp}
 	}
 
-	carbs := carbohydrates.Carbohydrates{
-		Amount:   d.Amount,
-		UnitName: d.UnitName,
+	for _, id := range ids {
+		if _, ok := results[id]; !ok {
+			results[id] = flight.Result{Err: errors.Wrapf(provider.ErrNotFound, "fdc id %s", id)}
+		}
 	}
 
+	return results
+}
+
+// detailsFromFood converts details got from the provider to the response.
+func (f *Food) detailsFromFood(fd *provider.Food) DetailsResponse {
+
+	// Get information about carbohydrates from nutrients of the food
+	carbs := f.carbs.Retrieve(fd.Nutrients)
 	resp := DetailsResponse{
-		Description:   d.Description,
+		Description:   fd.Description,
 		Carbohydrates: carbs,
+		Portions:      make([]Portion, len(fd.Portions)),
+		Provider:      fd.Provider,
+		Barcode:       fd.Barcode,
+		FoodCategory:  fd.FoodCategory,
+		Nutrients:     make([]Nutrient, len(fd.Nutrients)),
+	}
+	for i, n := range fd.Nutrients {
+		resp.Nutrients[i] = Nutrient{
+			Number:   n.Number,
+			Name:     n.Name,
+			Amount:   n.Amount,
+			UnitName: n.UnitName,
+			rank:     n.Rank,
+		}
+	}
+	if units, ok := fpu.Retrieve(fd.Nutrients); ok {
+		resp.FPU = &units
 	}
+	for i := range fd.Portions {
+		resp.Portions[i].GramWeight = fd.Portions[i].GramWeight
+		resp.Portions[i].Description = fd.Portions[i].Description
+	}
+	withPortions(&resp)
+	if fd.Serving != nil {
+		resp.Serving = &Serving{
+			Size:        fd.Serving.Size,
+			Unit:        fd.Serving.Unit,
+			Description: fd.Serving.Description,
+		}
+		if carbs := f.carbs.Retrieve(fd.Serving.Nutrients); carbs.UnitName != "" {
+			resp.Serving.Carbohydrates = &carbs
+		}
+	}
+	return resp
+}
 
-	return web.Respond(ctx, w, resp, http.StatusOK)
+// detailsFromStorage converts details got from storage to the response.
+// Carbohydrates are computed from the nutrient profile when it is stored,
+// foods saved without it have total carbohydrates only. Total carbohydrates
+// of recipes are always the stored ones, as ingredients state them by
+// different nutrients and the profile of the recipe sums each of them over
+// the ingredients which have it.
+func (f *Food) detailsFromStorage(d *storage.DetailsRef) DetailsResponse {
+	resp := DetailsResponse{
+		Description: d.Description,
+		Carbohydrates: carbohydrates.Carbohydrates{
+			Amount:   d.Amount,
+			UnitName: d.UnitName,
+		},
+		Portions:  make([]Portion, len(d.Portions)),
+		Provider:  d.Provider,
+		Nutrients: make([]Nutrient, len(d.Nutrients)),
+	}
+	for i, n := range d.Nutrients {
+		resp.Nutrients[i] = Nutrient{
+			Name:     n.Name,
+			Amount:   n.Amount,
+			UnitName: n.UnitName,
+			rank:     n.Rank,
+		}
+		if n.Number != 0 {
+			resp.Nutrients[i].Number = strconv.Itoa(n.Number)
+		}
+	}
+	stored := storedNutrients(d.Nutrients)
+	if d.Provider == storage.RecipeProvider {
+		resp.Carbohydrates = f.carbs.RetrieveWithTotal(stored, d.Amount, d.UnitName, carbohydrates.SourceIngredients)
+	} else if carbs := f.carbs.Retrieve(stored); carbs.Source != "" {
+		resp.Carbohydrates = carbs
+	}
+	if units, ok := fpu.Retrieve(stored); ok {
+		resp.FPU = &units
+	}
+	for i := range d.Portions {
+		resp.Portions[i].ID = d.Portions[i].ID
+		resp.Portions[i].GramWeight = d.Portions[i].GramWeight
+		resp.Portions[i].Description = d.Portions[i].Description
+	}
+	withPortions(&resp)
+	if s := d.Serving; s != nil {
+		resp.Serving = &Serving{
+			Size:        s.Size,
+			Unit:        s.Unit,
+			Description: s.Description,
+		}
+		if s.Carbohydrates.Valid {
+			resp.Serving.Carbohydrates = &carbohydrates.Carbohydrates{
+				Amount:   s.Carbohydrates.Float64,
+				UnitName: s.CarbohydratesUnit,
+			}
+		}
+	}
+	return resp
 }
 
-func saveDetails(ctx context.Context, db *sqlx.DB, fdcID int, carbs carbohydrates.Carbohydrates,
-	portions []Portion) error {
+// storedNutrients converts nutrients got from storage to nutrients of the
+// provider.
+func storedNutrients(fns []storage.FoodNutrient) []provider.Nutrient {
+	list := make([]provider.Nutrient, len(fns))
+	for i, n := range fns {
+		list[i] = provider.Nutrient{
+			Name:     n.Name,
+			Amount:   n.Amount,
+			UnitName: n.UnitName,
+			Rank:     n.Rank,
+		}
+		if n.Number != 0 {
+			list[i].Number = strconv.Itoa(n.Number)
+		}
+	}
+	return list
+}
+
+// uniqueIDs returns ids without duplicates, keeping their order.
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
+
+// lookupTimeout bounds the work which does not end with the request that
+// started it, like lookups shared by coalesced requests and saves to storage.
+const lookupTimeout = 30 * time.Second
+
+// detach returns the context for the work shared by coalesced requests or left
+// running after the request is over. It is not cancelled with the request
+// ctx, so a client going away does not fail the other clients waiting for
+// the same lookup, but it keeps the trace span of the request.
+func detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	d := trace.NewContext(context.Background(), trace.FromContext(ctx))
+	return context.WithTimeout(d, lookupTimeout)
+}
+
+// saveDetailsAsync saves details of the food to storage in background, on the
+// context detached from the request.
+func (f *Food) saveDetailsAsync(ctx context.Context, fdcID int, resp DetailsResponse) {
+	ctx, cancel := detach(ctx)
+	go func() {
+		defer cancel()
+		f.saveDetails(ctx, fdcID, resp)
+	}()
+}
+
+// saveDetails saves details of the food to storage. Recipes the food is an
+// ingredient of are computed from details in storage, so they are computed
+// again and dropped from cache.
+func (f *Food) saveDetails(ctx context.Context, fdcID int, resp DetailsResponse) error {
 	ctx, span := trace.StartSpan(ctx, "handlers.Food.Details.Storage.SaveDetails")
 	defer span.End()
 
-	dbPortions := make([]storage.Portion, len(portions))
+	dbPortions := make([]storage.Portion, len(resp.Portions))
 	dbCarbs := storage.Carbohydrates{
 		FDCID:    fdcID,
-		Amount:   carbs.Amount,
-		UnitName: carbs.UnitName,
+		Amount:   resp.Amount,
+		UnitName: resp.UnitName,
 	}
-	for i := range portions {
+	for i := range resp.Portions {
 		dbPortions[i].FDCID = fdcID
-		dbPortions[i].GramWeight = portions[i].GramWeight
-		dbPortions[i].Description = portions[i].Description
+		dbPortions[i].GramWeight = resp.Portions[i].GramWeight
+		dbPortions[i].Description = resp.Portions[i].Description
+	}
+
+	// Details can be requested without searching for the food before, so make
+	// sure the food they refer to is stored.
+	food := storage.Food{
+		FDCID:        fdcID,
+		Description:  resp.Description,
+		Barcode:      resp.Barcode,
+		FoodCategory: resp.FoodCategory,
+	}
+	if err := storage.SaveFood(ctx, f.db, food); err != nil {
+		return err
 	}
 
-	return storage.SaveDetails(ctx, db, fdcID, dbCarbs, dbPortions)
+	if s := resp.Serving; s != nil {
+		serving := storage.Serving{
+			FDCID:       fdcID,
+			Size:        s.Size,
+			Unit:        s.Unit,
+			Description: s.Description,
+		}
+		if s.Carbohydrates != nil {
+			serving.Carbohydrates = sql.NullFloat64{Float64: s.Carbohydrates.Amount, Valid: true}
+			serving.CarbohydratesUnit = s.Carbohydrates.UnitName
+		}
+		if err := storage.SaveServing(ctx, f.db, serving); err != nil {
+			return err
+		}
+	}
+
+	dbNutrients := make([]storage.FoodNutrient, 0, len(resp.Nutrients))
+	for _, n := range resp.Nutrients {
+		fn := storage.FoodNutrient{
+			FDCID:  fdcID,
+			Amount: n.Amount,
+			Nutrient: storage.Nutrient{
+				Name:     n.Name,
+				Rank:     n.rank,
+				UnitName: n.UnitName,
+			},
+		}
+
+		// Nutrients with numbers other than integer, e.g. 269.3, are
+		// saved without the number.
+		fn.Number, _ = strconv.Atoi(n.Number)
+		dbNutrients = append(dbNutrients, fn)
+	}
+	if err := storage.SaveNutrients(ctx, f.db, fdcID, dbNutrients); err != nil {
+		return err
+	}
+
+	if err := storage.SaveDetails(ctx, f.db, fdcID, dbCarbs, dbPortions); err != nil {
+		return err
+	}
+
+	recipes, err := storage.IngredientRecipes(ctx, f.db, fdcID)
+	if err != nil {
+		return err
+	}
+	if err := storage.ComputeRecipes(ctx, f.db, recipes); err != nil {
+		return err
+	}
+	for _, id := range recipes {
+		f.cache.Delete(strconv.Itoa(id))
+	}
+	return nil
 }
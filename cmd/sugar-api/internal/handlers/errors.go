@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	api "github.com/igomonov88/sugar/internal/fdc"
+	"github.com/igomonov88/sugar/internal/platform/web"
+)
+
+// degradedHeader marks responses answered from storage only, because the
+// circuit breaker in front of food data central api is open.
+const degradedHeader = "X-Degraded"
+
+// upstreamError converts the error of the call to food data central api to the
+// request error with given status. Exhausted quota of the api is reported with
+// 503 and Retry-After header, so clients know when to come back. Errors which
+// are request errors already are returned as is. Open circuit of the api keeps
+// given status, but the response is marked as degraded.
+func upstreamError(w http.ResponseWriter, err error, status int) error {
+	if _, ok := err.(*web.Error); ok {
+		return err
+	}
+	if qe, ok := errors.Cause(err).(*api.ErrQuotaExhausted); ok {
+		seconds := int(math.Ceil(qe.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		return web.NewRequestError(err, http.StatusServiceUnavailable)
+	}
+	if circuitOpen(err) {
+		w.Header().Set(degradedHeader, "true")
+	}
+	return web.NewRequestError(err, status)
+}
+
+// circuitOpen reports whether the call to food data central api was not made
+// because the circuit breaker is open.
+func circuitOpen(err error) bool {
+	return errors.Cause(err) == api.ErrCircuitOpen
+}
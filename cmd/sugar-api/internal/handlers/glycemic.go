@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/sugar/internal/storage"
+)
+
+// withGlycemic sets glycemic index of the foods to their responses, by fdcID,
+// with glycemic load of the food, its portions and serving. Glycemic index is
+// supplementary, foods are responded without it when it cannot be retrieved.
+func (f *Food) withGlycemic(ctx context.Context, resps map[int]*DetailsResponse) {
+	ctx, span := trace.StartSpan(ctx, "handlers.Food.Glycemic")
+	defer span.End()
+
+	categories := make(map[int]string, len(resps))
+	for id, resp := range resps {
+		categories[id] = resp.FoodCategory
+	}
+
+	indexes, err := storage.RetrieveGlycemicIndexes(ctx, f.db, categories)
+	if err != nil {
+		return
+	}
+
+	for id, gi := range indexes {
+		resp, ok := resps[id]
+		if !ok || resp.UnitName == "" {
+			continue
+		}
+		resp.Carbohydrates = resp.Carbohydrates.WithGlycemic(gi.GI, gi.Source, gi.Match)
+		withPortions(resp)
+
+		if resp.Serving != nil && resp.Serving.Carbohydrates != nil {
+			carbs := resp.Serving.Carbohydrates.WithGlycemic(gi.GI, gi.Source, gi.Match)
+			resp.Serving.Carbohydrates = &carbs
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/sugar/internal/insulin"
+	"github.com/igomonov88/sugar/internal/platform/web"
+)
+
+// IOB returns insulin on board and insulin activity of the doses at the time.
+// Doses are given by doses query parameter as units@time, e.g.
+// 4.5@2020-01-01T12:00:00Z, separated by commas or repeated.
+func (f *Food) IOB(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Food.IOB")
+	defer span.End()
+
+	q := r.URL.Query()
+	settings := InsulinSettings{
+		Name:  q.Get("insulin"),
+		Model: q.Get("model"),
+	}
+
+	var fields []web.FieldError
+	for _, p := range []struct {
+		name  string
+		value *float64
+	}{
+		{"peak", &settings.Peak},
+		{"duration", &settings.Duration},
+	} {
+		v := strings.TrimSpace(q.Get(p.name))
+		if v == "" {
+			continue
+		}
+		m, err := strconv.ParseFloat(v, 64)
+		if err != nil || m <= 0 {
+			fields = append(fields, web.FieldError{Field: p.name, Error: p.name + " must be a positive number of minutes"})
+			continue
+		}
+		*p.value = m
+	}
+
+	at := time.Now().UTC()
+	if v := strings.TrimSpace(q.Get("at")); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			fields = append(fields, web.FieldError{Field: "at", Error: "at must be RFC3339 time"})
+		} else {
+			at = t
+		}
+	}
+
+	var doses []InsulinDose
+	for _, v := range q["doses"] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			d, err := parseDose(s)
+			if err != nil {
+				fields = append(fields, web.FieldError{Field: "doses", Error: err.Error()})
+				continue
+			}
+			doses = append(doses, d)
+		}
+	}
+	if len(doses) > maxDoses {
+		fields = append(fields, web.FieldError{Field: "doses", Error: "too many doses"})
+	}
+
+	if len(fields) > 0 {
+		return &web.Error{
+			Err:    errors.New("field validator error"),
+			Status: http.StatusBadRequest,
+			Fields: fields,
+		}
+	}
+
+	in, curve, err := settings.curve()
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	st := insulin.At(curve, toDoses(doses), at)
+	resp := IOBResponse{
+		At:             at,
+		Insulin:        in.Name,
+		Model:          settings.model(),
+		Peak:           in.Peak.Minutes(),
+		Duration:       in.Duration.Minutes(),
+		InsulinOnBoard: st.OnBoard,
+		Activity:       st.Activity,
+		Doses:          make([]IOBDose, len(doses)),
+	}
+	for i, d := range doses {
+		resp.Doses[i] = IOBDose{InsulinDose: d, Remaining: st.Remaining[i]}
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// maxDoses is the most doses insulin on board is computed for in one request.
+const maxDoses = 100
+
+// parseDose parses the dose given as units@time.
+func parseDose(s string) (InsulinDose, error) {
+	parts := strings.SplitN(s, "@", 2)
+	if len(parts) != 2 {
+		return InsulinDose{}, errors.New("dose must be given as units@time")
+	}
+
+	units, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || units <= 0 {
+		return InsulinDose{}, errors.New("units of the dose must be a positive number")
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(parts[1]))
+	if err != nil {
+		return InsulinDose{}, errors.New("time of the dose must be RFC3339 time")
+	}
+	return InsulinDose{Units: units, Time: t}, nil
+}
+
+// curve returns the insulin of the settings and its activity curve. The
+// preset of the insulin is used for peak and duration which are not given.
+func (s InsulinSettings) curve() (insulin.Insulin, insulin.Curve, error) {
+	name := s.Name
+	if strings.TrimSpace(name) == "" {
+		name = insulin.DefaultInsulin
+	}
+	in, err := insulin.Preset(name)
+	if err != nil {
+		return in, nil, err
+	}
+	if s.Peak > 0 {
+		in.Peak = time.Duration(s.Peak * float64(time.Minute))
+	}
+	if s.Duration > 0 {
+		in.Duration = time.Duration(s.Duration * float64(time.Minute))
+	}
+
+	c, err := insulin.NewCurve(s.model(), in)
+	return in, c, err
+}
+
+// model returns the model of the settings, exponential when none is given.
+func (s InsulinSettings) model() string {
+	if strings.TrimSpace(s.Model) == "" {
+		return insulin.ModelExponential
+	}
+	return strings.ToLower(s.Model)
+}
+
+// toDoses converts doses of the request to doses of the model.
+func toDoses(doses []InsulinDose) []insulin.Dose {
+	out := make([]insulin.Dose, len(doses))
+	for i, d := range doses {
+		out[i] = insulin.Dose{Units: d.Units, Time: d.Time}
+	}
+	return out
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/sugar/internal/carbohydrates"
+	"github.com/igomonov88/sugar/internal/fpu"
+	"github.com/igomonov88/sugar/internal/nutrients"
+	"github.com/igomonov88/sugar/internal/platform/web"
+	"github.com/igomonov88/sugar/internal/provider"
+	"github.com/igomonov88/sugar/internal/storage"
+)
+
+// errPortionNotFound is the error of the item which portion is not known.
+var errPortionNotFound = errors.New("portion of the food is not found")
+
+// CalculateMeal returns carbohydrates, fat, protein and energy of every item
+// of the meal and of the meal in total. Foods of the items are looked up like
+// DetailsBatch does, items which could not be resolved are flagged and not
+// counted in the total.
+func (f *Food) CalculateMeal(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Food.CalculateMeal")
+	defer span.End()
+
+	var req MealRequest
+	if err := web.Decode(r, &req); err != nil {
+		return err
+	}
+
+	var fields []web.FieldError
+	ids := make([]int, len(req.Items))
+	for i, item := range req.Items {
+		given := 0
+		for _, ok := range []bool{item.Grams > 0, item.PortionID > 0, strings.TrimSpace(item.Portion) != ""} {
+			if ok {
+				given++
+			}
+		}
+		if given != 1 {
+			fields = append(fields, web.FieldError{
+				Field: fmt.Sprintf("items[%d]", i),
+				Error: "either grams, portion_id or portion must be given",
+			})
+		}
+		ids[i] = item.FDCID
+	}
+	if len(fields) > 0 {
+		return &web.Error{
+			Err:    errors.New("field validator error"),
+			Status: http.StatusBadRequest,
+			Fields: fields,
+		}
+	}
+
+	batch, err := f.detailsBatch(ctx, uniqueIDs(ids), true)
+	if err != nil {
+		return err
+	}
+	found := make(map[int]DetailsBatchItem, len(batch.Foods))
+	for _, item := range batch.Foods {
+		found[item.FDCID] = item
+	}
+
+	resp := MealResponse{
+		Items:    make([]MealItemResult, len(req.Items)),
+		Degraded: batch.Degraded,
+	}
+	var units []fpu.FPU
+	var resolved []MealNutrition
+	for i, item := range req.Items {
+		res := MealItemResult{FDCID: item.FDCID, Grams: item.Grams}
+
+		food := found[item.FDCID]
+		if !food.Found {
+			res.Error = "food is not found"
+			if food.Error != "" {
+				res.Error = food.Error
+			}
+			resp.Items[i] = res
+			resp.Unresolved = append(resp.Unresolved, i)
+			continue
+		}
+		res.Description = food.Details.Description
+
+		if item.Grams == 0 {
+			p, err := f.portion(ctx, food.Details, item)
+			switch {
+			case err == errPortionNotFound:
+				res.Error = err.Error()
+			case err != nil:
+				return web.NewRequestError(err, http.StatusInternalServerError)
+			default:
+				res.Portion = p.Description
+				res.Grams = round(p.GramWeight * quantity(item))
+			}
+		}
+		if res.Error == "" && food.Details.UnitName == "" {
+			res.Error = "carbohydrates of the food are not known"
+		}
+		if res.Error != "" {
+			resp.Items[i] = res
+			resp.Unresolved = append(resp.Unresolved, i)
+			continue
+		}
+
+		var u *fpu.FPU
+		res.MealNutrition, u = nutrition(*food.Details, res.Grams)
+		if u != nil {
+			units = append(units, *u)
+		}
+		res.Resolved = true
+		resp.Items[i] = res
+		resolved = append(resolved, res.MealNutrition)
+	}
+	resp.Total, resp.Partial = total(resolved)
+	if len(units) > 0 {
+		sum := fpu.Sum(units...)
+		resp.FPU = &sum
+	}
+	if resp.Degraded {
+		w.Header().Set(degradedHeader, "true")
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// portion returns the portion of the food the item asks for. Portions are
+// looked up by id in storage when the food was not read from it, as only
+// stored portions have ids.
+func (f *Food) portion(ctx context.Context, d *DetailsResponse, item MealItem) (Portion, error) {
+	for _, p := range d.Portions {
+		if item.PortionID > 0 && p.ID == item.PortionID ||
+			item.PortionID == 0 && strings.EqualFold(p.Description, strings.TrimSpace(item.Portion)) {
+			if p.GramWeight <= 0 {
+				return Portion{}, errPortionNotFound
+			}
+			return p, nil
+		}
+	}
+	if item.PortionID == 0 {
+		return Portion{}, errPortionNotFound
+	}
+
+	sp, err := storage.RetrievePortion(ctx, f.db, item.PortionID)
+	switch {
+	case err == sql.ErrNoRows:
+		return Portion{}, errPortionNotFound
+	case err != nil:
+		return Portion{}, err
+	case sp.FDCID != item.FDCID || sp.GramWeight <= 0:
+		return Portion{}, errPortionNotFound
+	}
+	return Portion{ID: sp.ID, GramWeight: sp.GramWeight, Description: sp.Description}, nil
+}
+
+// quantity returns the number of portions of the item.
+func quantity(item MealItem) float64 {
+	if item.Quantity > 0 {
+		return item.Quantity
+	}
+	return 1
+}
+
+// nutrition returns nutrition of given grams of the food, along with its
+// fat-protein units when they are known. Every value is rounded to 2 decimal
+// places.
+func nutrition(d DetailsResponse, grams float64) (MealNutrition, *fpu.FPU) {
+	carbs := d.Carbohydrates.ForWeight(grams)
+	n := MealNutrition{
+		Carbs:    carbs.Amount,
+		NetCarbs: carbs.NetCarbs,
+	}
+
+	var units *fpu.FPU
+	if d.FPU != nil {
+		u := d.FPU.ForWeight(grams)
+		n.Fat, n.Protein = &u.Fat, &u.Protein
+		units = &u
+	}
+
+	list := make([]provider.Nutrient, len(d.Nutrients))
+	for i, dn := range d.Nutrients {
+		list[i] = provider.Nutrient{Number: dn.Number, Name: dn.Name, Amount: dn.Amount, UnitName: dn.UnitName}
+	}
+	if e, ok := nutrients.Find(list, nutrients.MustLookup(nutrients.Energy)); ok && strings.EqualFold(e.UnitName, "kcal") {
+		energy := round(e.Amount * grams / carbohydrates.Basis)
+		n.Energy = &energy
+	}
+	return n, units
+}
+
+// total returns the sum of the nutrition of the items. Values are summed as
+// they are rounded for the items, so the total is the sum of the items as
+// shown. Values which some of the items do not state are nil in the total,
+// except net carbs, which are counted as carbs for such items, partial is set
+// then.
+func total(items []MealNutrition) (MealNutrition, bool) {
+	var n MealNutrition
+	var partial bool
+	netCarbs := make([]*float64, len(items))
+	fat := make([]*float64, len(items))
+	protein := make([]*float64, len(items))
+	energy := make([]*float64, len(items))
+	for i, m := range items {
+		n.Carbs = round(n.Carbs + m.Carbs)
+		netCarbs[i], fat[i], protein[i], energy[i] = m.NetCarbs, m.Fat, m.Protein, m.Energy
+		if m.NetCarbs == nil {
+			carbs := m.Carbs
+			netCarbs[i] = &carbs
+		}
+		if m.NetCarbs == nil || m.Fat == nil || m.Protein == nil || m.Energy == nil {
+			partial = true
+		}
+	}
+	n.NetCarbs = sumOptional(netCarbs)
+	n.Fat = sumOptional(fat)
+	n.Protein = sumOptional(protein)
+	n.Energy = sumOptional(energy)
+	return n, partial
+}
+
+// sumOptional sums values which may not be known, the sum is nil when any of
+// them is not known or there are none.
+func sumOptional(values []*float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	var sum float64
+	for _, v := range values {
+		if v == nil {
+			return nil
+		}
+		sum += *v
+	}
+	sum = round(sum)
+	return &sum
+}
+
+// round rounds the amount to 2 decimal places.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
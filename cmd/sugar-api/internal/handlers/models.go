@@ -1,26 +1,401 @@
 package handlers
 
-import "github.com/igomonov88/sugar/internal/carbohydrates"
+import (
+	"time"
+
+	"github.com/igomonov88/sugar/internal/bolus"
+	"github.com/igomonov88/sugar/internal/carbohydrates"
+	"github.com/igomonov88/sugar/internal/fpu"
+)
 
 // DetailsResponse represents response on http GET details request
 type DetailsResponse struct {
 	Description                 string `json:"description"`
 	carbohydrates.Carbohydrates `json:"carbohydrates"`
 	Portions                    []Portion `json:"portions"`
+
+	// BasisGrams is the weight in grams carbohydrates and nutrients of the
+	// food are given for.
+	BasisGrams float64 `json:"basis_grams"`
+
+	// Weight holds carbohydrates in the weight of the food asked by grams
+	// query parameter.
+	Weight *Weight `json:"weight,omitempty"`
+
+	// FPU is fat-protein units of the food, nil when it states neither fat
+	// nor protein.
+	FPU *fpu.FPU `json:"fpu,omitempty"`
+
+	// Provider is the name of the provider which supplied the food.
+	Provider string `json:"provider"`
+
+	// Barcode is GTIN, UPC or EAN code of the food when known.
+	Barcode string `json:"barcode,omitempty"`
+
+	// FoodCategory is the category of the food e.g. Fruits and Fruit Juices,
+	// when known.
+	FoodCategory string `json:"food_category,omitempty"`
+
+	// Serving is the serving stated on the label of branded product, with
+	// carbohydrates as the package states them.
+	Serving *Serving `json:"serving,omitempty"`
+
+	// Nutrients is the nutrient profile of the food per 100 grams, returned
+	// only when requested with nutrients query parameter.
+	Nutrients []Nutrient `json:"nutrients,omitempty"`
+}
+
+// Nutrient represents the amount of nutrient in 100 grams of the food
+type Nutrient struct {
+	// Number is the nutrient number used by USDA e.g. 205 for carbohydrates
+	Number   string  `json:"number,omitempty"`
+	Name     string  `json:"name"`
+	Amount   float64 `json:"amount"`
+	UnitName string  `json:"unit_name"`
+
+	// rank is kept to save the nutrient to storage, it is not a part of the
+	// response.
+	rank int
+}
+
+// Serving represents the labelled serving of branded product
+type Serving struct {
+	// Size is the amount of the serving in Unit, which is g or ml
+	Size float64 `json:"size"`
+	Unit string  `json:"unit"`
+
+	// Description is the serving in household measure e.g. 1 bar
+	Description string `json:"description,omitempty"`
+
+	// Carbohydrates is the amount of carbohydrates per serving, nil when the
+	// label does not state it
+	Carbohydrates *carbohydrates.Carbohydrates `json:"carbohydrates,omitempty"`
+}
+
+// DetailsBatchRequest represents the body of http POST details batch request
+type DetailsBatchRequest struct {
+	// IDs is the list of fdc ids of requested foods.
+	IDs []int `json:"ids" validate:"required,min=1,dive,gt=0"`
+}
+
+// DetailsBatchResponse represents response on details batch request
+type DetailsBatchResponse struct {
+	// Foods holds the result for every requested fdc id, in request order.
+	Foods []DetailsBatchItem `json:"foods"`
+
+	// NotFound lists fdc ids of the foods which details were not found.
+	NotFound []int `json:"not_found,omitempty"`
+
+	// Failed lists fdc ids of the foods which could not be looked up in
+	// external api because the call failed, Error of their items tells why.
+	Failed []int `json:"failed,omitempty"`
+
+	// Degraded is set when external api was not called because it is down,
+	// so foods not in storage could not be looked up.
+	Degraded bool `json:"degraded,omitempty"`
+
+	// err is the error of the call to external api the Failed foods were not
+	// looked up because of.
+	err error
+}
+
+// DetailsBatchItem represents the result of details lookup of one food
+type DetailsBatchItem struct {
+	FDCID   int              `json:"fdc_id"`
+	Found   bool             `json:"found"`
+	Details *DetailsResponse `json:"details,omitempty"`
+
+	// Error explains why the food was not looked up in external api.
+	Error string `json:"error,omitempty"`
 }
 
 type Portion struct {
+	// ID is the id of the portion in storage, zero for portions which are
+	// not stored yet.
+	ID int `json:"id,omitempty"`
+
 	// GramWeight represents total gram amount in portion
 	GramWeight float64 `json:"gram_weight"`
 
 	// PortionDescription represents information about portion 1bar/1snack etc.
 	Description string `json:"description"`
+
+	// Carbohydrates is the amount of carbohydrates in the portion, nil when
+	// carbohydrates or gram weight of the food are not known
+	Carbohydrates *carbohydrates.Carbohydrates `json:"carbohydrates,omitempty"`
+
+	// FPU is fat-protein units of the portion, nil when they are not known
+	FPU *fpu.FPU `json:"fpu,omitempty"`
+}
+
+// Weight represents carbohydrates in the arbitrary weight of the food
+type Weight struct {
+	Grams         float64                     `json:"grams"`
+	Carbohydrates carbohydrates.Carbohydrates `json:"carbohydrates"`
+	FPU           *fpu.FPU                    `json:"fpu,omitempty"`
+}
+
+// BolusRequest represents the body of http POST bolus request. Glucose values
+// are in the same unit, mg/dL or mmol/L, as CorrectionFactor is.
+type BolusRequest struct {
+	// Carbs is grams of carbohydrates of the meal, or Foods are the foods of
+	// the meal which carbohydrates are looked up.
+	Carbs *float64    `json:"carbs" validate:"omitempty,gte=0"`
+	Foods []BolusFood `json:"foods" validate:"omitempty,max=50,dive"`
+
+	// NetCarbs doses for net carbs of the foods instead of total
+	// carbohydrates.
+	NetCarbs bool `json:"net_carbs"`
+
+	// CarbRatio is grams of carbohydrates one unit of insulin covers.
+	CarbRatio float64 `json:"carb_ratio" validate:"required,gt=0"`
+
+	// CorrectionFactor is the drop of glucose one unit of insulin gives, it
+	// is required along with target when glucose is given.
+	CorrectionFactor float64 `json:"correction_factor" validate:"gte=0"`
+	Glucose          float64 `json:"glucose" validate:"gte=0"`
+	TargetGlucose    float64 `json:"target_glucose" validate:"gte=0"`
+
+	// InsulinOnBoard is units of insulin still active from doses before.
+	// Doses given before may be sent instead, and insulin on board is
+	// computed from them with the activity curve of Insulin.
+	InsulinOnBoard float64          `json:"insulin_on_board" validate:"gte=0"`
+	Doses          []InsulinDose    `json:"doses" validate:"omitempty,max=100,dive"`
+	Insulin        *InsulinSettings `json:"insulin"`
+
+	// Increment is the dose step of the pen or pump in units, the one the
+	// service is configured with is used when it is not given.
+	Increment float64 `json:"increment" validate:"gte=0"`
+}
+
+// InsulinSettings represents the insulin and its activity curve. The preset
+// of the insulin is used for peak and duration which are not given.
+type InsulinSettings struct {
+	// Name is the insulin e.g. fiasp, novorapid is used when it is not given.
+	Name string `json:"name"`
+
+	// Model is the activity curve, exponential or bilinear.
+	Model string `json:"model"`
+
+	// Peak and Duration of insulin activity in minutes.
+	Peak     float64 `json:"peak" validate:"gte=0"`
+	Duration float64 `json:"duration" validate:"gte=0"`
+}
+
+// InsulinDose represents units of insulin given at the time.
+type InsulinDose struct {
+	Units float64   `json:"units" validate:"gt=0"`
+	Time  time.Time `json:"time" validate:"required"`
+}
+
+// IOBResponse represents response on insulin on board request
+type IOBResponse struct {
+	At       time.Time `json:"at"`
+	Insulin  string    `json:"insulin"`
+	Model    string    `json:"model"`
+	Peak     float64   `json:"peak"`
+	Duration float64   `json:"duration"`
+
+	// InsulinOnBoard is units of insulin still to act, Activity is units
+	// acting per hour.
+	InsulinOnBoard float64 `json:"insulin_on_board"`
+	Activity       float64 `json:"activity"`
+
+	Doses []IOBDose `json:"doses"`
+}
+
+// IOBDose represents the dose with units of it still to act.
+type IOBDose struct {
+	InsulinDose
+	Remaining float64 `json:"remaining"`
+}
+
+// BolusFood represents the food of the meal, fdc id and grams of it.
+type BolusFood struct {
+	FDCID int     `json:"fdc_id" validate:"required,gt=0"`
+	Grams float64 `json:"grams" validate:"required,gt=0"`
+}
+
+// BolusResponse represents response on bolus request
+type BolusResponse struct {
+	// Carbs is grams of carbohydrates the dose is calculated for.
+	Carbs float64          `json:"carbs"`
+	Foods []BolusFoodCarbs `json:"foods,omitempty"`
+
+	bolus.Dose
+}
+
+// BolusFoodCarbs represents carbohydrates of the food of the meal
+type BolusFoodCarbs struct {
+	FDCID       int     `json:"fdc_id"`
+	Description string  `json:"description"`
+	Grams       float64 `json:"grams"`
+	Carbs       float64 `json:"carbs"`
+}
+
+// MealRequest represents the body of http POST meal calculate request
+type MealRequest struct {
+	Items []MealItem `json:"items" validate:"required,min=1,max=50,dive"`
+}
+
+// MealItem represents the food of the meal. The amount of the food is given
+// in grams, or by the portion of the food, either by its id or description.
+type MealItem struct {
+	FDCID int     `json:"fdc_id" validate:"required,gt=0"`
+	Grams float64 `json:"grams" validate:"gte=0"`
+
+	PortionID int    `json:"portion_id" validate:"gte=0"`
+	Portion   string `json:"portion"`
+
+	// Quantity is the number of portions, one when it is not given.
+	Quantity float64 `json:"quantity" validate:"gte=0"`
+}
+
+// MealResponse represents response on meal calculate request
+type MealResponse struct {
+	Items []MealItemResult `json:"items"`
+
+	// Total is the sum of the resolved items, each rounded the way the
+	// items are.
+	Total MealNutrition `json:"total"`
+
+	// Partial is set when some of the resolved items do not state net carbs,
+	// fat, protein or energy. Net carbs of such items are counted as their
+	// carbs, the other values are left out of the total.
+	Partial bool `json:"partial,omitempty"`
+
+	// FPU is fat-protein units of the meal as a whole.
+	FPU *fpu.FPU `json:"fpu,omitempty"`
+
+	// Unresolved lists indexes of the items which could not be resolved and
+	// are not counted in the total.
+	Unresolved []int `json:"unresolved,omitempty"`
+
+	// Degraded is set when external api was not called because it is down,
+	// so foods not in storage could not be looked up.
+	Degraded bool `json:"degraded,omitempty"`
+
+	// err is the error of the call to external api the Failed foods were not
+	// looked up because of.
+	err error
+}
+
+// MealItemResult represents the nutrition of the food of the meal
+type MealItemResult struct {
+	FDCID       int     `json:"fdc_id"`
+	Description string  `json:"description,omitempty"`
+	Grams       float64 `json:"grams"`
+	Portion     string  `json:"portion,omitempty"`
+
+	// Resolved is false when the food or its portion could not be found,
+	// Error tells why.
+	Resolved bool   `json:"resolved"`
+	Error    string `json:"error,omitempty"`
+
+	MealNutrition
+}
+
+// MealNutrition represents carbohydrates, fat, protein in grams and energy in
+// kcal. Values the foods do not state are nil.
+type MealNutrition struct {
+	Carbs    float64  `json:"carbs"`
+	NetCarbs *float64 `json:"net_carbs,omitempty"`
+	Fat      *float64 `json:"fat,omitempty"`
+	Protein  *float64 `json:"protein,omitempty"`
+	Energy   *float64 `json:"energy,omitempty"`
+}
+
+// RecipeRequest represents the body of http POST and PUT recipe requests
+type RecipeRequest struct {
+	Name        string             `json:"name" validate:"required"`
+	Ingredients []RecipeIngredient `json:"ingredients" validate:"required,min=1,max=100,dive"`
+
+	// YieldGrams is the weight of the cooked dish, the sum of ingredients
+	// is used when it is not given. Servings is one when it is not given.
+	YieldGrams float64 `json:"yield_grams" validate:"gte=0"`
+	Servings   float64 `json:"servings" validate:"gte=0"`
+}
+
+// RecipeIngredient represents grams of the food in the recipe
+type RecipeIngredient struct {
+	FDCID       int     `json:"fdc_id" validate:"required,gt=0"`
+	Grams       float64 `json:"grams" validate:"required,gt=0"`
+	Description string  `json:"description,omitempty"`
+}
+
+// RecipeResponse represents the recipe with its nutrition. FDCID of the
+// recipe is accepted wherever fdc id of a food is.
+type RecipeResponse struct {
+	FDCID        int                `json:"fdc_id"`
+	Name         string             `json:"name"`
+	Ingredients  []RecipeIngredient `json:"ingredients,omitempty"`
+	YieldGrams   float64            `json:"yield_grams"`
+	Servings     float64            `json:"servings"`
+	ServingGrams float64            `json:"serving_grams"`
+
+	// Per100g and PerServing are nutrition of the cooked dish, set when
+	// the single recipe is requested.
+	Per100g    *MealNutrition `json:"per_100g,omitempty"`
+	PerServing *MealNutrition `json:"per_serving,omitempty"`
+
+	DateCreated time.Time `json:"date_created"`
+	DateUpdated time.Time `json:"date_updated"`
+}
+
+// RecipesResponse represents response on recipes list request
+type RecipesResponse struct {
+	Recipes []RecipeResponse `json:"recipes"`
 }
 
 // SearchResponse represents the request result of food search request
 type SearchResponse struct {
+	// Criteria is a copy of the criteria that were used in the search.
+	Criteria SearchCriteria `json:"criteria"`
+
+	// TotalHits the total number of foods found matching the search criteria.
+	TotalHits int `json:"total_hits"`
+
+	// CurrentPage the current page of results being returned.
+	CurrentPage int `json:"current_page"`
+
+	// TotalPages represents total number of pages found matching the search
+	// criteria.
+	TotalPages int `json:"total_pages"`
+
 	// Foods is the list of foods found matching the search criteria.
 	Products []ProductInfo `json:"products"`
+
+	// Degraded is set when some of the providers could not be searched, e.g.
+	// external api was not called because it is down, and the result holds
+	// only the foods found in storage and by other providers.
+	Degraded bool `json:"degraded,omitempty"`
+}
+
+// SearchCriteria represents the optional query parameters of search request.
+type SearchCriteria struct {
+	// SearchInput is the search string for given food
+	SearchInput string `json:"search_input"`
+
+	// DataTypes restricts search to given data types e.g. Branded, Foundation.
+	DataTypes []string `json:"data_types,omitempty"`
+
+	// BrandOwner restricts search to given brand owner.
+	BrandOwner string `json:"brand_owner,omitempty"`
+
+	// Ingredients restricts search to foods with given ingredients.
+	Ingredients string `json:"ingredients,omitempty"`
+
+	// RequireAllWords requires every word of search input to be matched.
+	RequireAllWords bool `json:"require_all_words"`
+
+	// Page is the page of results to return, starts from 1.
+	Page int `json:"page"`
+
+	// SortField is name of the field by which to sort.
+	SortField string `json:"sort_field,omitempty"`
+
+	// SortDirection is the direction of the sorting, either asc or desc.
+	SortDirection string `json:"sort_direction,omitempty"`
 }
 
 // Food represents a information of Food from the search request
@@ -31,4 +406,14 @@ type ProductInfo struct {
 	Description string `json:"description"`
 	// BrandOwner brand owner for the food
 	BrandOwner string `json:"brand_owner"`
+	// Provider is the name of the provider which supplied the food
+	Provider string `json:"provider"`
+	// Barcode is GTIN, UPC or EAN code of the food when known
+	Barcode string `json:"barcode,omitempty"`
+	// DataType is the data type of the food e.g. Branded, Foundation
+	DataType string `json:"data_type,omitempty"`
+	// PublishedDate is the date the food was published in YYYY-MM-DD form
+	PublishedDate string `json:"published_date,omitempty"`
+	// FoodCategory is the category of the food e.g. Fruits and Fruit Juices
+	FoodCategory string `json:"food_category,omitempty"`
 }
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/igomonov88/sugar/internal/nutrients"
+	"github.com/igomonov88/sugar/internal/platform/web"
+	"github.com/igomonov88/sugar/internal/provider"
+)
+
+// Values of nutrients query parameter selecting the whole profile or none of
+// it, i.e. carbohydrates only.
+const (
+	nutrientsAll   = "all"
+	nutrientsCarbs = "carbs"
+)
+
+// nutrientAliases are keys accepted in the list of nutrients query parameter
+// selecting more than one nutrient of the registry. Carbs select every kind of
+// carbohydrates providers have.
+var nutrientAliases = map[string][]string{
+	nutrientsCarbs: {"carbs", "carbs_by_summation", "available_carbs"},
+}
+
+// nutrientSelection tells which nutrients of the profile to respond with. Nil
+// selection responds with none of them.
+type nutrientSelection struct {
+	all  bool
+	tags map[string]bool
+}
+
+// parseNutrients parses nutrients query parameter, which is all, carbs or the
+// comma separated list of nutrient keys of the registry e.g. fiber,sugars. Carbs alone is the
+// default, as carbohydrates are always in the response.
+func parseNutrients(r *http.Request) (*nutrientSelection, error) {
+	v := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("nutrients")))
+	switch v {
+	case "", nutrientsCarbs:
+		return nil, nil
+	case nutrientsAll:
+		return &nutrientSelection{all: true}, nil
+	}
+
+	sel := nutrientSelection{tags: make(map[string]bool)}
+	var fields []web.FieldError
+	for _, key := range strings.Split(v, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		keys, ok := nutrientAliases[key]
+		if !ok {
+			keys = []string{key}
+		}
+		for _, k := range keys {
+			n, ok := nutrients.ByKey(k)
+			if !ok {
+				fields = append(fields, web.FieldError{Field: "nutrients", Error: "unknown nutrient " + strconv.Quote(key)})
+				break
+			}
+			sel.tags[n.Tag] = true
+		}
+	}
+
+	if len(fields) != 0 {
+		return nil, &web.Error{
+			Err:    errors.New("field validator error"),
+			Status: http.StatusBadRequest,
+			Fields: fields,
+		}
+	}
+	return &sel, nil
+}
+
+// apply returns the response with nutrients of the selection only. The
+// response is a copy, so responses kept in cache are not changed.
+func (sel *nutrientSelection) apply(resp DetailsResponse) DetailsResponse {
+	all := resp.Nutrients
+	resp.Nutrients = nil
+
+	if sel == nil {
+		return resp
+	}
+	for _, n := range all {
+		if sel.all || sel.tags[n.tag()] {
+			resp.Nutrients = append(resp.Nutrients, n)
+		}
+	}
+	return resp
+}
+
+// tag returns the INFOODS tag of the nutrient, empty when the registry does
+// not know it.
+func (n Nutrient) tag() string {
+	nt, _ := nutrients.Identify(provider.Nutrient{Number: n.Number, Name: n.Name, UnitName: n.UnitName})
+	return nt.Tag
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/igomonov88/sugar/internal/carbohydrates"
+	"github.com/igomonov88/sugar/internal/platform/web"
+)
+
+// detailsOptions are query parameters shaping details responses of the
+// request.
+type detailsOptions struct {
+	nutrients *nutrientSelection
+	grams     float64
+
+	// exchange counts carbohydrates in exchange units too, nil counts them
+	// in grams only.
+	exchange *carbohydrates.Exchange
+}
+
+// parseDetailsOptions parses nutrients, grams and units query parameters.
+func parseDetailsOptions(r *http.Request) (detailsOptions, error) {
+	var opts detailsOptions
+
+	sel, err := parseNutrients(r)
+	if err != nil {
+		return opts, err
+	}
+	opts.nutrients = sel
+
+	if opts.grams, err = parseGrams(r); err != nil {
+		return opts, err
+	}
+
+	if opts.exchange, err = parseUnits(r); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+// apply returns the response shaped by the options. The response is a copy,
+// so responses kept in cache are not changed.
+func (o detailsOptions) apply(resp DetailsResponse) DetailsResponse {
+	resp = withWeight(o.nutrients.apply(resp), o.grams)
+	if o.exchange != nil {
+		resp = withUnits(resp, *o.exchange)
+	}
+	return resp
+}
+
+// parseUnits parses units query parameter, the exchange unit e.g. xe to count
+// carbohydrates in, and unit_grams, the gram equivalent of the unit. Grams
+// only are counted when units are not set.
+func parseUnits(r *http.Request) (*carbohydrates.Exchange, error) {
+	unit := strings.TrimSpace(r.URL.Query().Get("units"))
+	if unit == "" || strings.EqualFold(unit, "g") {
+		return nil, nil
+	}
+
+	var fields []web.FieldError
+	var grams float64
+	if v := strings.TrimSpace(r.URL.Query().Get("unit_grams")); v != "" {
+		var err error
+		if grams, err = strconv.ParseFloat(v, 64); err != nil || grams <= 0 || math.IsNaN(grams) || math.IsInf(grams, 0) {
+			fields = append(fields, web.FieldError{Field: "unit_grams", Error: "unit_grams must be a positive number"})
+		}
+	}
+
+	e, err := carbohydrates.NewExchange(unit, grams)
+	if err != nil && len(fields) == 0 {
+		field := "units"
+		if grams != 0 {
+			field = "unit_grams"
+		}
+		fields = append(fields, web.FieldError{Field: field, Error: err.Error()})
+	}
+
+	if len(fields) != 0 {
+		return nil, &web.Error{
+			Err:    errors.New("field validator error"),
+			Status: http.StatusBadRequest,
+			Fields: fields,
+		}
+	}
+	return &e, nil
+}
+
+// withUnits returns the response with carbohydrates of the food, its
+// portions, serving and weight counted in given exchange units too.
+func withUnits(resp DetailsResponse, e carbohydrates.Exchange) DetailsResponse {
+	if resp.UnitName == "" {
+		return resp
+	}
+	resp.Carbohydrates = resp.Carbohydrates.InUnits(e)
+
+	portions := make([]Portion, len(resp.Portions))
+	for i, p := range resp.Portions {
+		if p.Carbohydrates != nil {
+			carbs := p.Carbohydrates.InUnits(e)
+			p.Carbohydrates = &carbs
+		}
+		portions[i] = p
+	}
+	resp.Portions = portions
+
+	if resp.Serving != nil && resp.Serving.Carbohydrates != nil {
+		serving := *resp.Serving
+		carbs := serving.Carbohydrates.InUnits(e)
+		serving.Carbohydrates = &carbs
+		resp.Serving = &serving
+	}
+	if resp.Weight != nil {
+		resp.Weight = &Weight{
+			Grams:         resp.Weight.Grams,
+			Carbohydrates: resp.Weight.Carbohydrates.InUnits(e),
+			FPU:           resp.Weight.FPU,
+		}
+	}
+	return resp
+}
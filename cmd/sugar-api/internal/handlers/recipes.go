@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/sugar/internal/platform/web"
+	"github.com/igomonov88/sugar/internal/storage"
+)
+
+// ListRecipes returns recipes which names contain search query parameter,
+// every recipe when it is not given.
+func (f *Food) ListRecipes(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Food.ListRecipes")
+	defer span.End()
+
+	recipes, err := storage.ListRecipes(ctx, f.db, strings.TrimSpace(r.URL.Query().Get("search")))
+	if err != nil {
+		return web.NewRequestError(err, http.StatusInternalServerError)
+	}
+
+	resp := RecipesResponse{Recipes: make([]RecipeResponse, len(recipes))}
+	for i := range recipes {
+		resp.Recipes[i] = recipeInfo(&recipes[i])
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// CreateRecipe saves the recipe and returns it with its nutrition. Details of
+// the ingredients are looked up like DetailsBatch does and saved to storage,
+// as nutrition of the recipe is computed from stored details.
+func (f *Food) CreateRecipe(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Food.CreateRecipe")
+	defer span.End()
+
+	var req RecipeRequest
+	if err := web.Decode(r, &req); err != nil {
+		return err
+	}
+
+	recipe, err := f.recipe(ctx, req)
+	if err != nil {
+		return upstreamError(w, err, http.StatusInternalServerError)
+	}
+
+	id, err := storage.CreateRecipe(ctx, f.db, recipe)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusInternalServerError)
+	}
+
+	return f.respondRecipe(ctx, w, id, http.StatusCreated)
+}
+
+// RetrieveRecipe returns the recipe with its nutrition per 100 grams and per
+// serving.
+func (f *Food) RetrieveRecipe(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Food.RetrieveRecipe")
+	defer span.End()
+
+	id, err := recipeID(params)
+	if err != nil {
+		return err
+	}
+
+	return f.respondRecipe(ctx, w, id, http.StatusOK)
+}
+
+// UpdateRecipe replaces the recipe and returns it with its nutrition.
+func (f *Food) UpdateRecipe(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Food.UpdateRecipe")
+	defer span.End()
+
+	id, err := recipeID(params)
+	if err != nil {
+		return err
+	}
+
+	var req RecipeRequest
+	if err := web.Decode(r, &req); err != nil {
+		return err
+	}
+
+	recipe, err := f.recipe(ctx, req)
+	if err != nil {
+		return upstreamError(w, err, http.StatusInternalServerError)
+	}
+	recipe.FDCID = id
+
+	switch err := storage.UpdateRecipe(ctx, f.db, recipe); err {
+	case nil:
+	case sql.ErrNoRows:
+		return web.NewRequestError(errors.Errorf("recipe %d is not found", id), http.StatusNotFound)
+	default:
+		return web.NewRequestError(err, http.StatusInternalServerError)
+	}
+	f.cache.Delete(strconv.Itoa(id))
+
+	return f.respondRecipe(ctx, w, id, http.StatusOK)
+}
+
+// DeleteRecipe deletes the recipe.
+func (f *Food) DeleteRecipe(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Food.DeleteRecipe")
+	defer span.End()
+
+	id, err := recipeID(params)
+	if err != nil {
+		return err
+	}
+
+	switch err := storage.DeleteRecipe(ctx, f.db, id); err {
+	case nil:
+	case sql.ErrNoRows:
+		return web.NewRequestError(errors.Errorf("recipe %d is not found", id), http.StatusNotFound)
+	default:
+		return web.NewRequestError(err, http.StatusInternalServerError)
+	}
+	f.cache.Delete(strconv.Itoa(id))
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// recipeID parses fdc id of the recipe from the path.
+func recipeID(params map[string]string) (int, error) {
+	id, err := strconv.Atoi(params["id"])
+	if err != nil || id <= 0 {
+		return 0, web.NewRequestError(errors.New("id must be a positive integer"), http.StatusBadRequest)
+	}
+	if id < storage.RecipeIDBase {
+		return 0, web.NewRequestError(errors.Errorf("recipe %d is not found", id), http.StatusNotFound)
+	}
+	return id, nil
+}
+
+// recipe converts the request to the recipe to store. Ingredients must be
+// foods, not other recipes, and their details are saved to storage when they
+// are not there yet. Ingredients which are not found or have no nutrient
+// profile are reported as field errors.
+func (f *Food) recipe(ctx context.Context, req RecipeRequest) (storage.Recipe, error) {
+	recipe := storage.Recipe{
+		Name:        strings.TrimSpace(req.Name),
+		YieldGrams:  req.YieldGrams,
+		Servings:    req.Servings,
+		Ingredients: make([]storage.RecipeIngredient, len(req.Ingredients)),
+	}
+
+	var fields []web.FieldError
+	ids := make([]int, len(req.Ingredients))
+	var total float64
+	for i, in := range req.Ingredients {
+		if in.FDCID >= storage.RecipeIDBase {
+			fields = append(fields, web.FieldError{
+				Field: fmt.Sprintf("ingredients[%d].fdc_id", i),
+				Error: "recipes can not be ingredients of other recipes",
+			})
+		}
+		recipe.Ingredients[i] = storage.RecipeIngredient{FDCID: in.FDCID, Grams: in.Grams}
+		ids[i] = in.FDCID
+		total += in.Grams
+	}
+	if len(fields) == 0 {
+		invalid, err := f.storeIngredients(ctx, uniqueIDs(ids))
+		if err != nil {
+			return recipe, err
+		}
+		for i, in := range req.Ingredients {
+			if msg, ok := invalid[in.FDCID]; ok {
+				fields = append(fields, web.FieldError{
+					Field: fmt.Sprintf("ingredients[%d].fdc_id", i),
+					Error: msg,
+				})
+			}
+		}
+	}
+	if len(fields) > 0 {
+		return recipe, &web.Error{
+			Err:    errors.New("field validator error"),
+			Status: http.StatusBadRequest,
+			Fields: fields,
+		}
+	}
+
+	if recipe.YieldGrams == 0 {
+		recipe.YieldGrams = total
+	}
+	if recipe.Servings == 0 {
+		recipe.Servings = 1
+	}
+	return recipe, nil
+}
+
+// storeIngredients makes sure details of the foods are in storage. Foods not
+// there yet are looked up like DetailsBatch does and saved before it returns.
+// Nutrients of the recipe are summed from nutrient profiles of the foods, so
+// foods which could not be found or have no profile, like foods imported
+// without nutrients, are returned with the reason they can not be used.
+// The error of external api is returned when the foods could not be looked
+// up.
+func (f *Food) storeIngredients(ctx context.Context, fdcIDs []int) (map[int]string, error) {
+	stored, err := storage.RetrieveDetailsBatch(ctx, f.db, fdcIDs)
+	if err != nil {
+		return nil, web.NewRequestError(err, http.StatusInternalServerError)
+	}
+
+	invalid := make(map[int]string)
+	var rest []int
+	for _, id := range fdcIDs {
+		d, ok := stored[id]
+		switch {
+		case !ok:
+			rest = append(rest, id)
+		case len(d.Nutrients) == 0:
+			invalid[id] = fmt.Sprintf("food %d has no nutrient profile", id)
+		}
+	}
+	if len(rest) == 0 {
+		return invalid, nil
+	}
+
+	batch, err := f.detailsBatch(ctx, rest, false)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Degraded {
+		return nil, web.NewRequestError(errors.New("foods could not be looked up, external api is down"), http.StatusServiceUnavailable)
+	}
+	if len(batch.Failed) != 0 {
+		return nil, batch.err
+	}
+
+	for _, item := range batch.Foods {
+		switch {
+		case !item.Found:
+			invalid[item.FDCID] = fmt.Sprintf("food %d is not found", item.FDCID)
+		case len(item.Details.Nutrients) == 0:
+			invalid[item.FDCID] = fmt.Sprintf("food %d has no nutrient profile", item.FDCID)
+		default:
+			if err := f.saveDetails(ctx, item.FDCID, *item.Details); err != nil {
+				return nil, web.NewRequestError(err, http.StatusInternalServerError)
+			}
+		}
+	}
+	return invalid, nil
+}
+
+// respondRecipe responds with the recipe with given fdc id and its nutrition.
+// Nutrition is taken from details of the recipe, the way it is for foods.
+func (f *Food) respondRecipe(ctx context.Context, w http.ResponseWriter, id, statusCode int) error {
+	recipe, err := storage.RetrieveRecipe(ctx, f.db, id)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return web.NewRequestError(errors.Errorf("recipe %d is not found", id), http.StatusNotFound)
+	default:
+		return web.NewRequestError(err, http.StatusInternalServerError)
+	}
+
+	details, err := f.details(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	resp := recipeInfo(recipe)
+	resp.Ingredients = make([]RecipeIngredient, len(recipe.Ingredients))
+	for i, in := range recipe.Ingredients {
+		resp.Ingredients[i] = RecipeIngredient{FDCID: in.FDCID, Grams: in.Grams, Description: in.Description}
+	}
+	per100g, _ := nutrition(details, 100)
+	perServing, _ := nutrition(details, resp.ServingGrams)
+	resp.Per100g, resp.PerServing = &per100g, &perServing
+
+	return web.Respond(ctx, w, resp, statusCode)
+}
+
+// recipeInfo converts the recipe of storage to the response, without its
+// ingredients and nutrition.
+func recipeInfo(r *storage.Recipe) RecipeResponse {
+	return RecipeResponse{
+		FDCID:        r.FDCID,
+		Name:         r.Name,
+		YieldGrams:   r.YieldGrams,
+		Servings:     r.Servings,
+		ServingGrams: round(r.YieldGrams / r.Servings),
+		DateCreated:  r.DateCreated,
+		DateUpdated:  r.DateUpdated,
+	}
+}
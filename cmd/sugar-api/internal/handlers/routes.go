@@ -1,29 +1,54 @@
 package handlers
 
 import (
+	"expvar"
 	"log"
 	"net/http"
 	"os"
 
 	"github.com/jmoiron/sqlx"
 
-	api "github.com/igomonov88/sugar/internal/fdc"
+	"github.com/igomonov88/sugar/internal/bolus"
+	"github.com/igomonov88/sugar/internal/carbohydrates"
 	"github.com/igomonov88/sugar/internal/mid"
 	"github.com/igomonov88/sugar/internal/platform/auth"
 	"github.com/igomonov88/sugar/internal/platform/cache"
+	"github.com/igomonov88/sugar/internal/platform/flight"
 	"github.com/igomonov88/sugar/internal/platform/web"
+	"github.com/igomonov88/sugar/internal/provider"
+	"github.com/igomonov88/sugar/internal/storage"
 )
 
+// coalesced counts lookups which shared the result of the same lookup in
+// progress, by kind of lookup: search, details or barcode.
+var coalesced = expvar.NewMap("lookup_coalesced")
+
 // Food represents the Food Data Central API method handler set.
 type Food struct {
 	db            *sqlx.DB
-	apiClient     *api.Client
 	cache         *cache.Cache
 	authenticator *auth.Authenticator
+
+	// providers are the sources of food data in priority order, their search
+	// results are merged in this order.
+	providers []provider.FoodProvider
+
+	// rank orders foods found in storage.
+	rank storage.RankPolicy
+
+	// carbs tells how net carbs of the foods are computed.
+	carbs carbohydrates.Policy
+
+	// dosing is the setup insulin doses are calculated for.
+	dosing bolus.Config
+
+	// flight coalesces concurrent lookups of the same search input or fdcID,
+	// so only one of them calls external api and saves the result.
+	flight *flight.Group
 }
 
 // API constructs an http.Handler with all application routes defined.
-func API(build string, shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, fdcClient *api.Client, c *cache.Cache) http.Handler {
+func API(build string, shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, providers []provider.FoodProvider, rank storage.RankPolicy, carbs carbohydrates.Policy, dosing bolus.Config, c *cache.Cache) http.Handler {
 	// Construct the web.App which holds all routes as well as common Middleware.
 	app := web.NewApp(shutdown, mid.Logger(log), mid.Errors(log), mid.Metrics(), mid.Panics(log))
 
@@ -35,14 +60,40 @@ func API(build string, shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, fd
 
 	// Register food endpoints.
 	f := Food{
-		apiClient: fdcClient,
+		providers: providers,
+		rank:      rank,
+		carbs:     carbs,
+		dosing:    dosing,
 		cache:     c,
 		db:        db,
+		flight:    flight.New(coalesced),
 	}
 
 	app.Handle("GET", "/v1/health", check.Health)
 	app.Handle("GET", "/v1/search/:product", f.Search)
 	app.Handle("GET", "/v1/details/:fdcID", f.Details)
+	app.Handle("GET", "/v1/details", f.DetailsBatch)
+	app.Handle("POST", "/v1/details", f.DetailsBatch)
+	app.Handle("GET", "/v1/barcode/:gtin", f.Barcode)
+	app.Handle("POST", "/v1/bolus", f.Bolus)
+	app.Handle("GET", "/v1/iob", f.IOB)
+	app.Handle("POST", "/v1/meals/calculate", f.CalculateMeal)
+	app.Handle("GET", "/v1/recipes", f.ListRecipes)
+	app.Handle("POST", "/v1/recipes", f.CreateRecipe)
+	app.Handle("GET", "/v1/recipes/:id", f.RetrieveRecipe)
+	app.Handle("PUT", "/v1/recipes/:id", f.UpdateRecipe)
+	app.Handle("DELETE", "/v1/recipes/:id", f.DeleteRecipe)
 
 	return app
 }
+
+// provider returns the provider with given name, or nil when it is not
+// configured.
+func (f *Food) provider(name string) provider.FoodProvider {
+	for _, p := range f.providers {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
+}
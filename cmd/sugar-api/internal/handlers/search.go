@@ -2,73 +2,368 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/jmoiron/sqlx"
 	"go.opencensus.io/trace"
 
 	api "github.com/igomonov88/sugar/internal/fdc"
 	"github.com/igomonov88/sugar/internal/platform/web"
+	"github.com/igomonov88/sugar/internal/provider"
 	"github.com/igomonov88/sugar/internal/storage"
 )
 
 // Search returns result of the food with food ids from given search query.
+// Every configured provider is searched and results are merged in priority
+// order of the providers.
 func (f *Food) Search(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
 	ctx, span := trace.StartSpan(ctx, "handlers.Food.Search")
 	defer span.End()
 
 	si := strings.TrimSpace(params["product"])
 
-	foods, err := storage.List(ctx, f.db, si)
+	sc, err := searchCriteria(r, si)
 	if err != nil {
-		return web.NewRequestError(err, http.StatusInternalServerError)
+		return err
 	}
 
-	if len(foods) != 0 {
-		resp := SearchResponse{Products: make([]ProductInfo, len(foods))}
-		for i := range foods {
-			product := ProductInfo{
-				FDCID:       foods[i].FDCID,
-				Description: foods[i].Description,
-				BrandOwner:  foods[i].BrandOwner,
+	// Storage only knows about the first page of plain search input, so any
+	// narrowed search goes straight to the providers.
+	plain := sc.plain()
+
+	if plain {
+		foods, err := storage.List(ctx, f.db, si, f.rank)
+		if err != nil {
+			return web.NewRequestError(err, http.StatusInternalServerError)
+		}
+
+		if len(foods) != 0 {
+			resp, err := f.withRecipes(ctx, f.searchFromStorage(sc, foods))
+			if err != nil {
+				return err
 			}
-			resp.Products[i] = product
+			return web.Respond(ctx, w, &resp, http.StatusOK)
+		}
+	}
+
+	// Concurrent misses of the same search share one search of the providers
+	// and one save of the result. The result is saved before the search is
+	// over, so searches coming after it find the foods in storage.
+	v, err, _ := f.flight.Do("search", sc.key(), func() (interface{}, error) {
+		ctx, cancel := detach(ctx)
+		defer cancel()
+
+		sr, partial, err := f.searchProviders(ctx, sc.query())
+		if err != nil {
+			return nil, err
+		}
+
+		resp := SearchResponse{
+			Criteria:    sc,
+			TotalHits:   sr.TotalHits,
+			CurrentPage: sr.CurrentPage,
+			TotalPages:  sr.TotalPages,
+			Products:    make([]ProductInfo, len(sr.Foods)),
+			Degraded:    partial,
+		}
+		for i := range sr.Foods {
+			resp.Products[i] = productInfo(sr.Foods[i])
 		}
 
-		return web.Respond(ctx, w, &resp, http.StatusOK)
+		// Results missing some of the providers are not saved, as storage
+		// would answer with them until the search input is requested again.
+		if plain && !partial && len(resp.Products) != 0 {
+			saveSearchInput(ctx, f.db, si, &resp)
+		}
+		return &resp, nil
+	})
+	if err != nil {
+		if circuitOpen(err) {
+			return f.degradedSearch(ctx, w, sc)
+		}
+		return upstreamError(w, err, http.StatusInternalServerError)
 	}
 
-	sr, err := api.SearchOutput(ctx, f.apiClient, si)
+	resp := *v.(*SearchResponse)
+	if plain {
+		if resp, err = f.withRecipes(ctx, resp); err != nil {
+			return err
+		}
+	}
+	if resp.Degraded {
+		w.Header().Set(degradedHeader, "true")
+	}
+	return web.Respond(ctx, w, &resp, http.StatusOK)
+}
+
+// withRecipes returns the response with recipes which names contain the
+// search input ahead of the foods. It is meant for plain searches only, as
+// other criteria do not apply to recipes. The response is a copy, so
+// responses shared by concurrent searches are not changed.
+func (f *Food) withRecipes(ctx context.Context, resp SearchResponse) (SearchResponse, error) {
+	recipes, err := storage.ListRecipes(ctx, f.db, resp.Criteria.SearchInput)
+	if err != nil {
+		return resp, web.NewRequestError(err, http.StatusInternalServerError)
+	}
+	if len(recipes) == 0 {
+		return resp, nil
+	}
+
+	products := make([]ProductInfo, 0, len(recipes)+len(resp.Products))
+	for _, r := range recipes {
+		products = append(products, ProductInfo{
+			FDCID:       r.FDCID,
+			Description: r.Name,
+			Provider:    storage.RecipeProvider,
+			DataType:    "Recipe",
+		})
+	}
+	resp.Products = append(products, resp.Products...)
+	resp.TotalHits += len(recipes)
+	return resp, nil
+}
+
+// searchProviders searches every provider at once and merges their results.
+// When some of the providers fail the result of the rest is returned as
+// partial, the error is returned only when all of them fail. The error of the
+// provider with the highest priority is returned then.
+func (f *Food) searchProviders(ctx context.Context, q provider.Query) (*provider.SearchResult, bool, error) {
+	if len(f.providers) == 0 {
+		return nil, false, errors.New("no food providers configured")
+	}
+
+	results := make([]*provider.SearchResult, len(f.providers))
+	errs := make([]error, len(f.providers))
+
+	var wg sync.WaitGroup
+	wg.Add(len(f.providers))
+	for i := range f.providers {
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = f.providers[i].Search(ctx, q)
+		}(i)
+	}
+	wg.Wait()
+
+	var failed int
+	var firstErr error
+	for i := range errs {
+		if errs[i] == nil {
+			continue
+		}
+		failed++
+		if firstErr == nil {
+			firstErr = errs[i]
+		}
+		results[i] = nil
+	}
+	if failed == len(f.providers) {
+		return nil, false, firstErr
+	}
+
+	return provider.Merge(results...), failed != 0, nil
+}
+
+// degradedSearch answers the search from storage only, while every provider
+// is down. Storage knows nothing but the search input, so other criteria are
+// not applied and the response is marked as degraded.
+func (f *Food) degradedSearch(ctx context.Context, w http.ResponseWriter, sc SearchCriteria) error {
+	foods, err := storage.List(ctx, f.db, sc.SearchInput, f.rank)
 	if err != nil {
 		return web.NewRequestError(err, http.StatusInternalServerError)
 	}
 
-	resp := SearchResponse{Products: make([]ProductInfo, len(sr.Foods))}
-	if len(sr.Foods) != 0 {
-		for i := range sr.Foods {
-			product := ProductInfo{
-				FDCID:       sr.Foods[i].FDCID,
-				Description: sr.Foods[i].Description,
-				BrandOwner:  sr.Foods[i].BrandOwner,
+	resp := f.searchFromStorage(sc, foods)
+	resp.Degraded = true
+	if sc.plain() {
+		if resp, err = f.withRecipes(ctx, resp); err != nil {
+			return err
+		}
+	}
+
+	w.Header().Set(degradedHeader, "true")
+	return web.Respond(ctx, w, &resp, http.StatusOK)
+}
+
+// searchFromStorage converts foods found in storage to the response, in
+// priority order of the providers which supplied them. Foods of the same
+// provider keep the order storage ranked them in.
+func (f *Food) searchFromStorage(sc SearchCriteria, foods []storage.Food) SearchResponse {
+	priority := make(map[string]int, len(f.providers))
+	for i := range f.providers {
+		priority[f.providers[i].Name()] = i
+	}
+	rank := func(name string) int {
+		if p, ok := priority[name]; ok {
+			return p
+		}
+		return len(f.providers)
+	}
+	sort.SliceStable(foods, func(i, j int) bool {
+		return rank(foods[i].Provider) < rank(foods[j].Provider)
+	})
+
+	resp := SearchResponse{
+		Criteria:    sc,
+		TotalHits:   len(foods),
+		CurrentPage: 1,
+		TotalPages:  1,
+		Products:    make([]ProductInfo, len(foods)),
+	}
+	for i := range foods {
+		product := ProductInfo{
+			FDCID:         foods[i].FDCID,
+			Description:   foods[i].Description,
+			BrandOwner:    foods[i].BrandOwner,
+			Provider:      foods[i].Provider,
+			Barcode:       foods[i].Barcode,
+			DataType:      foods[i].DataType,
+			PublishedDate: foods[i].PublishedDate,
+			FoodCategory:  foods[i].FoodCategory,
+		}
+		resp.Products[i] = product
+	}
+	return resp
+}
+
+// productInfo converts the food found by the provider to the product of the
+// response.
+func productInfo(item provider.Item) ProductInfo {
+	p := ProductInfo{
+		Description:   item.Description,
+		BrandOwner:    item.BrandOwner,
+		Provider:      item.Provider,
+		Barcode:       item.Barcode,
+		DataType:      item.DataType,
+		PublishedDate: item.PublishedDate,
+		FoodCategory:  item.FoodCategory,
+	}
+	if item.Provider == provider.FDC {
+		p.FDCID, _ = strconv.Atoi(item.ID)
+	}
+	return p
+}
+
+// searchCriteria parses and validates optional query parameters of the search
+// request. Every invalid parameter is reported as a field error.
+func searchCriteria(r *http.Request, searchInput string) (SearchCriteria, error) {
+	q := r.URL.Query()
+	sc := SearchCriteria{
+		SearchInput:   searchInput,
+		BrandOwner:    strings.TrimSpace(q.Get("brand_owner")),
+		Ingredients:   strings.TrimSpace(q.Get("ingredients")),
+		SortField:     strings.TrimSpace(q.Get("sort_field")),
+		SortDirection: strings.ToLower(strings.TrimSpace(q.Get("sort_direction"))),
+		Page:          1,
+	}
+
+	var fields []web.FieldError
+
+	for _, v := range q["data_types"] {
+		for _, dt := range strings.Split(v, ",") {
+			dt = strings.TrimSpace(dt)
+			if dt == "" {
+				continue
+			}
+			if !api.DataTypes[dt] {
+				fields = append(fields, web.FieldError{Field: "data_types", Error: "unknown data type " + strconv.Quote(dt)})
+				continue
 			}
-			resp.Products[i] = product
+			sc.DataTypes = append(sc.DataTypes, dt)
 		}
+	}
 
-		go saveSearchInput(ctx, f.db, si, &resp)
-		return web.Respond(ctx, w, &resp, http.StatusOK)
+	if v := q.Get("require_all_words"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			fields = append(fields, web.FieldError{Field: "require_all_words", Error: "must be a boolean"})
+		}
+		sc.RequireAllWords = b
 	}
 
-	return web.Respond(ctx, w, &resp, http.StatusOK)
+	if v := q.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			fields = append(fields, web.FieldError{Field: "page", Error: "must be a positive integer"})
+		}
+		sc.Page = page
+	}
+
+	if sc.SortField != "" && !api.SortFields[sc.SortField] {
+		fields = append(fields, web.FieldError{Field: "sort_field", Error: "unknown sort field " + strconv.Quote(sc.SortField)})
+	}
+
+	switch sc.SortDirection {
+	case "", api.SortAsc, api.SortDesc:
+	default:
+		fields = append(fields, web.FieldError{Field: "sort_direction", Error: "must be asc or desc"})
+	}
+
+	if searchInput == "" {
+		fields = append(fields, web.FieldError{Field: "search_input", Error: "must not be empty"})
+	}
+
+	if len(fields) != 0 {
+		return sc, &web.Error{
+			Err:    errors.New("field validator error"),
+			Status: http.StatusBadRequest,
+			Fields: fields,
+		}
+	}
+
+	return sc, nil
+}
+
+// plain reports whether search criteria holds nothing but the search input.
+func (sc SearchCriteria) plain() bool {
+	return len(sc.DataTypes) == 0 && sc.BrandOwner == "" && sc.Ingredients == "" &&
+		!sc.RequireAllWords && sc.Page == 1 && sc.SortField == "" && sc.SortDirection == ""
+}
+
+// key returns the key which identifies equal searches.
+func (sc SearchCriteria) key() string {
+	if sc.plain() {
+		return sc.SearchInput
+	}
+	b, err := json.Marshal(sc)
+	if err != nil {
+		return sc.SearchInput + "?" + err.Error()
+	}
+	return string(b)
+}
+
+// query converts search criteria to the query of the providers.
+func (sc SearchCriteria) query() provider.Query {
+	return provider.Query{
+		SearchInput:     sc.SearchInput,
+		DataTypes:       sc.DataTypes,
+		BrandOwner:      sc.BrandOwner,
+		Ingredients:     sc.Ingredients,
+		RequireAllWords: sc.RequireAllWords,
+		Page:            sc.Page,
+		SortField:       sc.SortField,
+		SortDirection:   sc.SortDirection,
+	}
 }
 
 // addToStorage is add value to the storage.
 func saveSearchInput(ctx context.Context, db *sqlx.DB, searchInput string, resp *SearchResponse) {
 	for i := range resp.Products {
 		f := storage.Food{
-			FDCID:       resp.Products[i].FDCID,
-			Description: resp.Products[i].Description,
-			BrandOwner:  resp.Products[i].BrandOwner,
+			FDCID:         resp.Products[i].FDCID,
+			Description:   resp.Products[i].Description,
+			BrandOwner:    resp.Products[i].BrandOwner,
+			Provider:      resp.Products[i].Provider,
+			Barcode:       resp.Products[i].Barcode,
+			DataType:      resp.Products[i].DataType,
+			PublishedDate: resp.Products[i].PublishedDate,
+			FoodCategory:  resp.Products[i].FoodCategory,
 		}
 		storage.SaveSearchInput(ctx, db, f, searchInput)
 	}
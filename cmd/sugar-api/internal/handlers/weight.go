@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/igomonov88/sugar/internal/carbohydrates"
+	"github.com/igomonov88/sugar/internal/platform/web"
+)
+
+// parseGrams parses grams query parameter, the weight of the food to respond
+// with carbohydrates for. Zero is returned when it is not set.
+func parseGrams(r *http.Request) (float64, error) {
+	v := strings.TrimSpace(r.URL.Query().Get("grams"))
+	if v == "" {
+		return 0, nil
+	}
+
+	grams, err := strconv.ParseFloat(v, 64)
+	if err != nil || grams <= 0 || math.IsNaN(grams) || math.IsInf(grams, 0) {
+		return 0, &web.Error{
+			Err:    errors.New("field validator error"),
+			Status: http.StatusBadRequest,
+			Fields: []web.FieldError{{Field: "grams", Error: "grams must be a positive number"}},
+		}
+	}
+	return grams, nil
+}
+
+// withPortions sets carbohydrates and fat-protein units of every portion of
+// the food, along with the basis they are computed from.
+func withPortions(resp *DetailsResponse) {
+	resp.BasisGrams = carbohydrates.Basis
+	for i := range resp.Portions {
+		p := &resp.Portions[i]
+		if p.GramWeight <= 0 {
+			continue
+		}
+		if resp.UnitName != "" {
+			carbs := resp.Carbohydrates.ForWeight(p.GramWeight)
+			p.Carbohydrates = &carbs
+		}
+		if resp.FPU != nil {
+			units := resp.FPU.ForWeight(p.GramWeight)
+			p.FPU = &units
+		}
+	}
+}
+
+// withWeight returns the response with carbohydrates in given grams of the
+// food. The response is a copy, so responses kept in cache are not changed.
+func withWeight(resp DetailsResponse, grams float64) DetailsResponse {
+	if grams <= 0 || resp.UnitName == "" {
+		return resp
+	}
+	resp.Weight = &Weight{
+		Grams:         grams,
+		Carbohydrates: resp.Carbohydrates.ForWeight(grams),
+	}
+	if resp.FPU != nil {
+		units := resp.FPU.ForWeight(grams)
+		resp.Weight.FPU = &units
+	}
+	return resp
+}
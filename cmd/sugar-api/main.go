@@ -19,9 +19,14 @@ import (
 	"go.opencensus.io/trace"
 
 	"github.com/igomonov88/sugar/cmd/sugar-api/internal/handlers"
+	"github.com/igomonov88/sugar/internal/bolus"
+	"github.com/igomonov88/sugar/internal/carbohydrates"
 	apiClient "github.com/igomonov88/sugar/internal/fdc"
+	"github.com/igomonov88/sugar/internal/off"
 	"github.com/igomonov88/sugar/internal/platform/cache"
 	"github.com/igomonov88/sugar/internal/platform/database"
+	"github.com/igomonov88/sugar/internal/provider"
+	"github.com/igomonov88/sugar/internal/storage"
 )
 
 /*
@@ -83,12 +88,49 @@ func run() error {
 			Probability   float64 `conf:"default:0.05"`
 		}
 		FDCClient struct {
-			ConsumerKey string `conf:"default:07qblbARRNts5zU45YOPyC8NDQc1iuHQgTqLwbTL"`
-			APIURL      string `conf:"default:https://api.nal.usda.gov/fdc/v1/"`
+			ConsumerKey          string        `conf:"noprint"`
+			ConsumerKeys         []string      `conf:"noprint"`
+			KeyCooldown          time.Duration `conf:"default:1h"`
+			APIURL               string        `conf:"default:https://api.nal.usda.gov/fdc/v1/"`
+			RequestTimeout       time.Duration `conf:"default:10s"`
+			MaxIdleConns         int           `conf:"default:10"`
+			IdleConnTimeout      time.Duration `conf:"default:90s"`
+			TLSHandshakeTimeout  time.Duration `conf:"default:5s"`
+			MaxRetries           int           `conf:"default:3"`
+			RetryWaitMin         time.Duration `conf:"default:100ms"`
+			RetryWaitMax         time.Duration `conf:"default:2s"`
+			RequestsPerHour      int           `conf:"default:1000"`
+			Burst                int           `conf:"default:10"`
+			MaxThrottleWait      time.Duration `conf:"default:2s"`
+			QuotaCooldown        time.Duration `conf:"default:5m"`
+			CassetteMode         string
+			CassetteDir          string
+			ConsumerKeysFile     string
+			BreakerThreshold     int           `conf:"default:5"`
+			BreakerOpenTimeout   time.Duration `conf:"default:30s"`
+			BreakerHalfOpenCalls int           `conf:"default:1"`
 		}
 		Cache struct {
 			Size int `conf:"default:100"`
 		}
+		Providers struct {
+			Order []string `conf:"default:fdc;off"`
+		}
+		Ranking struct {
+			Generic []string `conf:"default:Foundation;SR Legacy;Survey (FNDDS);Branded"`
+			Branded []string `conf:"default:Branded;Foundation;SR Legacy;Survey (FNDDS)"`
+		}
+		NetCarbs struct {
+			Fiber          float64 `conf:"default:0.5"`
+			FiberThreshold float64 `conf:"default:5"`
+			SugarAlcohols  float64 `conf:"default:0.5"`
+		}
+		Bolus struct {
+			Increment float64 `conf:"default:0.5"`
+		}
+		OpenFoodFacts struct {
+			DumpPath string
+		}
 	}
 
 	if err := conf.Parse(os.Args[1:], "SUGAR", &cfg); err != nil {
@@ -202,16 +244,81 @@ func run() error {
 
 	// Construct Food Data Center Configuration
 	fdcConfig := apiClient.Config{
-		ConsumerKey: cfg.FDCClient.ConsumerKey,
-		APIURL:      cfg.FDCClient.APIURL,
+		ConsumerKey:          cfg.FDCClient.ConsumerKey,
+		ConsumerKeys:         cfg.FDCClient.ConsumerKeys,
+		ConsumerKeysFile:     cfg.FDCClient.ConsumerKeysFile,
+		KeyCooldown:          cfg.FDCClient.KeyCooldown,
+		APIURL:               cfg.FDCClient.APIURL,
+		RequestTimeout:       cfg.FDCClient.RequestTimeout,
+		MaxIdleConns:         cfg.FDCClient.MaxIdleConns,
+		IdleConnTimeout:      cfg.FDCClient.IdleConnTimeout,
+		TLSHandshakeTimeout:  cfg.FDCClient.TLSHandshakeTimeout,
+		MaxRetries:           cfg.FDCClient.MaxRetries,
+		RetryWaitMin:         cfg.FDCClient.RetryWaitMin,
+		RetryWaitMax:         cfg.FDCClient.RetryWaitMax,
+		RequestsPerHour:      cfg.FDCClient.RequestsPerHour,
+		Burst:                cfg.FDCClient.Burst,
+		MaxThrottleWait:      cfg.FDCClient.MaxThrottleWait,
+		QuotaCooldown:        cfg.FDCClient.QuotaCooldown,
+		CassetteMode:         cfg.FDCClient.CassetteMode,
+		CassetteDir:          cfg.FDCClient.CassetteDir,
+		BreakerThreshold:     cfg.FDCClient.BreakerThreshold,
+		BreakerOpenTimeout:   cfg.FDCClient.BreakerOpenTimeout,
+		BreakerHalfOpenCalls: cfg.FDCClient.BreakerHalfOpenCalls,
+		Log:                  log,
 	}
 	fdcClient, err := apiClient.Connect(fdcConfig)
 	if err != nil {
 		return errors.Wrap(err, "creating fdc api client")
 	}
+
+	// =========================================================================
+	// Start Food Providers
+
+	// Providers are constructed in priority order. Open Food Facts is used only
+	// when the path to its dump is given.
+	var providers []provider.FoodProvider
+	for _, name := range cfg.Providers.Order {
+		switch name {
+		case provider.FDC:
+			providers = append(providers, apiClient.NewProvider(fdcClient))
+		case provider.OpenFoodFacts:
+			if cfg.OpenFoodFacts.DumpPath == "" {
+				log.Println("main : Open Food Facts dump path is not set, provider is disabled")
+				continue
+			}
+			log.Printf("main : Loading Open Food Facts dump : %s", cfg.OpenFoodFacts.DumpPath)
+			p, err := off.Open(cfg.OpenFoodFacts.DumpPath)
+			if err != nil {
+				return errors.Wrap(err, "loading open food facts dump")
+			}
+			providers = append(providers, p)
+		default:
+			return errors.Errorf("unknown food provider %q", name)
+		}
+	}
+	if len(providers) == 0 {
+		return errors.New("no food providers configured")
+	}
+	rank := storage.RankPolicy{
+		Generic: cfg.Ranking.Generic,
+		Branded: cfg.Ranking.Branded,
+	}
+	carbs := carbohydrates.Policy{
+		Fiber:          cfg.NetCarbs.Fiber,
+		FiberThreshold: cfg.NetCarbs.FiberThreshold,
+		SugarAlcohols:  cfg.NetCarbs.SugarAlcohols,
+	}
+	if err := carbs.Validate(); err != nil {
+		return errors.Wrap(err, "net carbs config")
+	}
+	dosing := bolus.Config{
+		Increment: cfg.Bolus.Increment,
+	}
+
 	api := http.Server{
 		Addr:         cfg.Web.APIHost,
-		Handler:      handlers.API(build, shutdown, log, db, fdcClient, c),
+		Handler:      handlers.API(build, shutdown, log, db, providers, rank, carbs, dosing, c),
 		ReadTimeout:  cfg.Web.ReadTimeout,
 		WriteTimeout: cfg.Web.WriteTimeout,
 	}
@@ -5,12 +5,23 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/jmoiron/sqlx"
+
 	"github.com/igomonov88/sugar/cmd/sugar-api/internal/handlers"
+	"github.com/igomonov88/sugar/internal/bolus"
+	"github.com/igomonov88/sugar/internal/carbohydrates"
 	fdcAPI "github.com/igomonov88/sugar/internal/fdc"
+	"github.com/igomonov88/sugar/internal/fdc/fdctest"
+	"github.com/igomonov88/sugar/internal/off"
 	"github.com/igomonov88/sugar/internal/platform/cache"
+	"github.com/igomonov88/sugar/internal/platform/web"
+	"github.com/igomonov88/sugar/internal/provider"
+	"github.com/igomonov88/sugar/internal/storage"
 	"github.com/igomonov88/sugar/internal/tests"
 )
 
@@ -21,16 +32,27 @@ func TestFoodAPI(t *testing.T) {
 
 	shutdown := make(chan os.Signal, 1)
 
+	// Start fake of external api
+	srv := fdctest.StartServer(t)
+	defer srv.Close()
+
 	// Creating config for external api client
 	fdcConfig := fdcAPI.Config{
-		ConsumerKey: "07qblbARRNts5zU45YOPyC8NDQc1iuHQgTqLwbTL",
-		APIURL:      "https://api.nal.usda.gov/fdc/v1/",
+		ConsumerKey: "test",
+		APIURL:      srv.APIURL(),
 	}
 	// Connect to external api
 	fdcClient, err := fdcAPI.Connect(fdcConfig)
 	if err != nil {
 		t.Fatalf("\t%s\tShould be able to connect to Food Data Center api", tests.Failed)
 	}
+	// Open Food Facts provider reads the dump shipped with its tests
+	offProvider, err := off.Open("../../../internal/off/testdata/products.jsonl")
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to read Open Food Facts dump : %v", tests.Failed, err)
+	}
+	providers := []provider.FoodProvider{fdcAPI.NewProvider(fdcClient), offProvider}
+
 	cacheCfg := cache.Config{
 		DefaultDuration: 1 * time.Millisecond,
 		Size:            10,
@@ -40,10 +62,27 @@ func TestFoodAPI(t *testing.T) {
 		t.Fatalf("\t%s\tShould be able to create cache instance", tests.Failed)
 	}
 	tests := FoodAPITests{
-		app: handlers.API("develop", shutdown, test.Log, test.DB, fdcClient, cacheClient),
+		app: handlers.API("develop", shutdown, test.Log, test.DB, providers, storage.DefaultRankPolicy, carbohydrates.DefaultPolicy, bolus.Config{Increment: bolus.DefaultIncrement}, cacheClient),
+		db:  test.DB,
+		srv: srv,
 	}
 
 	t.Run("postSearch200", tests.postSearch200)
+	t.Run("getSearchCriteria400", tests.getSearchCriteria400)
+	t.Run("getDetails200", tests.getDetails200)
+	t.Run("getDetailsServing200", tests.getDetailsServing200)
+	t.Run("getDetailsBatch200", tests.getDetailsBatch200)
+	t.Run("getDetailsNutrients200", tests.getDetailsNutrients200)
+	t.Run("getDetailsGrams200", tests.getDetailsGrams200)
+	t.Run("getDetailsUnits200", tests.getDetailsUnits200)
+	t.Run("getDetailsGlycemic200", tests.getDetailsGlycemic200)
+	t.Run("postBolus200", tests.postBolus200)
+	t.Run("getIOB200", tests.getIOB200)
+	t.Run("postMealsCalculate200", tests.postMealsCalculate200)
+	t.Run("crudRecipes", tests.crudRecipes)
+	t.Run("getSearchProviders200", tests.getSearchProviders200)
+	t.Run("getBarcode200", tests.getBarcode200)
+	t.Run("getBarcode400", tests.getBarcode400)
 
 }
 
@@ -70,6 +109,645 @@ func (ft *FoodAPITests) postSearch200(t *testing.T) {
 	}
 }
 
+func (ft *FoodAPITests) getSearchCriteria400(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/search/cheese?data_types=Junk&page=0&sort_direction=up", nil)
+	w := httptest.NewRecorder()
+
+	ft.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate search criteria.")
+	{
+		t.Log("\tTest 0:\tWhen using invalid search query parameters.")
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould receive a status code of 400 for the response : %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 400 for the response.", tests.Success)
+
+		var resp web.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		if len(resp.Fields) != 3 {
+			t.Fatalf("\t%s\tShould report every invalid field : %+v", tests.Failed, resp.Fields)
+		}
+		t.Logf("\t%s\tShould report every invalid field.", tests.Success)
+	}
+}
+
+func (ft *FoodAPITests) getDetails200(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/details/171688", nil)
+	w := httptest.NewRecorder()
+
+	ft.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to get details of the food.")
+	{
+		t.Log("\tTest 0:\tWhen using fdc id known to the external api.")
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response : %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 200 for the response.", tests.Success)
+
+		var resp handlers.DetailsResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		if resp.Amount != 13.81 || len(resp.Portions) != 2 {
+			t.Fatalf("\t%s\tShould get carbohydrates and portions of the food : %+v", tests.Failed, resp)
+		}
+		t.Logf("\t%s\tShould get carbohydrates and portions of the food.", tests.Success)
+
+		c := resp.Carbohydrates
+		if c.Source != carbohydrates.SourceByDifference || c.Fiber == nil || *c.Fiber != 2.4 || c.NetCarbs == nil || *c.NetCarbs != 13.81 {
+			t.Fatalf("\t%s\tShould get fiber and net carbs with the method used : %+v", tests.Failed, c)
+		}
+		t.Logf("\t%s\tShould get fiber and net carbs with the method used.", tests.Success)
+	}
+}
+
+func (ft *FoodAPITests) getDetailsServing200(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/details/1104067", nil)
+	w := httptest.NewRecorder()
+
+	ft.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to get carbohydrates per labelled serving of branded food.")
+	{
+		t.Log("\tTest 0:\tWhen using fdc id of branded food.")
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response : %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 200 for the response.", tests.Success)
+
+		var resp handlers.DetailsResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		s := resp.Serving
+		if s == nil || s.Size != 50 || s.Description != "1 bar" || s.Carbohydrates == nil || s.Carbohydrates.Amount != 32 {
+			t.Fatalf("\t%s\tShould get carbohydrates per labelled serving : %+v", tests.Failed, s)
+		}
+		t.Logf("\t%s\tShould get carbohydrates per labelled serving.", tests.Success)
+	}
+}
+
+func (ft *FoodAPITests) getDetailsNutrients200(t *testing.T) {
+	t.Log("Given the need to get nutrient profile of the food.")
+	{
+		tt := []struct {
+			nutrients string
+			status    int
+			count     int
+		}{
+			{"", http.StatusOK, 0},
+			{"all", http.StatusOK, 7},
+			{"fiber,sugars,protein", http.StatusOK, 3},
+			{"fiber,vitamins", http.StatusBadRequest, 0},
+		}
+		for i, tc := range tt {
+			t.Logf("\tTest %d:\tWhen asking for nutrients %q.", i, tc.nutrients)
+
+			r := httptest.NewRequest("GET", "/v1/details/171688?nutrients="+tc.nutrients, nil)
+			w := httptest.NewRecorder()
+			ft.app.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("\t%s\tShould receive a status code of %d for the response : %v", tests.Failed, tc.status, w.Code)
+			}
+			if tc.status != http.StatusOK {
+				t.Logf("\t%s\tShould receive a status code of %d for the response.", tests.Success, tc.status)
+				continue
+			}
+
+			var resp handlers.DetailsResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+			}
+			if len(resp.Nutrients) != tc.count || resp.Amount != 13.81 {
+				t.Fatalf("\t%s\tShould get %d nutrients and carbohydrates : %+v", tests.Failed, tc.count, resp)
+			}
+			t.Logf("\t%s\tShould get %d nutrients and carbohydrates.", tests.Success, tc.count)
+		}
+	}
+}
+
+func (ft *FoodAPITests) getDetailsGrams200(t *testing.T) {
+	t.Log("Given the need to get carbohydrates in the portion of the food.")
+	{
+		tt := []struct {
+			grams  string
+			status int
+		}{
+			{"182", http.StatusOK},
+			{"-5", http.StatusBadRequest},
+			{"NaN", http.StatusBadRequest},
+			{"Inf", http.StatusBadRequest},
+		}
+		for i, tc := range tt {
+			t.Logf("\tTest %d:\tWhen asking for %q grams.", i, tc.grams)
+
+			r := httptest.NewRequest("GET", "/v1/details/171688?grams="+tc.grams, nil)
+			w := httptest.NewRecorder()
+			ft.app.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("\t%s\tShould receive a status code of %d for the response : %v", tests.Failed, tc.status, w.Code)
+			}
+			if tc.status != http.StatusOK {
+				t.Logf("\t%s\tShould receive a status code of %d for the response.", tests.Success, tc.status)
+				continue
+			}
+
+			var resp handlers.DetailsResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+			}
+			if resp.BasisGrams != 100 || resp.Weight == nil || resp.Weight.Carbohydrates.Amount != 25.13 {
+				t.Fatalf("\t%s\tShould get carbohydrates in the weight : %+v", tests.Failed, resp.Weight)
+			}
+			if p := resp.Portions[1]; p.Carbohydrates == nil || p.Carbohydrates.Amount != 25.13 || *p.Carbohydrates.NetCarbs != 25.13 {
+				t.Fatalf("\t%s\tShould get carbohydrates in every portion : %+v", tests.Failed, resp.Portions)
+			}
+			t.Logf("\t%s\tShould get carbohydrates in the weight and every portion.", tests.Success)
+
+			if resp.FPU == nil || resp.Weight.FPU == nil || resp.Portions[1].FPU == nil || resp.Weight.FPU.Kcal != 4.68 || resp.Weight.FPU.ExtendedHours != 0 {
+				t.Fatalf("\t%s\tShould get fat-protein units in the weight and every portion : %+v", tests.Failed, resp.Weight.FPU)
+			}
+			t.Logf("\t%s\tShould get fat-protein units in the weight and every portion.", tests.Success)
+		}
+	}
+}
+
+func (ft *FoodAPITests) getDetailsUnits200(t *testing.T) {
+	t.Log("Given the need to get carbohydrates in exchange units.")
+	{
+		tt := []struct {
+			query  string
+			status int
+			amount float64
+		}{
+			{"units=xe", http.StatusOK, 1.15},
+			{"units=xe&unit_grams=10", http.StatusOK, 1.38},
+			{"units=xe&unit_grams=100", http.StatusBadRequest, 0},
+			{"units=xe&unit_grams=NaN", http.StatusBadRequest, 0},
+			{"units=oz", http.StatusBadRequest, 0},
+		}
+		for i, tc := range tt {
+			t.Logf("\tTest %d:\tWhen asking for %q.", i, tc.query)
+
+			r := httptest.NewRequest("GET", "/v1/details/171688?"+tc.query, nil)
+			w := httptest.NewRecorder()
+			ft.app.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("\t%s\tShould receive a status code of %d for the response : %v", tests.Failed, tc.status, w.Code)
+			}
+			if tc.status != http.StatusOK {
+				t.Logf("\t%s\tShould receive a status code of %d for the response.", tests.Success, tc.status)
+				continue
+			}
+
+			var resp handlers.DetailsResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+			}
+			if u := resp.Units; u == nil || u.Unit != carbohydrates.XE || u.Amount != tc.amount {
+				t.Fatalf("\t%s\tShould get %g units of carbohydrates : %+v", tests.Failed, tc.amount, u)
+			}
+			if p := resp.Portions[1].Carbohydrates; p == nil || p.Units == nil {
+				t.Fatalf("\t%s\tShould get units of carbohydrates in every portion : %+v", tests.Failed, resp.Portions)
+			}
+			t.Logf("\t%s\tShould get %g units of carbohydrates.", tests.Success, tc.amount)
+		}
+	}
+}
+
+func (ft *FoodAPITests) getDetailsGlycemic200(t *testing.T) {
+	gi := storage.GlycemicIndex{FoodCategory: "Apples", GI: 36, Source: "test tables"}
+	if err := storage.SaveGlycemicIndexes(tests.Context(), ft.db, []storage.GlycemicIndex{gi}); err != nil {
+		t.Fatalf("\t%s\tShould be able to save glycemic index : %v", tests.Failed, err)
+	}
+
+	r := httptest.NewRequest("GET", "/v1/details/2345173", nil)
+	w := httptest.NewRecorder()
+
+	ft.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to know how fast carbohydrates of the food are.")
+	{
+		t.Log("\tTest 0:\tWhen glycemic index is known for the category of the food.")
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response : %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 200 for the response.", tests.Success)
+
+		var resp handlers.DetailsResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		g := resp.Glycemic
+		if g == nil || g.Index != 36 || g.Category != carbohydrates.GlycemicLow || g.Match != carbohydrates.MatchCategory || g.Source != gi.Source {
+			t.Fatalf("\t%s\tShould get glycemic index with its provenance : %+v", tests.Failed, g)
+		}
+		if g.Load == nil || *g.Load != 4.1 {
+			t.Fatalf("\t%s\tShould get glycemic load of the food : %+v", tests.Failed, g)
+		}
+		t.Logf("\t%s\tShould get glycemic index and load with its provenance.", tests.Success)
+	}
+}
+
+func (ft *FoodAPITests) getDetailsBatch200(t *testing.T) {
+	t.Log("Given the need to get details of several foods at once.")
+	{
+		tt := []struct {
+			method   string
+			target   string
+			body     string
+			fail     bool
+			status   int
+			found    int
+			notFound int
+			failed   int
+		}{
+			{"GET", "/v1/details?ids=171688,1", "", false, http.StatusOK, 1, 1, 0},
+			{"POST", "/v1/details", `{"ids":[3,5]}`, true, http.StatusOK, 0, 0, 2},
+			{"GET", "/v1/details?ids=abc", "", false, http.StatusBadRequest, 0, 0, 0},
+			{"POST", "/v1/details", `{"ids":[]}`, false, http.StatusBadRequest, 0, 0, 0},
+		}
+		for i, tc := range tt {
+			t.Logf("\tTest %d:\tWhen asking for %s %s %s.", i, tc.method, tc.target, tc.body)
+
+			if tc.fail {
+				ft.srv.FailNext(http.StatusBadRequest, 1)
+			}
+
+			r := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			ft.app.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("\t%s\tShould receive a status code of %d for the response : %v", tests.Failed, tc.status, w.Code)
+			}
+			if tc.status != http.StatusOK {
+				t.Logf("\t%s\tShould receive a status code of %d for the response.", tests.Success, tc.status)
+				continue
+			}
+
+			var resp handlers.DetailsBatchResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+			}
+			found := 0
+			for _, item := range resp.Foods {
+				if item.Found {
+					found++
+				}
+			}
+			if found != tc.found || len(resp.NotFound) != tc.notFound || len(resp.Failed) != tc.failed {
+				t.Fatalf("\t%s\tShould tell found, missing and failed foods apart : %+v", tests.Failed, resp)
+			}
+			t.Logf("\t%s\tShould tell found, missing and failed foods apart.", tests.Success)
+		}
+	}
+}
+
+func (ft *FoodAPITests) postBolus200(t *testing.T) {
+	t.Log("Given the need to calculate insulin dose for the meal.")
+	{
+		tt := []struct {
+			body   string
+			status int
+			carbs  float64
+			total  float64
+		}{
+			{`{"foods":[{"fdc_id":171688,"grams":182}],"carb_ratio":10,"correction_factor":40,"glucose":180,"target_glucose":120}`, http.StatusOK, 25.13, 4},
+			{`{"carbs":45,"carb_ratio":12,"insulin_on_board":1,"increment":0.1}`, http.StatusOK, 45, 3.7},
+			{`{"carb_ratio":10}`, http.StatusBadRequest, 0, 0},
+			{`{"carbs":45,"carb_ratio":12,"glucose":180}`, http.StatusBadRequest, 0, 0},
+		}
+		for i, tc := range tt {
+			t.Logf("\tTest %d:\tWhen asking for the dose of %s.", i, tc.body)
+
+			r := httptest.NewRequest("POST", "/v1/bolus", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			ft.app.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("\t%s\tShould receive a status code of %d for the response : %v", tests.Failed, tc.status, w.Code)
+			}
+			if tc.status != http.StatusOK {
+				t.Logf("\t%s\tShould receive a status code of %d for the response.", tests.Success, tc.status)
+				continue
+			}
+
+			var resp handlers.BolusResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+			}
+			if resp.Carbs != tc.carbs || resp.Total != tc.total || len(resp.Breakdown) == 0 {
+				t.Fatalf("\t%s\tShould get %g units for %g g of carbohydrates : %+v", tests.Failed, tc.total, tc.carbs, resp)
+			}
+			t.Logf("\t%s\tShould get %g units for %g g of carbohydrates.", tests.Success, tc.total, tc.carbs)
+		}
+	}
+}
+
+func (ft *FoodAPITests) getIOB200(t *testing.T) {
+	t.Log("Given the need to know insulin on board of the doses given before.")
+	{
+		tt := []struct {
+			query  string
+			status int
+			iob    float64
+		}{
+			{"at=2020-01-01T12:00:00Z&doses=4@2020-01-01T06:00:00Z,5@2020-01-01T12:00:00Z", http.StatusOK, 5},
+			{"at=2020-01-01T12:00:00Z&insulin=fiasp&model=bilinear&doses=2@2020-01-01T09:30:00Z", http.StatusOK, 0.61},
+			{"doses=4", http.StatusBadRequest, 0},
+			{"insulin=insulatard", http.StatusBadRequest, 0},
+		}
+		for i, tc := range tt {
+			t.Logf("\tTest %d:\tWhen asking for insulin on board with %s.", i, tc.query)
+
+			r := httptest.NewRequest("GET", "/v1/iob?"+tc.query, nil)
+			w := httptest.NewRecorder()
+			ft.app.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("\t%s\tShould receive a status code of %d for the response : %v", tests.Failed, tc.status, w.Code)
+			}
+			if tc.status != http.StatusOK {
+				t.Logf("\t%s\tShould receive a status code of %d for the response.", tests.Success, tc.status)
+				continue
+			}
+
+			var resp handlers.IOBResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+			}
+			if resp.InsulinOnBoard != tc.iob {
+				t.Fatalf("\t%s\tShould get %g units on board : %+v", tests.Failed, tc.iob, resp)
+			}
+			t.Logf("\t%s\tShould get %g units on board.", tests.Success, tc.iob)
+		}
+	}
+}
+
+func (ft *FoodAPITests) postMealsCalculate200(t *testing.T) {
+	t.Log("Given the need to know nutrition of the meal.")
+	{
+		tt := []struct {
+			body       string
+			status     int
+			carbs      float64
+			energy     float64
+			unresolved int
+		}{
+			{`{"items":[{"fdc_id":171688,"grams":182},{"fdc_id":171688,"portion":"slice"}]}`, http.StatusOK, 25.13, 94.64, 1},
+			{`{"items":[{"fdc_id":171688,"grams":91},{"fdc_id":171688,"grams":91}]}`, http.StatusOK, 25.14, 94.64, 0},
+			{`{"items":[{"fdc_id":171688}]}`, http.StatusBadRequest, 0, 0, 0},
+			{`{"items":[]}`, http.StatusBadRequest, 0, 0, 0},
+		}
+		for i, tc := range tt {
+			t.Logf("\tTest %d:\tWhen asking for nutrition of %s.", i, tc.body)
+
+			r := httptest.NewRequest("POST", "/v1/meals/calculate", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			ft.app.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("\t%s\tShould receive a status code of %d for the response : %v", tests.Failed, tc.status, w.Code)
+			}
+			if tc.status != http.StatusOK {
+				t.Logf("\t%s\tShould receive a status code of %d for the response.", tests.Success, tc.status)
+				continue
+			}
+
+			var resp handlers.MealResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+			}
+			if resp.Total.Carbs != tc.carbs || resp.Total.Energy == nil || *resp.Total.Energy != tc.energy || resp.FPU == nil {
+				t.Fatalf("\t%s\tShould get %g g of carbohydrates and %g kcal in total : %+v", tests.Failed, tc.carbs, tc.energy, resp.Total)
+			}
+			if len(resp.Unresolved) != tc.unresolved || !resp.Items[0].Resolved {
+				t.Fatalf("\t%s\tShould flag %d items which could not be resolved : %+v", tests.Failed, tc.unresolved, resp.Items)
+			}
+			if resp.Partial || resp.Total.NetCarbs == nil || resp.Total.Fat == nil || resp.Total.Protein == nil {
+				t.Fatalf("\t%s\tShould get every value in the total of foods which state them : %+v", tests.Failed, resp.Total)
+			}
+			t.Logf("\t%s\tShould get %g g of carbohydrates and %g kcal in total.", tests.Success, tc.carbs, tc.energy)
+		}
+	}
+}
+
+func (ft *FoodAPITests) crudRecipes(t *testing.T) {
+	t.Log("Given the need to keep recipes of home-cooked dishes.")
+	{
+		body := `{"name":"Baked apples","ingredients":[{"fdc_id":171688,"grams":300}],"yield_grams":250,"servings":2}`
+
+		t.Log("\tTest 0:\tWhen creating the recipe.")
+		r := httptest.NewRequest("POST", "/v1/recipes", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		ft.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("\t%s\tShould receive a status code of 201 for the response : %v", tests.Failed, w.Code)
+		}
+		var created handlers.RecipeResponse
+		if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		if created.FDCID < storage.RecipeIDBase || created.ServingGrams != 125 || created.Per100g == nil || created.Per100g.Carbs != 16.57 || created.PerServing == nil {
+			t.Fatalf("\t%s\tShould get nutrition per 100 g and per serving : %+v", tests.Failed, created)
+		}
+		t.Logf("\t%s\tShould get nutrition per 100 g and per serving.", tests.Success)
+
+		id := strconv.Itoa(created.FDCID)
+
+		t.Log("\tTest 1:\tWhen asking for details of the recipe.")
+		r = httptest.NewRequest("GET", "/v1/details/"+id, nil)
+		w = httptest.NewRecorder()
+		ft.app.ServeHTTP(w, r)
+
+		var details handlers.DetailsResponse
+		if err := json.NewDecoder(w.Body).Decode(&details); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould get details of the recipe like of any food : %v", tests.Failed, w.Code)
+		}
+		if details.Provider != storage.RecipeProvider || details.Source == "" || len(details.Portions) != 2 {
+			t.Fatalf("\t%s\tShould get details of the recipe like of any food : %+v", tests.Failed, details)
+		}
+		t.Logf("\t%s\tShould get details of the recipe like of any food.", tests.Success)
+
+		t.Log("\tTest 2:\tWhen searching and updating the recipe.")
+		r = httptest.NewRequest("GET", "/v1/recipes?search=baked", nil)
+		w = httptest.NewRecorder()
+		ft.app.ServeHTTP(w, r)
+
+		var list handlers.RecipesResponse
+		if err := json.NewDecoder(w.Body).Decode(&list); err != nil || len(list.Recipes) != 1 || list.Recipes[0].FDCID != created.FDCID {
+			t.Fatalf("\t%s\tShould find the recipe by name : %+v", tests.Failed, list)
+		}
+
+		body = `{"name":"Baked apples","ingredients":[{"fdc_id":171688,"grams":300}],"servings":3}`
+		r = httptest.NewRequest("PUT", "/v1/recipes/"+id, strings.NewReader(body))
+		w = httptest.NewRecorder()
+		ft.app.ServeHTTP(w, r)
+
+		var updated handlers.RecipeResponse
+		if err := json.NewDecoder(w.Body).Decode(&updated); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould be able to update the recipe : %v", tests.Failed, w.Code)
+		}
+		if updated.YieldGrams != 300 || updated.ServingGrams != 100 || updated.Per100g.Carbs != 13.81 {
+			t.Fatalf("\t%s\tShould compute nutrition of the recipe again : %+v", tests.Failed, updated)
+		}
+		t.Logf("\t%s\tShould find the recipe and compute its nutrition again.", tests.Success)
+
+		t.Log("\tTest 3:\tWhen deleting the recipe.")
+		r = httptest.NewRequest("DELETE", "/v1/recipes/"+id, nil)
+		w = httptest.NewRecorder()
+		ft.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("\t%s\tShould receive a status code of 204 for the response : %v", tests.Failed, w.Code)
+		}
+
+		r = httptest.NewRequest("GET", "/v1/recipes/"+id, nil)
+		w = httptest.NewRecorder()
+		ft.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("\t%s\tShould not find the deleted recipe : %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould be able to delete the recipe.", tests.Success)
+
+		t.Log("\tTest 4:\tWhen ingredients are imported from the dataset and fetched from the api.")
+		ctx := tests.Context()
+		for _, id := range []int{9000001, 9000002} {
+			food := storage.Food{FDCID: id, Description: "imported food " + strconv.Itoa(id), Provider: provider.FDC}
+			if err := storage.SaveFood(ctx, ft.db, food); err != nil {
+				t.Fatalf("\t%s\tShould be able to save imported food : %v", tests.Failed, err)
+			}
+			if err := storage.SaveDetails(ctx, ft.db, id, storage.Carbohydrates{Amount: 50, UnitName: "g"}, nil); err != nil {
+				t.Fatalf("\t%s\tShould be able to save imported food : %v", tests.Failed, err)
+			}
+		}
+		summation := []storage.FoodNutrient{{Amount: 50, Nutrient: storage.Nutrient{Name: "Carbohydrate, by summation", UnitName: "g"}}}
+		if err := storage.SaveNutrients(ctx, ft.db, 9000001, summation); err != nil {
+			t.Fatalf("\t%s\tShould be able to save imported nutrients : %v", tests.Failed, err)
+		}
+
+		body = `{"name":"Apple pie","ingredients":[{"fdc_id":171688,"grams":300},{"fdc_id":9000001,"grams":100}]}`
+		r = httptest.NewRequest("POST", "/v1/recipes", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		ft.app.ServeHTTP(w, r)
+
+		var mixed handlers.RecipeResponse
+		if err := json.NewDecoder(w.Body).Decode(&mixed); err != nil || w.Code != http.StatusCreated {
+			t.Fatalf("\t%s\tShould be able to create the recipe : %v", tests.Failed, w.Code)
+		}
+		if mixed.Per100g.Carbs != 22.86 {
+			t.Fatalf("\t%s\tShould count carbohydrates of every ingredient : %+v", tests.Failed, mixed.Per100g)
+		}
+		t.Logf("\t%s\tShould count carbohydrates of every ingredient.", tests.Success)
+
+		body = `{"name":"Apple pie","ingredients":[{"fdc_id":171688,"grams":300},{"fdc_id":9000002,"grams":100}]}`
+		r = httptest.NewRequest("POST", "/v1/recipes", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		ft.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould not accept the ingredient without nutrient profile : %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould not accept the ingredient without nutrient profile.", tests.Success)
+	}
+}
+
+func (ft *FoodAPITests) getSearchProviders200(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/search/mars?data_types=Branded", nil)
+	w := httptest.NewRecorder()
+
+	ft.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to search every food provider.")
+	{
+		t.Log("\tTest 0:\tWhen the food is known to several providers.")
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response : %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 200 for the response.", tests.Success)
+
+		var resp handlers.SearchResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+
+		// The bar known to both providers is taken from Food Data Central.
+		if len(resp.Products) != 2 || resp.Products[0].Provider != provider.FDC || resp.Products[1].Provider != provider.OpenFoodFacts {
+			t.Fatalf("\t%s\tShould merge products in priority order of the providers : %+v", tests.Failed, resp.Products)
+		}
+		t.Logf("\t%s\tShould merge products in priority order of the providers.", tests.Success)
+
+		if p := resp.Products[0]; p.DataType != "Branded" || p.PublishedDate != "2020-11-13" || p.FoodCategory != "Candy" {
+			t.Fatalf("\t%s\tShould expose data type and publication metadata : %+v", tests.Failed, p)
+		}
+		t.Logf("\t%s\tShould expose data type and publication metadata.", tests.Success)
+	}
+}
+
+func (ft *FoodAPITests) getBarcode200(t *testing.T) {
+	t.Log("Given the need to look the food up by its barcode.")
+	{
+		table := []struct {
+			barcode     string
+			description string
+			provider    string
+		}{
+			{"0040000002635", "MARS CHOCOLATE BAR", provider.FDC},
+			{"3017620422003", "Nutella", provider.OpenFoodFacts},
+		}
+
+		for i, tt := range table {
+			t.Logf("\tTest %d:\tWhen using barcode %s.", i, tt.barcode)
+
+			r := httptest.NewRequest("GET", "/v1/barcode/"+tt.barcode, nil)
+			w := httptest.NewRecorder()
+
+			ft.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tShould receive a status code of 200 for the response : %v", tests.Failed, w.Code)
+			}
+
+			var resp handlers.DetailsResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+			}
+			if resp.Description != tt.description || resp.Provider != tt.provider || resp.Amount == 0 {
+				t.Fatalf("\t%s\tShould get details of the food from %s : %+v", tests.Failed, tt.provider, resp)
+			}
+			t.Logf("\t%s\tShould get details of the food from %s.", tests.Success, tt.provider)
+		}
+	}
+}
+
+func (ft *FoodAPITests) getBarcode400(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/barcode/0040000002636", nil)
+	w := httptest.NewRecorder()
+
+	ft.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate barcodes.")
+	{
+		t.Log("\tTest 0:\tWhen using barcode with wrong check digit.")
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould receive a status code of 400 for the response : %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 400 for the response.", tests.Success)
+	}
+}
+
 type FoodAPITests struct {
 	app http.Handler
+	db  *sqlx.DB
+	srv *fdctest.Server
 }
@@ -0,0 +1,154 @@
+// Package bolus calculates the insulin dose for the meal, the bolus. The dose
+// covers carbohydrates of the meal by insulin-to-carb ratio and brings glucose
+// to the target by correction factor, less insulin still active from the doses
+// before.
+package bolus
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultIncrement is the dose step of the most of insulin pens, in units.
+const DefaultIncrement = 0.5
+
+// Terms of the breakdown of the dose.
+const (
+	TermMeal           = "meal"
+	TermCorrection     = "correction"
+	TermInsulinOnBoard = "insulin_on_board"
+	TermMinimum        = "minimum"
+	TermRounding       = "rounding"
+)
+
+// Config is the dosing setup the doses are calculated for.
+type Config struct {
+	// Increment is the dose step of the pen or pump in units, doses are
+	// rounded down to it.
+	Increment float64
+}
+
+// Input is what the dose is calculated from. Glucose values are in the same
+// unit, mg/dL or mmol/L, as CorrectionFactor is.
+type Input struct {
+	// Carbs is grams of carbohydrates of the meal and CarbRatio is grams of
+	// carbohydrates one unit of insulin covers.
+	Carbs     float64
+	CarbRatio float64
+
+	// CorrectionFactor is the drop of glucose one unit of insulin gives.
+	// Correction is not calculated when Glucose is zero.
+	CorrectionFactor float64
+	Glucose          float64
+	Target           float64
+
+	// InsulinOnBoard is units of insulin still active from doses before.
+	InsulinOnBoard float64
+}
+
+// Term is one term of the dose with the explanation of how it was got.
+type Term struct {
+	Name        string  `json:"name"`
+	Units       float64 `json:"units"`
+	Explanation string  `json:"explanation"`
+}
+
+// Dose is the calculated bolus in units of insulin.
+type Dose struct {
+	Meal       float64 `json:"meal"`
+	Correction float64 `json:"correction"`
+
+	// InsulinOnBoard is units of insulin on board subtracted from the
+	// correction, which is never more than the correction itself.
+	InsulinOnBoard float64 `json:"insulin_on_board"`
+
+	// Total is the dose to give, rounded down to Increment and never below
+	// zero.
+	Total     float64 `json:"total"`
+	Increment float64 `json:"increment"`
+
+	Breakdown []Term `json:"breakdown"`
+}
+
+// Calculate calculates the dose. Insulin on board is subtracted from the
+// correction only, as it is still covering carbohydrates eaten before, and
+// glucose below the target takes insulin off the meal dose.
+func (c Config) Calculate(in Input) (Dose, error) {
+	if err := in.validate(); err != nil {
+		return Dose{}, err
+	}
+	increment := c.Increment
+	if increment <= 0 {
+		increment = DefaultIncrement
+	}
+
+	d := Dose{Increment: increment}
+
+	if in.Carbs > 0 {
+		d.Meal = in.Carbs / in.CarbRatio
+		d.add(TermMeal, d.Meal, "%g g carbohydrates / %g g per unit", in.Carbs, in.CarbRatio)
+	}
+
+	if in.Glucose > 0 {
+		d.Correction = (in.Glucose - in.Target) / in.CorrectionFactor
+		d.add(TermCorrection, d.Correction, "(%g glucose - %g target) / %g per unit", in.Glucose, in.Target, in.CorrectionFactor)
+	}
+
+	if in.InsulinOnBoard > 0 {
+		d.InsulinOnBoard = math.Min(in.InsulinOnBoard, math.Max(d.Correction, 0))
+		d.add(TermInsulinOnBoard, -d.InsulinOnBoard, "%g units on board, subtracted from correction only", in.InsulinOnBoard)
+	}
+
+	exact := d.Meal + d.Correction - d.InsulinOnBoard
+	if exact < 0 {
+		d.add(TermMinimum, -exact, "%g units is below zero, no insulin is given", round(exact))
+		exact = 0
+	}
+
+	// Doses of whole steps can be a bit below them in floating point, e.g.
+	// 0.3 / 0.1, the epsilon keeps them from losing a step without giving
+	// steps the dose is short of.
+	d.Total = math.Floor(exact/increment+1e-9) * increment
+	d.Total = round(d.Total)
+	d.add(TermRounding, d.Total-exact, "%g units rounded down to %g unit step", round(exact), increment)
+
+	d.Meal = round(d.Meal)
+	d.Correction = round(d.Correction)
+	d.InsulinOnBoard = round(d.InsulinOnBoard)
+	return d, nil
+}
+
+// add adds the term to the breakdown of the dose.
+func (d *Dose) add(name string, units float64, format string, args ...interface{}) {
+	d.Breakdown = append(d.Breakdown, Term{
+		Name:        name,
+		Units:       round(units),
+		Explanation: fmt.Sprintf(format, args...),
+	})
+}
+
+// validate tells whether the dose can be calculated from the input.
+func (in Input) validate() error {
+	switch {
+	case in.Carbs < 0:
+		return errors.New("carbs must not be negative")
+	case in.Carbs > 0 && in.CarbRatio <= 0:
+		return errors.New("carb ratio must be positive")
+	case in.Glucose < 0:
+		return errors.New("glucose must not be negative")
+	case in.Glucose > 0 && in.CorrectionFactor <= 0:
+		return errors.New("correction factor must be positive")
+	case in.Glucose > 0 && in.Target <= 0:
+		return errors.New("target glucose must be positive")
+	case in.InsulinOnBoard < 0:
+		return errors.New("insulin on board must not be negative")
+	}
+	return nil
+}
+
+// round rounds the units to 2 decimal places.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,74 @@
+package bolus
+
+import (
+	"testing"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestCalculate(t *testing.T) {
+	t.Log("Given the need to calculate the insulin dose for the meal.")
+	{
+		tt := []struct {
+			name       string
+			cfg        Config
+			in         Input
+			meal       float64
+			correction float64
+			iob        float64
+			total      float64
+		}{
+			{"meal only", Config{}, Input{Carbs: 60, CarbRatio: 10}, 6, 0, 0, 6},
+			{"meal and correction", Config{Increment: 0.5}, Input{Carbs: 45, CarbRatio: 12, Glucose: 200, Target: 120, CorrectionFactor: 40}, 3.75, 2, 0, 5.5},
+			{"insulin on board", Config{Increment: 0.1}, Input{Carbs: 45, CarbRatio: 12, Glucose: 11, Target: 6, CorrectionFactor: 2.5, InsulinOnBoard: 1.2}, 3.75, 2, 1.2, 4.5},
+			{"insulin on board over correction", Config{Increment: 0.1}, Input{Carbs: 30, CarbRatio: 10, Glucose: 7, Target: 6, CorrectionFactor: 2, InsulinOnBoard: 3}, 3, 0.5, 0.5, 3},
+			{"glucose below target", Config{Increment: 0.05}, Input{Carbs: 30, CarbRatio: 10, Glucose: 4, Target: 6, CorrectionFactor: 2, InsulinOnBoard: 1}, 3, -1, 0, 2},
+			{"low glucose without meal", Config{}, Input{Glucose: 3, Target: 6, CorrectionFactor: 2}, 0, -1.5, 0, 0},
+			{"just short of the step", Config{Increment: 0.5}, Input{Carbs: 14.98, CarbRatio: 10}, 1.5, 0, 0, 1},
+		}
+		for i, tc := range tt {
+			t.Logf("\tTest %d:\tWhen calculating %s.", i, tc.name)
+			{
+				d, err := tc.cfg.Calculate(tc.in)
+				if err != nil {
+					t.Fatalf("\t%s\tShould be able to calculate the dose : %s.", failed, err)
+				}
+				if d.Meal != tc.meal || d.Correction != tc.correction || d.InsulinOnBoard != tc.iob || d.Total != tc.total {
+					t.Fatalf("\t%s\tShould get %g meal, %g correction, %g on board and %g total : %+v.", failed, tc.meal, tc.correction, tc.iob, tc.total, d)
+				}
+
+				var sum float64
+				for _, term := range d.Breakdown {
+					if term.Explanation == "" {
+						t.Fatalf("\t%s\tShould explain every term : %+v.", failed, term)
+					}
+					sum += term.Units
+				}
+				if round(sum) != d.Total {
+					t.Fatalf("\t%s\tShould get the total as the sum of the terms : %+v.", failed, d.Breakdown)
+				}
+				t.Logf("\t%s\tShould get %g units explained by the breakdown.", success, tc.total)
+			}
+		}
+
+		t.Log("\tWhen the input is not valid.")
+		{
+			inputs := []Input{
+				{Carbs: 60},
+				{Carbs: -1, CarbRatio: 10},
+				{Glucose: 180, Target: 120},
+				{Glucose: 180, CorrectionFactor: 40},
+				{InsulinOnBoard: -1},
+			}
+			for _, in := range inputs {
+				if _, err := (Config{}).Calculate(in); err == nil {
+					t.Fatalf("\t%s\tShould not calculate the dose for %+v.", failed, in)
+				}
+			}
+			t.Logf("\t%s\tShould not calculate the dose.", success)
+		}
+	}
+}
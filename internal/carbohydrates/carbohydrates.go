@@ -1,51 +1,208 @@
 package carbohydrates
 
 import (
+	"fmt"
+	"math"
 	"strings"
 
-	"github.com/igomonov88/sugar/internal/fdc"
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/sugar/internal/nutrients"
+	"github.com/igomonov88/sugar/internal/provider"
+)
+
+// Sources of the total carbohydrates, telling which nutrient of the food the
+// amount is.
+const (
+	// SourceByDifference is carbohydrate, by difference, which FoodData
+	// Central gives. It includes fiber and sugar alcohols.
+	SourceByDifference = "carbohydrate, by difference"
+
+	// SourceBySummation is carbohydrate, by summation, the sum of sugars and
+	// starch some foods of FoodData Central have instead.
+	SourceBySummation = "carbohydrate, by summation"
+
+	// SourceAvailable is available carbohydrates, which labels in Europe
+	// state. They exclude fiber, but include sugar alcohols.
+	SourceAvailable = "carbohydrates"
+
+	// SourceIngredients is the sum of total carbohydrates of the ingredients
+	// of the recipe, whichever of the nutrients above every one of them has.
+	SourceIngredients = "sum of ingredients"
 )
 
+// Carbohydrates are carbohydrates of the food, in UnitName.
 type Carbohydrates struct {
+	// Amount is total carbohydrates, the nutrient named by Source.
 	Amount   float64 `json:"amount"`
 	UnitName string  `json:"unit_name"`
+	Source   string  `json:"source,omitempty"`
+
+	// Fiber, Sugars, AddedSugars and SugarAlcohols are nil when the food
+	// does not state them.
+	Fiber         *float64 `json:"fiber,omitempty"`
+	Sugars        *float64 `json:"sugars,omitempty"`
+	AddedSugars   *float64 `json:"added_sugars,omitempty"`
+	SugarAlcohols *float64 `json:"sugar_alcohols,omitempty"`
+
+	// NetCarbs is total carbohydrates less fiber and sugar alcohols as the
+	// policy described by Method subtracts them.
+	NetCarbs *float64 `json:"net_carbs,omitempty"`
+	Method   string   `json:"method,omitempty"`
+
+	// Units are the amounts counted in exchange units, set when asked for.
+	Units *Units `json:"units,omitempty"`
+
+	// Glycemic is glycemic index and load, nil when glycemic index of the
+	// food is not known.
+	Glycemic *Glycemic `json:"glycemic,omitempty"`
+
+	// policy is the policy NetCarbs were computed with, ForWeight computes
+	// them again with it for the weight.
+	policy *Policy
 }
 
-func Retrieve(nutrients []fdc.FoodNutrient) Carbohydrates {
-	const (
-		carbohydrates             = "carbohydrates"
-		carbohydratesByDifference = "carbohydrate, by difference"
-	)
+// Policy tells how much of fiber and sugar alcohols is subtracted from total
+// carbohydrates to get net carbs.
+type Policy struct {
+	// Fiber is the share of fiber subtracted, from 0 to 1. Fiber is
+	// subtracted only when there is at least FiberThreshold grams of it.
+	Fiber          float64
+	FiberThreshold float64
 
-	var (
-		carbs             float64
-		carbsByDifference float64
-		unitName          string
-	)
+	// SugarAlcohols is the share of sugar alcohols subtracted, from 0 to 1.
+	SugarAlcohols float64
+}
 
-	for i := range nutrients {
-		name := strings.ToLower(nutrients[i].Nutrient.Name)
+// DefaultPolicy subtracts half of fiber when there is 5 grams of it or more
+// and half of sugar alcohols, as carbohydrate counting for insulin dosing
+// usually does.
+var DefaultPolicy = Policy{
+	Fiber:          0.5,
+	FiberThreshold: 5,
+	SugarAlcohols:  0.5,
+}
 
-		if strings.EqualFold(carbohydrates, name) {
-			carbs = nutrients[i].Amount
-		}
+// Validate returns the error when shares of the policy are not from 0 to 1 or
+// the fiber threshold is negative.
+func (p Policy) Validate() error {
+	if !(p.Fiber >= 0 && p.Fiber <= 1) {
+		return errors.Errorf("fiber share %g must be from 0 to 1", p.Fiber)
+	}
+	if !(p.SugarAlcohols >= 0 && p.SugarAlcohols <= 1) {
+		return errors.Errorf("sugar alcohols share %g must be from 0 to 1", p.SugarAlcohols)
+	}
+	if !(p.FiberThreshold >= 0) || math.IsInf(p.FiberThreshold, 1) {
+		return errors.Errorf("fiber threshold %g must be zero or more grams", p.FiberThreshold)
+	}
+	return nil
+}
 
-		if strings.EqualFold(carbohydratesByDifference, name) {
-			carbsByDifference = nutrients[i].Amount
-		}
+// Retrieve returns carbohydrates of the food computed with the default policy.
+func Retrieve(list []provider.Nutrient) Carbohydrates {
+	return DefaultPolicy.Retrieve(list)
+}
+
+// RetrieveWithTotal returns carbohydrates of the food with given total,
+// computed with the default policy.
+func RetrieveWithTotal(list []provider.Nutrient, amount float64, unitName, source string) Carbohydrates {
+	return DefaultPolicy.RetrieveWithTotal(list, amount, unitName, source)
+}
+
+// Retrieve returns carbohydrates of the food. Total carbohydrates are taken
+// by difference when the food has them, then available and then by
+// summation, and the one taken is reported as the source. Amount and unit of
+// every value are taken from the same nutrient record.
+func (p Policy) Retrieve(list []provider.Nutrient) Carbohydrates {
+	var c Carbohydrates
 
-		unitName = nutrients[i].Nutrient.UnitName
+	sources := []struct {
+		nutrient nutrients.Nutrient
+		source   string
+	}{
+		{nutrients.MustLookup(nutrients.CarbohydrateByDifference), SourceByDifference},
+		{byTag(nutrients.AvailableCarbohydrate), SourceAvailable},
+		{nutrients.MustLookup(nutrients.CarbohydrateBySummation), SourceBySummation},
+	}
+	for _, s := range sources {
+		if n, ok := nutrients.Find(list, s.nutrient); ok {
+			c.Amount, c.UnitName, c.Source = n.Amount, n.UnitName, s.source
+			break
+		}
+	}
+	if c.Source == "" {
+		return c
 	}
+	return p.retrieve(list, c)
+}
+
+// RetrieveWithTotal returns carbohydrates of the food like Retrieve does, but
+// total carbohydrates are given instead of taken from the list. It is meant
+// for recipes, which total is summed from the totals of the ingredients.
+func (p Policy) RetrieveWithTotal(list []provider.Nutrient, amount float64, unitName, source string) Carbohydrates {
+	return p.retrieve(list, Carbohydrates{Amount: amount, UnitName: unitName, Source: source})
+}
+
+// retrieve sets the nutrients of the list the total carbohydrates of c are
+// made of, and computes net carbs.
+func (p Policy) retrieve(list []provider.Nutrient, c Carbohydrates) Carbohydrates {
+	c.Fiber = find(list, nutrients.Fiber, c.UnitName)
+	c.Sugars = find(list, nutrients.Sugars, c.UnitName)
+	c.AddedSugars = find(list, nutrients.AddedSugars, c.UnitName)
+	c.SugarAlcohols = find(list, nutrients.SugarAlcohols, c.UnitName)
+
+	p.net(&c)
+	return c
+}
 
-	if carbsByDifference >= carbs {
-		return Carbohydrates{
-			Amount:   carbsByDifference,
-			UnitName: unitName,
+// net computes net carbs of the carbohydrates. Available carbohydrates have no
+// fiber in them, so it is not subtracted from them.
+func (p Policy) net(c *Carbohydrates) {
+	net := c.Amount
+	var method []string
+
+	if c.Fiber != nil && c.Source != SourceAvailable && p.Fiber > 0 && *c.Fiber >= p.FiberThreshold {
+		net -= *c.Fiber * p.Fiber
+		method = append(method, subtracted(p.Fiber, "fiber"))
+		if p.FiberThreshold > 0 {
+			method[len(method)-1] += fmt.Sprintf(" (%g %s or more)", p.FiberThreshold, c.UnitName)
 		}
 	}
+	if c.SugarAlcohols != nil && p.SugarAlcohols > 0 {
+		net -= *c.SugarAlcohols * p.SugarAlcohols
+		method = append(method, subtracted(p.SugarAlcohols, "sugar alcohols"))
+	}
+
+	net = math.Max(0, round(net))
+	c.NetCarbs = &net
+	c.Method = strings.Join(append([]string{c.Source}, method...), " - ")
+	c.policy = &p
+}
+
+// subtracted describes the share of the nutrient subtracted, e.g. 50% fiber.
+func subtracted(share float64, name string) string {
+	return fmt.Sprintf("%g%% %s", round(share*100), name)
+}
 
-	return Carbohydrates{
-		Amount:   carbs,
-		UnitName: unitName,
+// find returns the amount of the nutrient with given number, when it is
+// measured in given unit.
+func find(list []provider.Nutrient, number, unitName string) *float64 {
+	n, ok := nutrients.Find(list, nutrients.MustLookup(number))
+	if !ok || !strings.EqualFold(n.UnitName, unitName) {
+		return nil
 	}
+	amount := n.Amount
+	return &amount
+}
+
+// byTag returns the nutrient of the registry with given tag.
+func byTag(tag string) nutrients.Nutrient {
+	n, _ := nutrients.ByTag(tag)
+	return n
+}
+
+// round rounds the amount to 2 decimal places, so subtraction does not leave
+// floating point noise in the response.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
 }
@@ -0,0 +1,232 @@
+package carbohydrates
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/igomonov88/sugar/internal/provider"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestRetrieve(t *testing.T) {
+	t.Log("Given the need to retrieve carbohydrates of the food.")
+	{
+		list := []provider.Nutrient{
+			{Number: "205", Name: "Carbohydrate, by difference", Amount: 13.8, UnitName: "g"},
+			{Number: "208", Name: "Energy", Amount: 52, UnitName: "kcal"},
+			{Number: "291", Name: "Fiber, total dietary", Amount: 6, UnitName: "g"},
+			{Number: "269", Name: "Sugars, total including NLEA", Amount: 10.4, UnitName: "g"},
+			{Number: "299", Name: "Total sugar alcohols", Amount: 2, UnitName: "g"},
+			{Number: "307", Name: "Sodium, Na", Amount: 1, UnitName: "mg"},
+		}
+		float := func(v float64) *float64 { return &v }
+
+		t.Log("\tWhen the food has carbohydrates by difference.")
+		{
+			want := Carbohydrates{
+				Amount:        13.8,
+				UnitName:      "g",
+				Source:        SourceByDifference,
+				Fiber:         float(6),
+				Sugars:        float(10.4),
+				SugarAlcohols: float(2),
+				NetCarbs:      float(9.8),
+				Method:        "carbohydrate, by difference - 50% fiber (5 g or more) - 50% sugar alcohols",
+				policy:        &DefaultPolicy,
+			}
+			got := Retrieve(append(list, provider.Nutrient{Name: "Carbohydrates", Amount: 20, UnitName: "g"}))
+			if diff := cmp.Diff(want, got, cmp.AllowUnexported(Carbohydrates{})); diff != "" {
+				t.Fatalf("\t%s\tShould take carbohydrates by difference and subtract by policy: %s", failed, diff)
+			}
+			t.Logf("\t%s\tShould take carbohydrates by difference and subtract by policy.", success)
+		}
+
+		t.Log("\tWhen the policy subtracts whole fiber.")
+		{
+			p := Policy{Fiber: 1}
+			got := p.Retrieve(list)
+			if *got.NetCarbs != 7.8 || got.Method != "carbohydrate, by difference - 100% fiber" {
+				t.Fatalf("\t%s\tShould subtract whole fiber only: %+v", failed, got)
+			}
+			t.Logf("\t%s\tShould subtract whole fiber only.", success)
+		}
+
+		t.Log("\tWhen the food has available carbohydrates only.")
+		{
+			got := Retrieve([]provider.Nutrient{
+				{Name: "Carbohydrates", Amount: 20, UnitName: "g"},
+				{Number: "291", Name: "Fiber, total dietary", Amount: 8, UnitName: "g"},
+			})
+			if got.Source != SourceAvailable || got.Amount != 20 || *got.NetCarbs != 20 || *got.Fiber != 8 {
+				t.Fatalf("\t%s\tShould not subtract fiber from available carbohydrates: %+v", failed, got)
+			}
+			t.Logf("\t%s\tShould not subtract fiber from available carbohydrates.", success)
+		}
+
+		t.Log("\tWhen total carbohydrates of the food are known apart from its nutrients.")
+		{
+			got := RetrieveWithTotal(list, 30, "g", SourceIngredients)
+			if got.Amount != 30 || got.Source != SourceIngredients || *got.Fiber != 6 || *got.NetCarbs != 26 {
+				t.Fatalf("\t%s\tShould take given total and subtract by policy: %+v", failed, got)
+			}
+			t.Logf("\t%s\tShould take given total and subtract by policy.", success)
+		}
+
+		t.Log("\tWhen the food has no carbohydrates.")
+		{
+			if got := Retrieve(list[1:]); !cmp.Equal(got, Carbohydrates{}, cmp.AllowUnexported(Carbohydrates{})) {
+				t.Fatalf("\t%s\tShould return no carbohydrates: %+v", failed, got)
+			}
+			t.Logf("\t%s\tShould return no carbohydrates.", success)
+		}
+	}
+}
+
+func TestForWeight(t *testing.T) {
+	t.Log("Given the need to know carbohydrates in the portion of the food.")
+	{
+		fiber, net := 2.4, 13.81
+		c := Carbohydrates{Amount: 13.81, UnitName: "g", Source: SourceByDifference, Fiber: &fiber, NetCarbs: &net}
+
+		got := c.ForWeight(182)
+		if got.Amount != 25.13 || *got.Fiber != 4.37 || *got.NetCarbs != 25.13 || got.Sugars != nil || got.Source != c.Source {
+			t.Fatalf("\t%s\tShould scale carbohydrates to the weight: %+v", failed, got)
+		}
+		if *c.Fiber != 2.4 {
+			t.Fatalf("\t%s\tShould not change carbohydrates per 100 grams: %+v", failed, c)
+		}
+		t.Logf("\t%s\tShould scale carbohydrates to the weight.", success)
+
+		t.Log("\tWhen the portion has less fiber than the policy threshold.")
+		{
+			c := Retrieve([]provider.Nutrient{
+				{Number: "205", Name: "Carbohydrate, by difference", Amount: 20, UnitName: "g"},
+				{Number: "291", Name: "Fiber, total dietary", Amount: 6, UnitName: "g"},
+			})
+			if *c.NetCarbs != 17 {
+				t.Fatalf("\t%s\tShould subtract fiber per 100 grams: %+v", failed, c)
+			}
+
+			got := c.ForWeight(50)
+			if got.Amount != 10 || *got.Fiber != 3 || *got.NetCarbs != 10 || got.Method != SourceByDifference {
+				t.Fatalf("\t%s\tShould not subtract fiber below the threshold: %+v", failed, got)
+			}
+			t.Logf("\t%s\tShould not subtract fiber below the threshold.", success)
+
+			got = c.ForWeight(200)
+			if got.Amount != 40 || *got.Fiber != 12 || *got.NetCarbs != 34 {
+				t.Fatalf("\t%s\tShould subtract fiber above the threshold: %+v", failed, got)
+			}
+			t.Logf("\t%s\tShould subtract fiber above the threshold.", success)
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	t.Log("Given the need to count carbohydrates in exchange units.")
+	{
+		tt := []struct {
+			unit  string
+			grams float64
+			want  float64
+			err   bool
+		}{
+			{"xe", 0, 2.5, false},
+			{"BE", 10, 3, false},
+			{"cu", 0, 3, false},
+			{"ke", 0, 3, false},
+			{"xe", 40, 0, true},
+			{"xe", math.NaN(), 0, true},
+			{"xe", math.Inf(1), 0, true},
+			{"oz", 0, 0, true},
+		}
+		for i, tc := range tt {
+			t.Logf("\tTest %d:\tWhen counting in %q of %g grams.", i, tc.unit, tc.grams)
+
+			e, err := NewExchange(tc.unit, tc.grams)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("\t%s\tShould not accept the exchange unit: %+v", failed, e)
+				}
+				t.Logf("\t%s\tShould not accept the exchange unit.", success)
+				continue
+			}
+			if err != nil {
+				t.Fatalf("\t%s\tShould accept the exchange unit: %s", failed, err)
+			}
+
+			net := 24.0
+			c := Carbohydrates{Amount: 30, UnitName: "g", NetCarbs: &net}.InUnits(e)
+			if c.Units == nil || c.Units.Amount != tc.want || *c.Units.NetAmount != round(net/e.Grams) {
+				t.Fatalf("\t%s\tShould count %g units: %+v", failed, tc.want, c.Units)
+			}
+			t.Logf("\t%s\tShould count %g units.", success, tc.want)
+		}
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	t.Log("Given the need to reject policies which can not compute net carbs.")
+	{
+		tt := []struct {
+			name   string
+			policy Policy
+			err    bool
+		}{
+			{"default", DefaultPolicy, false},
+			{"no subtraction", Policy{}, false},
+			{"whole fiber", Policy{Fiber: 1, SugarAlcohols: 1}, false},
+			{"fiber above 1", Policy{Fiber: 1.5}, true},
+			{"negative sugar alcohols", Policy{SugarAlcohols: -0.5}, true},
+			{"NaN fiber", Policy{Fiber: math.NaN()}, true},
+			{"negative fiber threshold", Policy{Fiber: 0.5, FiberThreshold: -1}, true},
+		}
+		for i, tc := range tt {
+			t.Logf("\tTest %d:\tWhen validating %s policy.", i, tc.name)
+
+			err := tc.policy.Validate()
+			if tc.err != (err != nil) {
+				t.Fatalf("\t%s\tShould reject only invalid policies: %v", failed, err)
+			}
+			t.Logf("\t%s\tShould reject only invalid policies.", success)
+		}
+	}
+}
+
+func TestGlycemic(t *testing.T) {
+	t.Log("Given the need to know how fast carbohydrates of the food are.")
+	{
+		fiber := 2.4
+		c := Carbohydrates{Amount: 13.81, UnitName: "g", Source: SourceByDifference, Fiber: &fiber}
+
+		got := c.WithGlycemic(36, "tables", MatchExact).Glycemic
+		if got.Category != GlycemicLow || got.Load == nil || *got.Load != 4.11 || got.LoadCategory != GlycemicLow {
+			t.Fatalf("\t%s\tShould compute glycemic load of available carbohydrates: %+v", failed, got)
+		}
+		t.Logf("\t%s\tShould compute glycemic load of available carbohydrates.", success)
+
+		got = c.WithGlycemic(36, "tables", MatchExact).ForWeight(500).Glycemic
+		if *got.Load != 20.54 || got.LoadCategory != GlycemicHigh {
+			t.Fatalf("\t%s\tShould compute glycemic load of the portion: %+v", failed, got)
+		}
+		t.Logf("\t%s\tShould compute glycemic load of the portion.", success)
+
+		c.Fiber = nil
+		if got := c.WithGlycemic(72, "tables", MatchCategory).Glycemic; got.Load != nil || got.Category != GlycemicHigh {
+			t.Fatalf("\t%s\tShould not compute glycemic load without fiber: %+v", failed, got)
+		}
+		t.Logf("\t%s\tShould not compute glycemic load without fiber.", success)
+
+		recipe := Carbohydrates{Amount: 30, UnitName: "g", Source: SourceIngredients, Fiber: &fiber}
+		if got := recipe.WithGlycemic(50, "tables", MatchExact).Glycemic; got.Load == nil || *got.Load != 15 || got.LoadCategory != GlycemicMedium {
+			t.Fatalf("\t%s\tShould compute glycemic load of the recipe from its total: %+v", failed, got)
+		}
+		t.Logf("\t%s\tShould compute glycemic load of the recipe from its total.", success)
+	}
+}
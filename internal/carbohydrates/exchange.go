@@ -0,0 +1,89 @@
+package carbohydrates
+
+import (
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Exchange systems, which count carbohydrates in units of some grams of
+// carbohydrates instead of grams.
+const (
+	// XE is bread unit, Broteinheit or ХЕ, of 12 grams by default. BE is
+	// accepted as the other name of it.
+	XE = "xe"
+
+	// CU is carbohydrate unit of 10 grams.
+	CU = "cu"
+
+	// KE is Kohlenhydrateinheit of 10 grams.
+	KE = "ke"
+)
+
+// exchanges are the default gram equivalents of exchange units.
+var exchanges = map[string]float64{
+	XE: 12,
+	CU: 10,
+	KE: 10,
+}
+
+// Gram equivalents exchange units can be configured with.
+const (
+	minExchangeGrams = 5
+	maxExchangeGrams = 25
+)
+
+// Exchange is the exchange unit, grams of carbohydrates one unit is.
+type Exchange struct {
+	Unit  string  `json:"unit"`
+	Grams float64 `json:"grams"`
+}
+
+// NewExchange returns the exchange unit with given name, e.g. xe, and gram
+// equivalent. The default gram equivalent of the unit is used when grams is
+// zero.
+func NewExchange(unit string, grams float64) (Exchange, error) {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	if unit == "be" {
+		unit = XE
+	}
+
+	def, ok := exchanges[unit]
+	if !ok {
+		return Exchange{}, errors.Errorf("unknown exchange unit %q", unit)
+	}
+	if grams == 0 {
+		grams = def
+	}
+	if math.IsNaN(grams) || grams < minExchangeGrams || grams > maxExchangeGrams {
+		return Exchange{}, errors.Errorf("gram equivalent must be from %d to %d grams", minExchangeGrams, maxExchangeGrams)
+	}
+
+	return Exchange{Unit: unit, Grams: grams}, nil
+}
+
+// Units are carbohydrates counted in exchange units.
+type Units struct {
+	Exchange
+
+	// Amount is total carbohydrates in units, NetAmount is net carbs in
+	// units when they are known.
+	Amount    float64  `json:"amount"`
+	NetAmount *float64 `json:"net_amount,omitempty"`
+}
+
+// InUnits returns carbohydrates with their amounts counted in given exchange
+// units too.
+func (c Carbohydrates) InUnits(e Exchange) Carbohydrates {
+	u := Units{
+		Exchange: e,
+		Amount:   round(c.Amount / e.Grams),
+	}
+	if c.NetCarbs != nil {
+		net := round(*c.NetCarbs / e.Grams)
+		u.NetAmount = &net
+	}
+	c.Units = &u
+	return c
+}
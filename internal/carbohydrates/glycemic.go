@@ -0,0 +1,100 @@
+package carbohydrates
+
+import "strings"
+
+// Matches telling how the glycemic index was found for the food.
+const (
+	// MatchExact is the glycemic index measured for the food itself.
+	MatchExact = "exact"
+
+	// MatchCategory is the glycemic index of the category of the food, used
+	// when the food itself has none.
+	MatchCategory = "category"
+)
+
+// Categories of glycemic index and glycemic load.
+const (
+	GlycemicLow    = "low"
+	GlycemicMedium = "medium"
+	GlycemicHigh   = "high"
+)
+
+// Glycemic is glycemic index of the food and glycemic load of its
+// carbohydrates. High glycemic index tells carbohydrates are fast.
+type Glycemic struct {
+	Index    float64 `json:"index"`
+	Category string  `json:"category"`
+
+	// Load is glycemic load of available carbohydrates, nil when they are
+	// not known.
+	Load         *float64 `json:"load,omitempty"`
+	LoadCategory string   `json:"load_category,omitempty"`
+
+	// Source is the table the glycemic index was taken from and Match tells
+	// whether it was measured for the food or its category.
+	Source string `json:"source"`
+	Match  string `json:"match"`
+}
+
+// IndexCategory returns the category of glycemic index, low is 55 or less and
+// high is 70 or more.
+func IndexCategory(index float64) string {
+	switch {
+	case index <= 55:
+		return GlycemicLow
+	case index < 70:
+		return GlycemicMedium
+	}
+	return GlycemicHigh
+}
+
+// LoadCategory returns the category of glycemic load, low is 10 or less and
+// high is 20 or more.
+func LoadCategory(load float64) string {
+	switch {
+	case load <= 10:
+		return GlycemicLow
+	case load < 20:
+		return GlycemicMedium
+	}
+	return GlycemicHigh
+}
+
+// WithGlycemic returns carbohydrates with given glycemic index and glycemic
+// load computed from it.
+func (c Carbohydrates) WithGlycemic(index float64, source, match string) Carbohydrates {
+	g := Glycemic{
+		Index:    index,
+		Category: IndexCategory(index),
+		Source:   source,
+		Match:    match,
+	}
+	if available, ok := c.available(); ok {
+		load := round(index * available / 100)
+		g.Load = &load
+		g.LoadCategory = LoadCategory(load)
+	}
+	c.Glycemic = &g
+	return c
+}
+
+// available returns available carbohydrates in grams, which glycemic load is
+// computed from. Fiber is taken out of carbohydrates by difference, so they
+// are known only when fiber is known. Total carbohydrates of recipes sum
+// ingredients which may or may not include fiber, so fiber can not be taken
+// out of them and the total is taken, which makes the load of the recipe the
+// highest it can be.
+func (c Carbohydrates) available() (float64, bool) {
+	if !strings.EqualFold(c.UnitName, "g") {
+		return 0, false
+	}
+	switch c.Source {
+	case SourceAvailable, SourceBySummation, SourceIngredients:
+		return c.Amount, true
+	case SourceByDifference:
+		if c.Fiber != nil {
+			return c.Amount - *c.Fiber, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,38 @@
+package carbohydrates
+
+// Basis is the weight in grams carbohydrates of the food are given for, as
+// providers give nutrients per 100 grams.
+const Basis = 100
+
+// ForWeight returns carbohydrates in given grams of the food, scaled from
+// carbohydrates per Basis grams. Amounts are rounded to 2 decimal places, so
+// every client gets the same numbers. Net carbs are computed again from the
+// scaled amounts with the policy they were computed with, as the fiber
+// threshold of the policy is for the fiber eaten, not for Basis grams.
+func (c Carbohydrates) ForWeight(grams float64) Carbohydrates {
+	scale := func(v *float64) *float64 {
+		if v == nil {
+			return nil
+		}
+		scaled := round(*v * grams / Basis)
+		return &scaled
+	}
+
+	c.Amount = round(c.Amount * grams / Basis)
+	c.Fiber = scale(c.Fiber)
+	c.Sugars = scale(c.Sugars)
+	c.AddedSugars = scale(c.AddedSugars)
+	c.SugarAlcohols = scale(c.SugarAlcohols)
+	if c.policy != nil && c.NetCarbs != nil {
+		c.policy.net(&c)
+	} else {
+		c.NetCarbs = scale(c.NetCarbs)
+	}
+	if c.Units != nil {
+		c = c.InUnits(c.Units.Exchange)
+	}
+	if g := c.Glycemic; g != nil {
+		c = c.WithGlycemic(g.Index, g.Source, g.Match)
+	}
+	return c
+}
@@ -0,0 +1,147 @@
+package fdc
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the api while the circuit breaker
+// is open, i.e. the api failed too many times in a row recently.
+var ErrCircuitOpen = errors.New("food data central circuit is open")
+
+// States of the circuit breaker, the values are exposed as fdc_circuit_state.
+const (
+	circuitClosed = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+var circuitStates = [...]string{
+	circuitClosed:   "closed",
+	circuitHalfOpen: "half-open",
+	circuitOpen:     "open",
+}
+
+// outcome is the result of the call as the circuit breaker sees it.
+type outcome int
+
+const (
+	callSucceeded outcome = iota
+	callFailed
+
+	// callIgnored is the call which says nothing about health of the api,
+	// e.g. cancelled by the caller or throttled by the quota.
+	callIgnored
+)
+
+// breaker stops calls to the api once threshold calls in a row failed. After
+// openTimeout it lets halfOpenCalls trial calls through: the circuit closes
+// when all of them succeed and opens again when any of them fails.
+type breaker struct {
+	mu sync.Mutex
+
+	threshold     int
+	openTimeout   time.Duration
+	halfOpenCalls int
+	log           *log.Logger
+
+	state    int
+	failures int
+	openedAt time.Time
+
+	// trials is the number of trial calls let through in half-open state,
+	// succeeded is the number of them which succeeded.
+	trials    int
+	succeeded int
+}
+
+// newBreaker constructs closed circuit breaker.
+func newBreaker(threshold int, openTimeout time.Duration, halfOpenCalls int, log *log.Logger) *breaker {
+	m.circuitState.Set(circuitClosed)
+	return &breaker{
+		threshold:     threshold,
+		openTimeout:   openTimeout,
+		halfOpenCalls: halfOpenCalls,
+		log:           log,
+	}
+}
+
+// allow reports whether the call can be made, and returns ErrCircuitOpen when
+// it can not.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitOpen {
+		if time.Since(b.openedAt) < b.openTimeout {
+			m.circuitRejected.Add(1)
+			return ErrCircuitOpen
+		}
+		b.setState(circuitHalfOpen)
+	}
+
+	if b.state == circuitHalfOpen {
+		if b.trials >= b.halfOpenCalls {
+			m.circuitRejected.Add(1)
+			return ErrCircuitOpen
+		}
+		b.trials++
+	}
+
+	return nil
+}
+
+// done records the outcome of the call allowed before.
+func (b *breaker) done(o outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitClosed:
+		switch o {
+		case callSucceeded:
+			b.failures = 0
+		case callFailed:
+			b.failures++
+			if b.failures >= b.threshold {
+				b.setState(circuitOpen)
+			}
+		}
+
+	case circuitHalfOpen:
+		switch o {
+		case callSucceeded:
+			b.succeeded++
+			if b.succeeded >= b.halfOpenCalls {
+				b.setState(circuitClosed)
+			}
+		case callFailed:
+			b.setState(circuitOpen)
+		case callIgnored:
+			// Give the trial back, so the next call can try instead.
+			b.trials--
+		}
+	}
+}
+
+// setState moves the breaker to given state, resetting the counters of the
+// previous one. Must be called with mu held.
+func (b *breaker) setState(state int) {
+	if b.state == state {
+		return
+	}
+
+	b.log.Printf("fdc : circuit breaker : %s -> %s", circuitStates[b.state], circuitStates[state])
+
+	b.state = state
+	b.failures = 0
+	b.trials = 0
+	b.succeeded = 0
+	if state == circuitOpen {
+		b.openedAt = time.Now()
+		m.circuitOpened.Add(1)
+	}
+	m.circuitState.Set(int64(state))
+}
@@ -0,0 +1,88 @@
+package fdc
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/sugar/internal/fdc/fdctest"
+	"github.com/igomonov88/sugar/internal/tests"
+)
+
+func TestBreaker(t *testing.T) {
+	t.Log("Given the need to stop calling food data central api while it is down.")
+	{
+		ctx := tests.Context()
+
+		srv := fdctest.StartServer(t)
+		defer srv.Close()
+
+		client, err := Connect(Config{
+			ConsumerKey:          "test",
+			APIURL:               srv.APIURL(),
+			MaxRetries:           0,
+			BreakerThreshold:     2,
+			BreakerOpenTimeout:   50 * time.Millisecond,
+			BreakerHalfOpenCalls: 1,
+		})
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to connect to Food Data Center client : %s.", failed, err)
+		}
+
+		t.Log("\tWhen the api fails as many times in a row as the threshold.")
+		{
+			srv.FailNext(http.StatusServiceUnavailable, 2)
+			for i := 0; i < 2; i++ {
+				if _, err := Details(ctx, client, 171688); err == nil {
+					t.Fatalf("\t%s\tShould get an error from failing api.", failed)
+				}
+			}
+
+			requests := srv.Requests()
+			_, err := Details(ctx, client, 171688)
+			if errors.Cause(err) != ErrCircuitOpen {
+				t.Fatalf("\t%s\tShould get ErrCircuitOpen : %v.", failed, err)
+			}
+			if srv.Requests() != requests {
+				t.Fatalf("\t%s\tShould not call the api while the circuit is open.", failed)
+			}
+			t.Logf("\t%s\tShould open the circuit.", success)
+		}
+
+		t.Log("\tWhen the trial call fails after open timeout.")
+		{
+			time.Sleep(60 * time.Millisecond)
+			srv.FailNext(http.StatusBadGateway, 1)
+			if _, err := Details(ctx, client, 171688); err == nil || errors.Cause(err) == ErrCircuitOpen {
+				t.Fatalf("\t%s\tShould let the trial call through : %v.", failed, err)
+			}
+			if _, err := Details(ctx, client, 171688); errors.Cause(err) != ErrCircuitOpen {
+				t.Fatalf("\t%s\tShould open the circuit again : %v.", failed, err)
+			}
+			t.Logf("\t%s\tShould open the circuit again.", success)
+		}
+
+		t.Log("\tWhen the trial call succeeds after open timeout.")
+		{
+			time.Sleep(60 * time.Millisecond)
+			for i := 0; i < 3; i++ {
+				if _, err := Details(ctx, client, 171688); err != nil {
+					t.Fatalf("\t%s\tShould close the circuit : %v.", failed, err)
+				}
+			}
+			t.Logf("\t%s\tShould close the circuit.", success)
+		}
+
+		t.Log("\tWhen the api does not know the food.")
+		{
+			for i := 0; i < 3; i++ {
+				if _, err := Details(ctx, client, 1); errors.Cause(err) == ErrCircuitOpen {
+					t.Fatalf("\t%s\tShould not count client errors as failures.", failed)
+				}
+			}
+			t.Logf("\t%s\tShould not count client errors as failures.", success)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+// Package bulk imports the FoodData Central datasets which USDA publishes as
+// CSV downloads, so the service can answer most lookups from storage without
+// calling the external api.
+package bulk
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/sugar/internal/provider"
+)
+
+// ErrMissingFile is used when the dataset does not hold the file every import
+// requires.
+var ErrMissingFile = errors.New("required dataset file is missing")
+
+// Config is the required properties to import the dataset.
+type Config struct {
+	// Dir is the directory the dataset was unpacked to.
+	Dir string
+
+	// BatchSize is the number of rows copied to database in one transaction.
+	// Progress is saved after every batch, so interrupted import resumes from
+	// the last saved batch.
+	BatchSize int
+
+	// Progress receives a line about every imported batch.
+	Progress io.Writer
+}
+
+// Import streams the dataset files into storage. Files are imported in the
+// order the foreign keys between tables require. Rows already in storage are
+// left as is, so the import can be run again over the same dataset.
+func Import(ctx context.Context, db *sqlx.DB, cfg Config) error {
+	ctx, span := trace.StartSpan(ctx, "internal.fdc.bulk.Import")
+	defer span.End()
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 5000
+	}
+	if cfg.Progress == nil {
+		cfg.Progress = ioutil.Discard
+	}
+
+	dir, err := filepath.Abs(cfg.Dir)
+	if err != nil {
+		return errors.Wrap(err, "resolving dataset directory")
+	}
+	cfg.Dir = dir
+
+	nutrients, err := readNutrients(filepath.Join(dir, "nutrient.csv"))
+	if err != nil {
+		return err
+	}
+
+	categories, err := readCategories(filepath.Join(dir, "food_category.csv"))
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tables(nutrients, categories) {
+		if err := importTable(ctx, db, cfg, t); err != nil {
+			return errors.Wrapf(err, "importing %s", t.file)
+		}
+	}
+
+	return nil
+}
+
+// table describes how the rows of one dataset file are moved to storage. Rows
+// are copied into the temporary staging table first, and merge statement moves
+// them from there to the tables of the service.
+type table struct {
+	file     string
+	required bool
+
+	// columns are the names of the csv columns the row function gets values
+	// of, in the same order.
+	columns []string
+
+	// staging is the definition of the temporary table, stagingColumns are
+	// the columns copied into it.
+	staging        string
+	stagingColumns []string
+
+	// row converts values of the csv columns to values of the staging
+	// columns. Rows it reports as not ok are skipped.
+	row func(values []string) ([]interface{}, bool)
+
+	merge string
+}
+
+// tables returns the dataset files in the order they should be imported.
+func tables(nutrients map[string]nutrient, categories map[string]string) []table {
+	return []table{
+		{
+			file:           "food.csv",
+			required:       true,
+			columns:        []string{"fdc_id", "description", "data_type", "food_category_id", "publication_date"},
+			staging:        "fdc_id INT, description VARCHAR, data_type VARCHAR, food_category VARCHAR, published_date DATE",
+			stagingColumns: []string{"fdc_id", "description", "data_type", "food_category", "published_date"},
+			row: func(v []string) ([]interface{}, bool) {
+				id, err := strconv.Atoi(v[0])
+				if err != nil {
+					return nil, false
+				}
+				var published interface{}
+				if len(v[4]) >= len("2006-01-02") {
+					published = v[4][:len("2006-01-02")]
+				}
+				return []interface{}{id, v[1], dataType(v[2]), nullable(categories[v[3]]), published}, true
+			},
+			merge: `
+			INSERT INTO food (fdc_id, description, data_type, food_category, published_date)
+			SELECT fdc_id, description, data_type, food_category, published_date FROM staging
+			ON CONFLICT (fdc_id) DO UPDATE SET data_type = COALESCE(food.data_type, EXCLUDED.data_type),
+				food_category = COALESCE(food.food_category, EXCLUDED.food_category),
+				published_date = COALESCE(food.published_date, EXCLUDED.published_date);`,
+		},
+		{
+			file:           "branded_food.csv",
+			columns:        []string{"fdc_id", "brand_owner", "gtin_upc", "branded_food_category"},
+			staging:        "fdc_id INT, brand_owner VARCHAR, barcode VARCHAR, gtin VARCHAR, food_category VARCHAR",
+			stagingColumns: []string{"fdc_id", "brand_owner", "barcode", "gtin", "food_category"},
+			row: func(v []string) ([]interface{}, bool) {
+				id, err := strconv.Atoi(v[0])
+				if err != nil || (v[1] == "" && v[2] == "" && v[3] == "") {
+					return nil, false
+				}
+				var gtin interface{}
+				if g, err := provider.GTIN(v[2]); err == nil {
+					gtin = g
+				}
+				return []interface{}{id, nullable(v[1]), nullable(v[2]), gtin, nullable(v[3])}, true
+			},
+			merge: `
+			UPDATE food SET brand_owner = COALESCE(food.brand_owner, s.brand_owner),
+				barcode = COALESCE(food.barcode, s.barcode), gtin = COALESCE(food.gtin, s.gtin),
+				food_category = COALESCE(food.food_category, s.food_category)
+			FROM staging AS s
+			WHERE food.fdc_id = s.fdc_id;`,
+		},
+		{
+			file:           "food_nutrient.csv",
+			columns:        []string{"fdc_id", "nutrient_id", "amount"},
+			staging:        "fdc_id INT, number INT, name VARCHAR, rank INT, unit_name VARCHAR, amount FLOAT, preference INT",
+			stagingColumns: []string{"fdc_id", "number", "name", "rank", "unit_name", "amount", "preference"},
+			row: func(v []string) ([]interface{}, bool) {
+				n, ok := nutrients[v[1]]
+				if !ok {
+					return nil, false
+				}
+				id, err := strconv.Atoi(v[0])
+				if err != nil {
+					return nil, false
+				}
+				amount, err := strconv.ParseFloat(v[2], 64)
+				if err != nil {
+					return nil, false
+				}
+				return []interface{}{id, nullableInt(n.number), n.name, nullableInt(n.rank), n.unitName, amount, nullableInt(n.carbohydrates)}, true
+			},
+
+			// Nutrients are saved once and shared by foods, and amounts
+			// already in storage are left as is, like storage.SaveNutrients
+			// does. The same food can have carbohydrates measured in
+			// different ways, the preferred one is kept like
+			// carbohydrates.Policy does. Rows of the food can be in different
+			// batches, so the preference is saved and a less preferred
+			// amount does not replace the saved one.
+			merge: `
+			INSERT INTO nutrients (number, name, rank, unit_name)
+			SELECT DISTINCT ON (name, unit_name) number, name, rank, unit_name FROM staging
+			ORDER BY name, unit_name
+			ON CONFLICT (name, unit_name) DO UPDATE SET number = COALESCE(nutrients.number, EXCLUDED.number),
+				rank = COALESCE(nutrients.rank, EXCLUDED.rank);
+
+			INSERT INTO food_nutrients (fdc_id, nutrient_id, amount)
+			SELECT DISTINCT ON (s.fdc_id, n.id) s.fdc_id, n.id, s.amount
+			FROM staging AS s
+			INNER JOIN food AS f ON f.fdc_id = s.fdc_id
+			INNER JOIN nutrients AS n ON n.name = s.name AND n.unit_name = s.unit_name
+			ORDER BY s.fdc_id, n.id
+			ON CONFLICT DO NOTHING;
+
+			INSERT INTO carbohydrates (fdc_id, amount, unit_name, preference)
+			SELECT DISTINCT ON (s.fdc_id) s.fdc_id, s.amount, s.unit_name, s.preference
+			FROM staging AS s INNER JOIN food AS f ON f.fdc_id = s.fdc_id
+			WHERE s.preference IS NOT NULL
+			ORDER BY s.fdc_id, s.preference
+			ON CONFLICT (fdc_id) DO UPDATE
+			SET amount = EXCLUDED.amount, unit_name = EXCLUDED.unit_name, preference = EXCLUDED.preference
+			WHERE carbohydrates.preference IS NULL OR carbohydrates.preference >= EXCLUDED.preference;`,
+		},
+		{
+			file:           "food_portion.csv",
+			columns:        []string{"fdc_id", "amount", "portion_description", "modifier", "gram_weight"},
+			staging:        "fdc_id INT, gram_weight FLOAT, description VARCHAR",
+			stagingColumns: []string{"fdc_id", "gram_weight", "description"},
+			row: func(v []string) ([]interface{}, bool) {
+				id, err := strconv.Atoi(v[0])
+				if err != nil {
+					return nil, false
+				}
+				gw, err := strconv.ParseFloat(v[4], 64)
+				if err != nil || gw <= 0 {
+					return nil, false
+				}
+				return []interface{}{id, gw, portionDescription(v[1], v[2], v[3])}, true
+			},
+			merge: `
+			INSERT INTO portions (fdc_id, gram_weight, description)
+			SELECT s.fdc_id, s.gram_weight, s.description
+			FROM staging AS s INNER JOIN food AS f ON f.fdc_id = s.fdc_id
+			ON CONFLICT DO NOTHING;`,
+		},
+	}
+}
+
+// importTable streams rows of the table file to storage in batches, skipping
+// rows which were imported before.
+func importTable(ctx context.Context, db *sqlx.DB, cfg Config, t table) error {
+	path := filepath.Join(cfg.Dir, t.file)
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && !t.required {
+			fmt.Fprintf(cfg.Progress, "%s : skipped, file is missing\n", t.file)
+			return nil
+		}
+		if os.IsNotExist(err) {
+			return errors.Wrap(ErrMissingFile, t.file)
+		}
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	key := progressKey(path, info)
+
+	done, rows, err := progress(ctx, db, key)
+	if err != nil {
+		return err
+	}
+	if done {
+		fmt.Fprintf(cfg.Progress, "%s : already imported, %d rows\n", t.file, rows)
+		return nil
+	}
+
+	r, err := newReader(f, t.columns)
+	if err != nil {
+		return err
+	}
+
+	// Skip rows imported by the interrupted run.
+	for n := int64(0); n < rows; n++ {
+		if _, err := r.read(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+	}
+
+	batch := make([][]interface{}, 0, cfg.BatchSize)
+	for {
+		values, err := r.read()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		last := err == io.EOF
+
+		if !last {
+			rows++
+			if v, ok := t.row(values); ok {
+				batch = append(batch, v)
+			}
+		}
+
+		if len(batch) == cfg.BatchSize || last {
+			if err := saveBatch(ctx, db, t, key, batch, rows, last); err != nil {
+				return err
+			}
+			fmt.Fprintf(cfg.Progress, "%s : %d rows\n", t.file, rows)
+			batch = batch[:0]
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// saveBatch copies batch rows into the staging table and merges them into the
+// tables of the service. Progress is saved under key in the same transaction,
+// so the batch is either imported and recorded or not imported at all.
+func saveBatch(ctx context.Context, db *sqlx.DB, t table, key string, batch [][]interface{}, rows int64, done bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	if len(batch) != 0 {
+		if _, err := tx.Exec(`CREATE TEMP TABLE staging (` + t.staging + `) ON COMMIT DROP;`); err != nil {
+			return errors.Wrap(err, "creating staging table")
+		}
+
+		stmt, err := tx.Prepare(pq.CopyIn("staging", t.stagingColumns...))
+		if err != nil {
+			return errors.Wrap(err, "preparing copy")
+		}
+		for _, v := range batch {
+			if _, err := stmt.Exec(v...); err != nil {
+				stmt.Close()
+				return errors.Wrap(err, "copying row")
+			}
+		}
+		if _, err := stmt.Exec(); err != nil {
+			stmt.Close()
+			return errors.Wrap(err, "flushing copy")
+		}
+		if err := stmt.Close(); err != nil {
+			return errors.Wrap(err, "closing copy")
+		}
+
+		if _, err := tx.Exec(t.merge); err != nil {
+			return errors.Wrap(err, "merging staging rows")
+		}
+	}
+
+	const saveProgress = `
+	INSERT INTO import_progress (file, rows, done, date_updated) VALUES ($1, $2, $3, now())
+	ON CONFLICT (file) DO UPDATE SET rows = $2, done = $3, date_updated = now();`
+
+	if _, err := tx.Exec(saveProgress, key, rows, done); err != nil {
+		return errors.Wrap(err, "saving progress")
+	}
+
+	return tx.Commit()
+}
+
+// progressKey returns the key progress of the file is saved under. The key
+// holds size and modification time of the file besides its path, so a newer
+// release unpacked to the same directory is imported again.
+func progressKey(path string, info os.FileInfo) string {
+	return fmt.Sprintf("%s@%d@%d", path, info.Size(), info.ModTime().Unix())
+}
+
+// progress returns how many rows of the file saved under key were imported,
+// and whether the whole file was imported.
+func progress(ctx context.Context, db *sqlx.DB, key string) (bool, int64, error) {
+	const q = `SELECT done, rows FROM import_progress WHERE file = $1;`
+
+	var p struct {
+		Done bool  `db:"done"`
+		Rows int64 `db:"rows"`
+	}
+	err := db.GetContext(ctx, &p, q, key)
+	switch err {
+	case nil:
+		return p.Done, p.Rows, nil
+	case sql.ErrNoRows:
+		return false, 0, nil
+	default:
+		return false, 0, errors.Wrap(err, "reading progress")
+	}
+}
+
+// nullable returns NULL for empty value of the csv column.
+func nullable(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
+
+// nullableInt returns NULL for zero value, which stands for the value which is
+// not known.
+func nullableInt(v int) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+// dataType converts the data type of the dataset to the one the api reports,
+// e.g. sr_legacy_food to SR Legacy. Unknown data types are saved as is.
+func dataType(v string) interface{} {
+	switch v {
+	case "branded_food":
+		return "Branded"
+	case "foundation_food":
+		return "Foundation"
+	case "sr_legacy_food":
+		return "SR Legacy"
+	case "survey_fndds_food":
+		return "Survey (FNDDS)"
+	}
+	return nullable(v)
+}
+
+// portionDescription composes the description of the portion like the api
+// does: the portion description when given, the amount with modifier otherwise.
+func portionDescription(amount, description, modifier string) string {
+	if description != "" && description != "Quantity not specified" {
+		return description
+	}
+	return strings.TrimSpace(amount + " " + modifier)
+}
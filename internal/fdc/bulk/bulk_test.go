@@ -0,0 +1,94 @@
+package bulk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProgressKey(t *testing.T) {
+	t.Log("Given the need to tell releases of the dataset unpacked to the same directory apart.")
+	{
+		dir, err := ioutil.TempDir("", "bulk")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create directory : %s.", failed, err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "food.csv")
+
+		key := func() string {
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to stat the file : %s.", failed, err)
+			}
+			return progressKey(path, info)
+		}
+
+		t.Log("\tWhen the file is replaced by the newer release.")
+		{
+			if err := ioutil.WriteFile(path, []byte("fdc_id\n1\n"), 0644); err != nil {
+				t.Fatalf("\t%s\tShould be able to write the file : %s.", failed, err)
+			}
+			old := key()
+			if key() != old {
+				t.Fatalf("\t%s\tShould get the same key for the same file.", failed)
+			}
+
+			if err := ioutil.WriteFile(path, []byte("fdc_id\n1\n2\n"), 0644); err != nil {
+				t.Fatalf("\t%s\tShould be able to write the file : %s.", failed, err)
+			}
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(path, later, later); err != nil {
+				t.Fatalf("\t%s\tShould be able to change the file time : %s.", failed, err)
+			}
+			if key() == old {
+				t.Fatalf("\t%s\tShould get another key for the newer release : %s.", failed, old)
+			}
+			t.Logf("\t%s\tShould get another key for the newer release.", success)
+		}
+	}
+}
+
+func TestReadNutrients(t *testing.T) {
+	t.Log("Given the need to import nutrients the registry knows.")
+	{
+		dir, err := ioutil.TempDir("", "bulk")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create directory : %s.", failed, err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "nutrient.csv")
+
+		const file = `"id","name","unit_name","nutrient_nbr","rank"
+"1003","Protein","G","203","600.0"
+"1005","Carbohydrate, by difference","G","205","1110.0"
+"1050","Carbohydrate, by summation","G","205.2","1120.0"
+"1089","Iron, Fe","MG","303","5400.0"
+"1091","Phosphorus, P","MG","305","5600.0"
+`
+		if err := ioutil.WriteFile(path, []byte(file), 0644); err != nil {
+			t.Fatalf("\t%s\tShould be able to write the file : %s.", failed, err)
+		}
+
+		list, err := readNutrients(path)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to read nutrients : %s.", failed, err)
+		}
+		if len(list) != 4 {
+			t.Fatalf("\t%s\tShould read only nutrients of the registry, got %v.", failed, list)
+		}
+		t.Logf("\t%s\tShould read only nutrients of the registry.", success)
+
+		if n := list["1089"]; n.number != 303 || n.name != "Iron, Fe" || n.unitName != "mg" || n.rank != 5400 || n.carbohydrates != 0 {
+			t.Fatalf("\t%s\tShould name the nutrient like the registry, got %+v.", failed, n)
+		}
+		t.Logf("\t%s\tShould name the nutrient like the registry.", success)
+
+		if n := list["1050"]; n.number != 0 || n.carbohydrates != 2 || list["1005"].carbohydrates != 1 {
+			t.Fatalf("\t%s\tShould prefer carbohydrates by difference, got %+v.", failed, list)
+		}
+		t.Logf("\t%s\tShould prefer carbohydrates by difference.", success)
+	}
+}
@@ -0,0 +1,181 @@
+package bulk
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/sugar/internal/nutrients"
+)
+
+// reader reads values of the named columns from the csv file of the dataset.
+type reader struct {
+	csv     *csv.Reader
+	indexes []int
+	values  []string
+}
+
+// newReader reads the header of the file and finds the columns to read.
+func newReader(r io.Reader, columns []string) (*reader, error) {
+
+	// Files saved by spreadsheet editors may start with the byte order mark,
+	// it would become the part of the first column name.
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(3); err == nil && string(b) == "\ufeff" {
+		br.Discard(3)
+	}
+
+	cr := csv.NewReader(br)
+	cr.ReuseRecord = true
+	cr.LazyQuotes = true
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading header")
+	}
+
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		positions[strings.TrimSpace(name)] = i
+	}
+
+	rd := reader{
+		csv:     cr,
+		indexes: make([]int, len(columns)),
+		values:  make([]string, len(columns)),
+	}
+	for i, name := range columns {
+		pos, ok := positions[name]
+		if !ok {
+			return nil, errors.Errorf("column %q is missing", name)
+		}
+		rd.indexes[i] = pos
+	}
+
+	return &rd, nil
+}
+
+// read returns values of the columns of the next row. The returned slice is
+// reused by the next call.
+func (r *reader) read() ([]string, error) {
+	record, err := r.csv.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i, pos := range r.indexes {
+		r.values[i] = ""
+		if pos < len(record) {
+			r.values[i] = strings.TrimSpace(record[pos])
+		}
+	}
+	return r.values, nil
+}
+
+// nutrient is the row of nutrient.csv the import cares about.
+type nutrient struct {
+	// number, name and unitName are taken from the nutrients registry, name
+	// is empty for nutrients the registry does not know. number is zero for
+	// nutrients which numbers are not integer, e.g. 205.2, like the details
+	// of the api are saved.
+	number   int
+	name     string
+	unitName string
+	rank     int
+
+	// carbohydrates is the preference of the nutrient as total carbohydrates
+	// of the food, lower is preferred. Zero is set for other nutrients.
+	carbohydrates int
+}
+
+// carbohydrates are numbers of the nutrients taken as total carbohydrates of
+// the food, in order of preference like carbohydrates.Policy takes them.
+var carbohydrates = []string{
+	nutrients.CarbohydrateByDifference,
+	nutrients.CarbohydrateBySummation,
+}
+
+// readNutrients reads nutrient.csv into memory, it is small and needed to
+// recognize nutrients of the registry among food nutrients by their numbers.
+func readNutrients(path string) (map[string]nutrient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Wrap(ErrMissingFile, "nutrient.csv")
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := newReader(f, []string{"id", "nutrient_nbr", "rank"})
+	if err != nil {
+		return nil, errors.Wrap(err, "reading nutrient.csv")
+	}
+
+	list := make(map[string]nutrient)
+	for {
+		v, err := r.read()
+		if err == io.EOF {
+			return list, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "reading nutrient.csv")
+		}
+
+		// Some releases write whole numbers as decimals, e.g. 205.0.
+		number := strings.TrimSuffix(v[1], ".0")
+		rn, ok := nutrients.Lookup(number)
+		if !ok {
+			continue
+		}
+
+		n := nutrient{name: rn.Name, unitName: rn.Unit}
+		n.number, _ = strconv.Atoi(number)
+		if rank, err := strconv.ParseFloat(v[2], 64); err == nil {
+			n.rank = int(rank)
+		}
+		for i, c := range carbohydrates {
+			if number == c {
+				n.carbohydrates = i + 1
+			}
+		}
+		list[v[0]] = n
+	}
+}
+
+// readCategories reads food_category.csv into memory, so foods are saved with
+// the description of their category. Categories are optional, the empty map is
+// returned when the file is missing.
+func readCategories(path string) (map[string]string, error) {
+	categories := make(map[string]string)
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return categories, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := newReader(f, []string{"id", "description"})
+	if err != nil {
+		return nil, errors.Wrap(err, "reading food_category.csv")
+	}
+
+	for {
+		v, err := r.read()
+		if err == io.EOF {
+			return categories, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "reading food_category.csv")
+		}
+		categories[v[0]] = v[1]
+	}
+}
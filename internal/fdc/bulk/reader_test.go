@@ -0,0 +1,61 @@
+package bulk
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestReader(t *testing.T) {
+	t.Log("Given the need to read columns of dataset files by name.")
+	{
+		t.Log("\tWhen the file has columns in any order and starts with byte order mark.")
+		{
+			const file = "\ufeff\"id\",\"fdc_id\",\"nutrient_id\",\"amount\"\n" +
+				"\"1\",\"1001\",\"1005\",\"12.5\"\n" +
+				"\"2\",\"1001\",\"1003\"\n"
+
+			r, err := newReader(strings.NewReader(file), []string{"fdc_id", "amount", "id"})
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to read the header : %s.", failed, err)
+			}
+
+			v, err := r.read()
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to read the row : %s.", failed, err)
+			}
+			if v[0] != "1001" || v[1] != "12.5" || v[2] != "1" {
+				t.Fatalf("\t%s\tShould get values of named columns : %v.", failed, v)
+			}
+
+			v, err = r.read()
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to read the short row : %s.", failed, err)
+			}
+			if v[1] != "" {
+				t.Fatalf("\t%s\tShould get empty value of missing column : %q.", failed, v[1])
+			}
+			t.Logf("\t%s\tShould get values of named columns.", success)
+		}
+
+		t.Log("\tWhen the file does not have the column.")
+		{
+			if _, err := newReader(strings.NewReader("id,fdc_id\n"), []string{"amount"}); err == nil {
+				t.Fatalf("\t%s\tShould get an error for missing column.", failed)
+			}
+			t.Logf("\t%s\tShould get an error for missing column.", success)
+		}
+
+		t.Log("\tWhen the portion is described by amount and modifier only.")
+		{
+			if got := portionDescription("1", "Quantity not specified", "cup"); got != "1 cup" {
+				t.Fatalf("\t%s\tShould compose portion description, got %q.", failed, got)
+			}
+			t.Logf("\t%s\tShould compose portion description.", success)
+		}
+	}
+}
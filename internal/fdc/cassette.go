@@ -0,0 +1,253 @@
+package fdc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Modes of the cassette.
+const (
+	// CassettePassthrough sends requests to the api and records nothing.
+	CassettePassthrough = "passthrough"
+
+	// CassetteRecord sends requests to the api and saves every interaction.
+	CassetteRecord = "record"
+
+	// CassetteReplay answers requests with saved interactions and never calls
+	// the api.
+	CassetteReplay = "replay"
+)
+
+// Environment variables which select the cassette when Config does not.
+const (
+	CassetteModeEnv = "FDC_CASSETTE_MODE"
+	CassetteDirEnv  = "FDC_CASSETTE_DIR"
+)
+
+var (
+	// ErrInvalidCassetteMode is used when cassette mode is not one of the
+	// known modes.
+	ErrInvalidCassetteMode = errors.New("invalid cassette mode")
+
+	// ErrInteractionNotFound is used when replayed cassette has no saved
+	// interaction for the request.
+	ErrInteractionNotFound = errors.New("cassette has no interaction for the request")
+)
+
+// Cassette is the http.RoundTripper which records interactions with the api to
+// files and replays them, so fixtures of search and details flows can be
+// captured from real traffic once and used deterministically after.
+//
+// Interactions are matched by method, path and normalized json body of the
+// request. Consumer key is never saved.
+type Cassette struct {
+	mode string
+	dir  string
+	next http.RoundTripper
+
+	mu sync.Mutex
+}
+
+// NewCassette constructs the cassette in given mode, keeping interactions in
+// dir and sending requests through next.
+func NewCassette(mode, dir string, next http.RoundTripper) (*Cassette, error) {
+	switch mode {
+	case CassettePassthrough, CassetteRecord, CassetteReplay:
+	default:
+		return nil, errors.Wrapf(ErrInvalidCassetteMode, "mode %q", mode)
+	}
+	if mode != CassettePassthrough && dir == "" {
+		return nil, errors.Wrap(ErrInvalidConfig, "cassette directory is not specified")
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Cassette{mode: mode, dir: dir, next: next}, nil
+}
+
+// interaction is the request and response pair saved to the file.
+type interaction struct {
+	Request struct {
+		Method string          `json:"method"`
+		URL    string          `json:"url"`
+		Body   json.RawMessage `json:"body,omitempty"`
+	} `json:"request"`
+	Response struct {
+		StatusCode int             `json:"status_code"`
+		Header     http.Header     `json:"header"`
+		Body       json.RawMessage `json:"body,omitempty"`
+		RawBody    string          `json:"raw_body,omitempty"`
+	} `json:"response"`
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	if c.mode == CassettePassthrough {
+		return c.next.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "reading request body")
+		}
+		body = b
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	path := filepath.Join(c.dir, interactionName(req.Method, req.URL.Path, body))
+
+	if c.mode == CassetteReplay {
+		return c.replay(req, path)
+	}
+
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.record(req, body, resp, path); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// record saves the interaction to the file. Response body is read and put
+// back, so the caller gets it untouched.
+func (c *Cassette) record(req *http.Request, body []byte, resp *http.Response, path string) error {
+	rb, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return errors.Wrap(err, "reading response body")
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(rb))
+
+	var in interaction
+	in.Request.Method = req.Method
+	in.Request.URL = redactURL(req.URL.String())
+	if len(body) != 0 {
+		in.Request.Body = normalizeJSON(body)
+	}
+	in.Response.StatusCode = resp.StatusCode
+	in.Response.Header = resp.Header
+	if json.Valid(rb) {
+		in.Response.Body = rb
+	} else {
+		in.Response.RawBody = string(rb)
+	}
+
+	b, err := json.MarshalIndent(in, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshal interaction")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return errors.Wrap(err, "creating cassette directory")
+	}
+	if err := ioutil.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "saving interaction")
+	}
+	return nil
+}
+
+// replay answers the request with the interaction saved to the file.
+func (c *Cassette) replay(req *http.Request, path string) (*http.Response, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Wrapf(ErrInteractionNotFound, "%s %s", req.Method, req.URL.Path)
+		}
+		return nil, errors.Wrap(err, "reading interaction")
+	}
+
+	var in interaction
+	if err := json.Unmarshal(b, &in); err != nil {
+		return nil, errors.Wrapf(err, "decoding interaction %s", path)
+	}
+
+	rb := []byte(in.Response.Body)
+	if len(rb) == 0 {
+		rb = []byte(in.Response.RawBody)
+	}
+
+	resp := http.Response{
+		Status:        http.StatusText(in.Response.StatusCode),
+		StatusCode:    in.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        in.Response.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(rb)),
+		ContentLength: int64(len(rb)),
+		Request:       req,
+	}
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	return &resp, nil
+}
+
+var nonWord = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// interactionName returns the file name of the interaction. The readable part
+// helps to find the fixture, the hash tells apart requests with the same path.
+func interactionName(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + "\n" + path + "\n"))
+	h.Write(normalizeJSON(body))
+	sum := hex.EncodeToString(h.Sum(nil))[:12]
+
+	slug := strings.Trim(nonWord.ReplaceAllString(path, "-"), "-")
+	if slug == "" {
+		slug = "root"
+	}
+	return strings.ToLower(method) + "-" + slug + "-" + sum + ".json"
+}
+
+// normalizeJSON returns the json document with sorted keys and without
+// insignificant whitespace, so equal documents are byte to byte equal. Bodies
+// which are not json are returned as is.
+func normalizeJSON(b []byte) []byte {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return b
+	}
+	n, err := json.Marshal(v)
+	if err != nil {
+		return b
+	}
+	return n
+}
+
+// replayMiss reports whether the call failed because replayed cassette has no
+// interaction for it, which no retry can fix.
+func replayMiss(err error) bool {
+	if ue, ok := err.(*url.Error); ok {
+		err = ue.Err
+	}
+	return errors.Cause(err) == ErrInteractionNotFound
+}
@@ -0,0 +1,124 @@
+package fdc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/sugar/internal/fdc/fdctest"
+	"github.com/igomonov88/sugar/internal/tests"
+)
+
+func TestCassette(t *testing.T) {
+	t.Log("Given the need to record and replay calls to food data central api.")
+	{
+		ctx := tests.Context()
+
+		dir, err := ioutil.TempDir("", "cassette")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create cassette directory : %s.", failed, err)
+		}
+		defer os.RemoveAll(dir)
+
+		srv := fdctest.StartServer(t)
+		defer srv.Close()
+
+		search := SearchInternalRequest{GeneralSearchInput: "apple"}
+
+		t.Log("\tWhen recording calls to the api.")
+		{
+			client, err := Connect(Config{
+				ConsumerKey:  "secret-key",
+				APIURL:       srv.APIURL(),
+				CassetteMode: CassetteRecord,
+				CassetteDir:  dir,
+			})
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to connect to Food Data Center client : %s.", failed, err)
+			}
+			if _, err := SearchOutput(ctx, client, search); err != nil {
+				t.Fatalf("\t%s\tShould be able to search while recording : %s.", failed, err)
+			}
+			if _, err := Details(ctx, client, 171688); err != nil {
+				t.Fatalf("\t%s\tShould be able to get details while recording : %s.", failed, err)
+			}
+
+			files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+			if err != nil || len(files) != 2 {
+				t.Fatalf("\t%s\tShould save 2 interactions, saved %d.", failed, len(files))
+			}
+			for _, f := range files {
+				b, err := ioutil.ReadFile(f)
+				if err != nil {
+					t.Fatalf("\t%s\tShould be able to read interaction : %s.", failed, err)
+				}
+				if strings.Contains(string(b), "secret-key") {
+					t.Fatalf("\t%s\tShould not save the consumer key : %s.", failed, f)
+				}
+			}
+			t.Logf("\t%s\tShould save interactions without the consumer key.", success)
+		}
+
+		srv.Close()
+
+		t.Log("\tWhen replaying calls with the api gone.")
+		{
+			client, err := Connect(Config{
+				ConsumerKey:  "other-key",
+				APIURL:       srv.APIURL(),
+				CassetteMode: CassetteReplay,
+				CassetteDir:  dir,
+			})
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to connect to Food Data Center client : %s.", failed, err)
+			}
+
+			sr, err := SearchOutput(ctx, client, search)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to replay search : %s.", failed, err)
+			}
+			if len(sr.Foods) == 0 {
+				t.Fatalf("\t%s\tShould replay recorded search result.", failed)
+			}
+
+			d, err := Details(ctx, client, 171688)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to replay details : %s.", failed, err)
+			}
+			if d.Description == "" {
+				t.Fatalf("\t%s\tShould replay recorded details.", failed)
+			}
+			t.Logf("\t%s\tShould replay recorded interactions.", success)
+
+			_, err = Details(ctx, client, 170720)
+			if !replayMiss(errors.Cause(err)) {
+				t.Fatalf("\t%s\tShould fail with ErrInteractionNotFound for unrecorded call : %v.", failed, err)
+			}
+			t.Logf("\t%s\tShould fail for unrecorded call.", success)
+		}
+	}
+}
+
+func TestInteractionName(t *testing.T) {
+	t.Log("Given the need to match requests with recorded interactions.")
+	{
+		a := interactionName("POST", "/search", []byte(`{"generalSearchInput":"apple","pageNumber":"1"}`))
+		b := interactionName("POST", "/search", []byte("{\n  \"pageNumber\": \"1\",\n  \"generalSearchInput\": \"apple\"\n}"))
+		if a != b {
+			t.Fatalf("\t%s\tShould match json bodies regardless of key order and whitespace : %s != %s.", failed, a, b)
+		}
+
+		c := interactionName("POST", "/search", []byte(`{"generalSearchInput":"pear","pageNumber":"1"}`))
+		if a == c {
+			t.Fatalf("\t%s\tShould tell apart different bodies.", failed)
+		}
+		if d := interactionName("GET", "/search", nil); d == a {
+			t.Fatalf("\t%s\tShould tell apart different methods.", failed)
+		}
+		t.Logf("\t%s\tShould match requests by method, path and normalized body.", success)
+	}
+}
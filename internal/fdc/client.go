@@ -5,7 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io/ioutil"
+	"log"
+	"net"
 	"net/http"
+	"os"
+	"time"
 
 	"go.opencensus.io/trace"
 )
@@ -14,19 +19,192 @@ import (
 type Config struct {
 	ConsumerKey string
 	APIURL      string
+
+	// ConsumerKeys and ConsumerKeysFile add consumer keys to ConsumerKey.
+	// The file holds one key per line, blank lines and lines starting with #
+	// are skipped. Calls are rotated across all the keys, see keyPool. At
+	// least one key must be given, Connect fails with ErrNoConsumerKey
+	// otherwise.
+	ConsumerKeys     []string
+	ConsumerKeysFile string
+
+	// KeyCooldown is how long the key refused by the api with 403 is not used.
+	KeyCooldown time.Duration
+
+	// RequestTimeout limits a single attempt of the call to the api.
+	RequestTimeout time.Duration
+
+	// MaxIdleConns, IdleConnTimeout and TLSHandshakeTimeout tune the transport
+	// dedicated to food data central api.
+	MaxIdleConns        int
+	IdleConnTimeout     time.Duration
+	TLSHandshakeTimeout time.Duration
+
+	// MaxRetries is the number of additional attempts made when the call fails
+	// with network error, 5xx or 429 status.
+	MaxRetries int
+
+	// RetryWaitMin and RetryWaitMax bound the exponential backoff between
+	// attempts.
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+
+	// RequestsPerHour and Burst configure the client side limiter, which
+	// keeps the client within the hourly quota of the consumer key.
+	RequestsPerHour int
+	Burst           int
+
+	// MaxThrottleWait is the longest a call waits for the limiter or for
+	// Retry-After of 429 response before ErrQuotaExhausted is returned.
+	MaxThrottleWait time.Duration
+
+	// QuotaCooldown is how long the client stops calling the api once it
+	// reports no requests left for the consumer key.
+	QuotaCooldown time.Duration
+
+	// CassetteMode selects whether calls to the api are recorded to or
+	// replayed from CassetteDir, see Cassette. When empty the mode and the
+	// directory are taken from FDC_CASSETTE_MODE and FDC_CASSETTE_DIR
+	// environment variables, and calls pass through when those are not set.
+	CassetteMode string
+	CassetteDir  string
+
+	// BreakerThreshold is the number of calls in a row which fail with
+	// network error or 5xx status before the circuit breaker opens, and
+	// calls return ErrCircuitOpen without reaching the api.
+	BreakerThreshold int
+
+	// BreakerOpenTimeout is how long the circuit stays open before
+	// BreakerHalfOpenCalls trial calls are let through to probe the api.
+	BreakerOpenTimeout   time.Duration
+	BreakerHalfOpenCalls int
+
+	// Log receives state changes of the circuit breaker.
+	Log *log.Logger
 }
 
 // Client makes all operations with food data central external api.
 type Client struct {
-	cfg Config
+	cfg     Config
+	http    *http.Client
+	keys    *keyPool
+	breaker *breaker
 }
 
 // Connect knows how to connect to food data central api with provided config.
 func Connect(cfg Config) (*Client, error) {
-	if cfg.APIURL == "" || cfg.ConsumerKey == "" {
+	if cfg.APIURL == "" {
 		return nil, ErrInvalidConfig
 	}
-	return &Client{cfg}, nil
+	if cfg.MaxRetries < 0 || cfg.RequestsPerHour < 0 || cfg.Burst < 0 || (cfg.RetryWaitMax != 0 && cfg.RetryWaitMin > cfg.RetryWaitMax) {
+		return nil, ErrInvalidConfig
+	}
+	if cfg.BreakerThreshold < 0 || cfg.BreakerOpenTimeout < 0 || cfg.BreakerHalfOpenCalls < 0 || cfg.KeyCooldown < 0 {
+		return nil, ErrInvalidConfig
+	}
+	cfg = withDefaults(cfg)
+
+	keys, err := consumerKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoConsumerKey
+	}
+
+	tr := http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConns,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	var rt http.RoundTripper = &tr
+	if cfg.CassetteMode != CassettePassthrough {
+		cst, err := NewCassette(cfg.CassetteMode, cfg.CassetteDir, &tr)
+		if err != nil {
+			return nil, err
+		}
+		rt = cst
+	}
+
+	c := Client{
+		cfg: cfg,
+		http: &http.Client{
+			Transport: rt,
+			Timeout:   cfg.RequestTimeout,
+		},
+		keys: newKeyPool(keys, cfg.KeyCooldown, func() *quota {
+			return newQuota(cfg.RequestsPerHour, cfg.Burst, cfg.MaxThrottleWait, cfg.QuotaCooldown)
+		}),
+		breaker: newBreaker(cfg.BreakerThreshold, cfg.BreakerOpenTimeout, cfg.BreakerHalfOpenCalls, cfg.Log),
+	}
+	return &c, nil
+}
+
+// withDefaults fills zero values of the optional config properties.
+func withDefaults(cfg Config) Config {
+	if cfg.RequestTimeout == 0 {
+		cfg.RequestTimeout = 10 * time.Second
+	}
+	if cfg.MaxIdleConns == 0 {
+		cfg.MaxIdleConns = 10
+	}
+	if cfg.IdleConnTimeout == 0 {
+		cfg.IdleConnTimeout = 90 * time.Second
+	}
+	if cfg.TLSHandshakeTimeout == 0 {
+		cfg.TLSHandshakeTimeout = 5 * time.Second
+	}
+	if cfg.RetryWaitMin == 0 {
+		cfg.RetryWaitMin = 100 * time.Millisecond
+	}
+	if cfg.RetryWaitMax == 0 {
+		cfg.RetryWaitMax = 2 * time.Second
+	}
+	if cfg.RequestsPerHour == 0 {
+		cfg.RequestsPerHour = 1000
+	}
+	if cfg.Burst == 0 {
+		cfg.Burst = 10
+	}
+	if cfg.MaxThrottleWait == 0 {
+		cfg.MaxThrottleWait = 2 * time.Second
+	}
+	if cfg.QuotaCooldown == 0 {
+		cfg.QuotaCooldown = 5 * time.Minute
+	}
+	if cfg.KeyCooldown == 0 {
+		cfg.KeyCooldown = time.Hour
+	}
+	if cfg.CassetteMode == "" {
+		cfg.CassetteMode = os.Getenv(CassetteModeEnv)
+		if cfg.CassetteDir == "" {
+			cfg.CassetteDir = os.Getenv(CassetteDirEnv)
+		}
+	}
+	if cfg.CassetteMode == "" {
+		cfg.CassetteMode = CassettePassthrough
+	}
+	if cfg.BreakerThreshold == 0 {
+		cfg.BreakerThreshold = 5
+	}
+	if cfg.BreakerOpenTimeout == 0 {
+		cfg.BreakerOpenTimeout = 30 * time.Second
+	}
+	if cfg.BreakerHalfOpenCalls == 0 {
+		cfg.BreakerHalfOpenCalls = 1
+	}
+	if cfg.Log == nil {
+		cfg.Log = log.New(ioutil.Discard, "", 0)
+	}
+	return cfg
 }
 
 // StatusCheck returns nil if it can successfully talk to the food data center api. It
@@ -42,11 +220,18 @@ func StatusCheck(ctx context.Context, cfg Config) error {
 		return err
 	}
 	buf := bytes.NewBuffer(b)
-	url, err := buildRequestURL(cfg.APIURL, cfg.ConsumerKey, foodSearchMethod, nil)
+	keys, err := consumerKeys(cfg)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return ErrNoConsumerKey
+	}
+	url, err := buildRequestURL(cfg.APIURL, foodSearchMethod, nil)
 	if err != nil {
 		return err
 	}
-	resp, err := http.Post(url, "application/json", buf)
+	resp, err := http.Post(withKey(url, keys[0]), "application/json", buf)
 	if err != nil {
 		return err
 	}
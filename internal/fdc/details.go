@@ -6,7 +6,6 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"time"
 
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
@@ -15,12 +14,20 @@ import (
 const (
 	foodSearchMethod = "foodSearch"
 	foodDetailMethod = "foodDetail"
+	foodsMethod      = "foods"
+
+	// maxBatchSize is the number of foods the api returns details for in one
+	// call.
+	maxBatchSize = 20
 )
 
 var (
 	// ErrInvalidConfig is used then some of config values does not specified.
 	ErrInvalidConfig = errors.New("config not specified properly")
 
+	// ErrNoConsumerKey is used when none of the consumer keys is given.
+	ErrNoConsumerKey = errors.New("no consumer key of the api is configured")
+
 	// ErrMethodNotSupported is used when we try to call search with api method
 	// which is not supported.
 	ErrMethodNotSupported = errors.New("api method not supported")
@@ -38,10 +45,6 @@ func Details(ctx context.Context, client *Client, fdcID int) (*DetailsInternalRe
 	ctx, span := trace.StartSpan(ctx, "internal.FoodDataCenter.Details")
 	defer span.End()
 
-	// Create a context with a timeout of 10 seconds.
-	ctx, cancel := context.WithTimeout(ctx, 1*time.Millisecond)
-	defer cancel()
-
 	var fdi DetailsInternalResponse
 
 	resp, err := foodDetailsHTTPRequest(ctx, client, fdcID)
@@ -55,17 +58,11 @@ func Details(ctx context.Context, client *Client, fdcID int) (*DetailsInternalRe
 			return nil, errors.Wrap(err, "got error while details request")
 		}
 	}
+	defer resp.Body.Close()
 
 	// Check response status code. If it's not 200 return error.
 	if resp.StatusCode != http.StatusOK {
-		errResp := FoodDataCentralErrorResponse{}
-		err := json.NewDecoder(resp.Body).Decode(&errResp)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to decode error body.")
-		}
-		return nil, errors.Wrapf(ErrFromExternalAPI,
-			"status code: %v, error: %v, message: %v, path: %v",
-			resp.StatusCode, errResp.Error, errResp.Message, errResp.Path)
+		return nil, apiError(resp)
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(&fdi)
@@ -76,6 +73,78 @@ func Details(ctx context.Context, client *Client, fdcID int) (*DetailsInternalRe
 	return &fdi, nil
 }
 
+// ErrBatchIncomplete is returned by DetailsBatch when a chunk of ids fails.
+// FDCIDs are the ids of the failed chunk and of the chunks after it, which
+// were not requested, Err is the error of the failed chunk.
+type ErrBatchIncomplete struct {
+	FDCIDs []int
+	Err    error
+}
+
+// Error implements the error interface.
+func (e *ErrBatchIncomplete) Error() string {
+	return e.Err.Error()
+}
+
+// Cause returns the error of the failed chunk, so errors.Cause gets to it.
+func (e *ErrBatchIncomplete) Cause() error {
+	return e.Err
+}
+
+// DetailsBatch knows how to get information about multiple products from
+// external api. Ids are requested in chunks the api accepts, foods the api does
+// not know are simply missing from the result. When a chunk fails, foods of
+// the chunks fetched before it are returned along with ErrBatchIncomplete.
+func DetailsBatch(ctx context.Context, client *Client, fdcIDs []int) ([]DetailsInternalResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.FoodDataCenter.DetailsBatch")
+	defer span.End()
+
+	url, err := buildRequestURL(client.cfg.APIURL, foodsMethod, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	foods := make([]DetailsInternalResponse, 0, len(fdcIDs))
+	for start := 0; start < len(fdcIDs); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(fdcIDs) {
+			end = len(fdcIDs)
+		}
+
+		chunk, err := detailsChunk(ctx, client, url, fdcIDs[start:end])
+		if err != nil {
+			return foods, &ErrBatchIncomplete{FDCIDs: fdcIDs[start:], Err: err}
+		}
+		foods = append(foods, chunk...)
+	}
+
+	return foods, nil
+}
+
+// detailsChunk requests details of the chunk of ids DetailsBatch is split in.
+func detailsChunk(ctx context.Context, client *Client, url string, fdcIDs []int) ([]DetailsInternalResponse, error) {
+	b, err := json.Marshal(DetailsBatchInternalRequest{FDCIDs: fdcIDs})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal details batch request")
+	}
+
+	resp, err := client.do(ctx, http.MethodPost, url, b)
+	if err != nil {
+		return nil, errors.Wrap(err, "got error while details batch request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var chunk []DetailsInternalResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chunk); err != nil {
+		return nil, errors.Wrap(err, "failed to decode response to food details batch response")
+	}
+	return chunk, nil
+}
+
 // foodDetails make an external call to food data central with given client and
 // fdcID parameter to get response.
 //
@@ -85,25 +154,15 @@ func foodDetailsHTTPRequest(ctx context.Context, c *Client, fdcID int) (*http.Re
 	defer span.End()
 
 	// Create request url with given client parameters.
-	url, err := buildRequestURL(c.cfg.APIURL, c.cfg.ConsumerKey, foodDetailMethod, fdcID)
+	url, err := buildRequestURL(c.cfg.APIURL, foodDetailMethod, fdcID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create a new request.
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	// Make the web call and return any error.
+	resp, err := c.do(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, errors.Wrapf(err, "request url: %s", url)
-	}
-
-	//Bind the new context into the request.
-	req = req.WithContext(ctx)
-
-	// Make the web call and return any error. Do will handle the
-	// context level timeout.
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed on making request")
+		return nil, err
 	}
 
 	return resp, nil
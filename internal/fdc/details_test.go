@@ -0,0 +1,182 @@
+package fdc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/igomonov88/sugar/internal/fdc/fdctest"
+	"github.com/igomonov88/sugar/internal/provider"
+	"github.com/igomonov88/sugar/internal/tests"
+)
+
+func TestDetailsDataTypes(t *testing.T) {
+	t.Log("Given the need to decode details of every data type.")
+	{
+		srv := fdctest.StartServer(t)
+		defer srv.Close()
+
+		client, err := Connect(Config{ConsumerKey: "test", APIURL: srv.APIURL()})
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to connect to Food Data Center client : %s.", failed, err)
+		}
+
+		t.Log("\tWhen the food is Branded.")
+		{
+			d, err := Details(tests.Context(), client, 1104067)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to get details : %s.", failed, err)
+			}
+			if d.Branded == nil || d.Survey != nil || d.Foundation != nil {
+				t.Fatalf("\t%s\tShould decode fields of Branded food only : %+v.", failed, d)
+			}
+			b := d.Branded
+			if b.ServingSize != 50 || b.ServingSizeUnit != "g" || b.HouseholdServingFullText != "1 bar" ||
+				b.LabelNutrients.Carbohydrates == nil || b.LabelNutrients.Carbohydrates.Value != 32 || b.Ingredients == "" {
+				t.Fatalf("\t%s\tShould decode the label : %+v.", failed, b)
+			}
+			if b.LabelNutrients.TransFat != nil {
+				t.Fatalf("\t%s\tShould leave nutrients not on the label nil.", failed)
+			}
+			t.Logf("\t%s\tShould decode the label.", success)
+
+			f := FoodFromDetails(d)
+			if f.Serving == nil || f.Serving.Size != 50 || f.Serving.Description != "1 bar" || len(f.Serving.Nutrients) != 7 {
+				t.Fatalf("\t%s\tShould convert the label to the serving : %+v.", failed, f.Serving)
+			}
+			t.Logf("\t%s\tShould convert the label to the serving.", success)
+		}
+
+		t.Log("\tWhen the food is Survey (FNDDS).")
+		{
+			d, err := Details(tests.Context(), client, 2345173)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to get details : %s.", failed, err)
+			}
+			if d.Survey == nil || d.Survey.FoodCode != "63101000" || len(d.Survey.InputFoods) != 1 {
+				t.Fatalf("\t%s\tShould decode input foods : %+v.", failed, d.Survey)
+			}
+			if FoodFromDetails(d).Serving != nil {
+				t.Fatalf("\t%s\tShould have no labelled serving.", failed)
+			}
+			t.Logf("\t%s\tShould decode input foods.", success)
+		}
+
+		t.Log("\tWhen the food is SR Legacy.")
+		{
+			d, err := Details(tests.Context(), client, 171688)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to get details : %s.", failed, err)
+			}
+			if d.Foundation == nil || d.Foundation.FoodCategory.Description != "Fruits and Fruit Juices" ||
+				len(d.Foundation.NutrientConversionFactors) != 2 {
+				t.Fatalf("\t%s\tShould decode nutrient conversion factors : %+v.", failed, d.Foundation)
+			}
+			t.Logf("\t%s\tShould decode nutrient conversion factors.", success)
+		}
+	}
+}
+
+func TestDetailsBatch(t *testing.T) {
+	t.Log("Given the need to get details of multiple foods in one call.")
+	{
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			var req DetailsBatchInternalRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if len(req.FDCIDs) > maxBatchSize {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			// Pretend the api rejects ids above 100.
+			for _, id := range req.FDCIDs {
+				if id > 100 {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+			}
+
+			// Pretend the api knows only foods with even ids.
+			var foods []DetailsInternalResponse
+			for _, id := range req.FDCIDs {
+				if id%2 == 0 {
+					foods = append(foods, DetailsInternalResponse{FDCID: id, Description: fmt.Sprint("food ", id)})
+				}
+			}
+			json.NewEncoder(w).Encode(foods)
+		}))
+		defer srv.Close()
+
+		client, err := Connect(Config{ConsumerKey: "test", APIURL: srv.URL + "/"})
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to connect to Food Data Center client : %s.", failed, err)
+		}
+
+		t.Log("\tWhen requesting more foods than the api accepts in one call.")
+		{
+			ids := make([]int, 25)
+			for i := range ids {
+				ids[i] = i + 1
+			}
+
+			foods, err := DetailsBatch(tests.Context(), client, ids)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to get details batch : %s.", failed, err)
+			}
+			if got := atomic.LoadInt32(&calls); got != 2 {
+				t.Fatalf("\t%s\tShould split ids into 2 calls, made %d.", failed, got)
+			}
+			if len(foods) != 12 {
+				t.Fatalf("\t%s\tShould get details of every known food, got %d.", failed, len(foods))
+			}
+			t.Logf("\t%s\tShould be able to get details batch.", success)
+		}
+
+		t.Log("\tWhen a later chunk of the batch fails.")
+		{
+			ids := make([]int, 25)
+			for i := range ids {
+				ids[i] = i + 1
+			}
+			ids[24] = 101
+
+			foods, err := DetailsBatch(tests.Context(), client, ids)
+			be, ok := err.(*ErrBatchIncomplete)
+			if !ok || len(be.FDCIDs) != 5 || be.FDCIDs[0] != 21 {
+				t.Fatalf("\t%s\tShould get ids of the failed chunk with the error : %v.", failed, err)
+			}
+			if len(foods) != 10 {
+				t.Fatalf("\t%s\tShould keep foods of the chunks fetched before, got %d.", failed, len(foods))
+			}
+			t.Logf("\t%s\tShould keep foods of the chunks fetched before the failed one.", success)
+		}
+
+		t.Log("\tWhen the provider gets a batch which fails part way.")
+		{
+			ids := make([]string, 25)
+			for i := range ids {
+				ids[i] = strconv.Itoa(i + 1)
+			}
+			ids[24] = "101"
+
+			foods, err := NewProvider(client).DetailsBatch(tests.Context(), ids)
+			be, ok := err.(*provider.BatchError)
+			if !ok || len(be.IDs) != 5 || be.IDs[0] != "21" {
+				t.Fatalf("\t%s\tShould get ids of the failed chunk with the error : %v.", failed, err)
+			}
+			if len(foods) != 10 || foods[0].ID != "2" || foods[0].Provider != provider.FDC {
+				t.Fatalf("\t%s\tShould keep foods of the chunks fetched before : %+v.", failed, foods)
+			}
+			t.Logf("\t%s\tShould return foods fetched before with ids of the failed chunk.", success)
+		}
+	}
+}
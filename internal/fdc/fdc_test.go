@@ -1,8 +1,11 @@
 package fdc
 
 import (
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/igomonov88/sugar/internal/fdc/fdctest"
 	"github.com/igomonov88/sugar/internal/tests"
 )
 
@@ -19,9 +22,12 @@ func TestFoodDataCenterClient(t *testing.T) {
 		{
 			ctx := tests.Context()
 
+			srv := fdctest.StartServer(t)
+			defer srv.Close()
+
 			cfg := Config{
-				ConsumerKey: "07qblbARRNts5zU45YOPyC8NDQc1iuHQgTqLwbTL",
-				APIURL:      "https://api.nal.usda.gov/fdc/v1/",
+				ConsumerKey: "test",
+				APIURL:      srv.APIURL(),
 			}
 
 			client, err := Connect(cfg)
@@ -32,7 +38,7 @@ func TestFoodDataCenterClient(t *testing.T) {
 
 			t.Log("\tWhen handling the request to Food Data Center.")
 			{
-				resp, err := SearchOutput(ctx, client, "mc donalds cheeseburger")
+				resp, err := SearchOutput(ctx, client, SearchInternalRequest{GeneralSearchInput: "mc donalds cheeseburger"})
 				if err != nil {
 					t.Fatalf("\t%s\tShould be able make search request to Food Data Center: %s.", failed, err)
 				}
@@ -60,3 +66,66 @@ func TestFoodDataCenterClient(t *testing.T) {
 		}
 	}
 }
+
+func TestFoodDataCenterFailures(t *testing.T) {
+	t.Log("Given the need to handle failures of food data center api.")
+	{
+		ctx := tests.Context()
+
+		srv := fdctest.StartServer(t)
+		defer srv.Close()
+
+		client, err := Connect(Config{
+			ConsumerKey:    "test",
+			APIURL:         srv.APIURL(),
+			RequestTimeout: 50 * time.Millisecond,
+			MaxRetries:     1,
+			RetryWaitMin:   time.Millisecond,
+			RetryWaitMax:   time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to connect to Food Data Center client : %s.", failed, err)
+		}
+
+		t.Log("\tWhen the api answers with malformed body.")
+		{
+			srv.SetMalformed(true)
+			if _, err := Details(ctx, client, 171688); err == nil {
+				t.Fatalf("\t%s\tShould get an error for malformed body.", failed)
+			}
+			srv.SetMalformed(false)
+			t.Logf("\t%s\tShould get an error for malformed body.", success)
+		}
+
+		t.Log("\tWhen the api answers slower than request timeout.")
+		{
+			srv.SetLatency(time.Second)
+			if _, err := Details(ctx, client, 171688); err == nil {
+				t.Fatalf("\t%s\tShould get an error for slow api.", failed)
+			}
+			srv.SetLatency(0)
+			t.Logf("\t%s\tShould get an error for slow api.", success)
+		}
+
+		t.Log("\tWhen the api fails once with error status.")
+		{
+			srv.FailNext(http.StatusBadGateway, 1)
+			resp, err := Details(ctx, client, 171688)
+			if err != nil {
+				t.Fatalf("\t%s\tShould get details on retry : %s.", failed, err)
+			}
+			if resp.Description == "" {
+				t.Fatalf("\t%s\tShould get details of the food on retry.", failed)
+			}
+			t.Logf("\t%s\tShould get details on retry.", success)
+		}
+
+		t.Log("\tWhen the api does not know the food.")
+		{
+			if _, err := Details(ctx, client, 1); err == nil {
+				t.Fatalf("\t%s\tShould get an error for unknown food.", failed)
+			}
+			t.Logf("\t%s\tShould get an error for unknown food.", success)
+		}
+	}
+}
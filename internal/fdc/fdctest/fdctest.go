@@ -0,0 +1,280 @@
+// Package fdctest provides an in-process fake of Food Data Central api, so
+// tests of the code calling the api run without network and real consumer key.
+package fdctest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Server is the fake Food Data Central api. It serves search, details and
+// multi-ID details routes from fixture files:
+//
+//	search/<search input slug>.json
+//	details/<fdcID>.json
+//
+// Search input slug is the lowercase search input with every run of non
+// alphanumeric characters replaced by dash, e.g. "mc-donalds-cheeseburger".
+type Server struct {
+	*httptest.Server
+
+	dir string
+
+	mu        sync.Mutex
+	latency   time.Duration
+	failures  []int
+	malformed bool
+	limit     int
+	remaining int
+	requests  int
+}
+
+// StartServer starts the fake api serving fixtures shipped with this package.
+// It does not return errors as this intended for testing only. Call Close when
+// the test is done with the server.
+func StartServer(t *testing.T) *Server {
+	t.Helper()
+
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatal("could not locate fdctest fixtures")
+	}
+
+	return StartServerWithFixtures(t, filepath.Join(filepath.Dir(file), "testdata"))
+}
+
+// StartServerWithFixtures starts the fake api serving fixtures from dir.
+func StartServerWithFixtures(t *testing.T, dir string) *Server {
+	t.Helper()
+
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("fdctest fixtures: %v", err)
+	}
+
+	s := Server{
+		dir:       dir,
+		limit:     -1,
+		remaining: -1,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return &s
+}
+
+// APIURL returns the value for fdc.Config.APIURL pointing to the server.
+func (s *Server) APIURL() string {
+	return s.URL + "/"
+}
+
+// SetLatency makes the server wait d before answering every request.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	s.latency = d
+	s.mu.Unlock()
+}
+
+// FailNext makes the server answer next n requests with given status.
+func (s *Server) FailNext(status int, n int) {
+	s.mu.Lock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, status)
+	}
+	s.mu.Unlock()
+}
+
+// SetMalformed makes the server answer with truncated json bodies.
+func (s *Server) SetMalformed(malformed bool) {
+	s.mu.Lock()
+	s.malformed = malformed
+	s.mu.Unlock()
+}
+
+// SetQuota makes the server report quota of the consumer key in
+// X-RateLimit headers. Remaining requests are decremented by every request
+// and the server answers 429 when none are left.
+func (s *Server) SetQuota(limit, remaining int) {
+	s.mu.Lock()
+	s.limit = limit
+	s.remaining = remaining
+	s.mu.Unlock()
+}
+
+// Requests returns the number of requests the server received.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// handle routes the request of the api.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	latency := s.latency
+	malformed := s.malformed
+	var failure int
+	if len(s.failures) != 0 {
+		failure = s.failures[0]
+		s.failures = s.failures[1:]
+	}
+	limited := false
+	if s.limit >= 0 {
+		if s.remaining > 0 {
+			s.remaining--
+		} else {
+			limited = true
+		}
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(s.remaining))
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch {
+	case r.URL.Query().Get("api_key") == "":
+		s.apiKeyError(w, http.StatusForbidden, "API_KEY_MISSING")
+		return
+	case limited:
+		w.Header().Set("Retry-After", "3600")
+		s.apiKeyError(w, http.StatusTooManyRequests, "OVER_RATE_LIMIT")
+		return
+	case failure != 0:
+		s.error(w, r, failure)
+		return
+	}
+
+	var body []byte
+	var status int
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/search":
+		body, status = s.search(r)
+	case r.Method == http.MethodPost && r.URL.Path == "/foods":
+		body, status = s.foods(r)
+	case r.Method == http.MethodGet && isID(strings.TrimPrefix(r.URL.Path, "/")):
+		body, status = s.details(strings.TrimPrefix(r.URL.Path, "/"))
+	default:
+		status = http.StatusNotFound
+	}
+
+	if status != http.StatusOK {
+		s.error(w, r, status)
+		return
+	}
+
+	if malformed {
+		body = body[:len(body)/2]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// search answers with the fixture of the search input, or with empty result
+// when there is no such fixture.
+func (s *Server) search(r *http.Request) ([]byte, int) {
+	var req struct {
+		GeneralSearchInput string `json:"generalSearchInput"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, http.StatusBadRequest
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, "search", Slug(req.GeneralSearchInput)+".json"))
+	if err != nil {
+		empty := `{"foodSearchCriteria":{"generalSearchInput":` + strconv.Quote(req.GeneralSearchInput) +
+			`,"pageNumber":1,"requireAllWords":false},"totalHits":0,"currentPage":1,"totalPages":0,"foods":[]}`
+		return []byte(empty), http.StatusOK
+	}
+	return b, http.StatusOK
+}
+
+// details answers with the fixture of the food.
+func (s *Server) details(fdcID string) ([]byte, int) {
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, "details", fdcID+".json"))
+	if err != nil {
+		return nil, http.StatusNotFound
+	}
+	return b, http.StatusOK
+}
+
+// foods answers with the list of fixtures of requested foods, the foods
+// without fixture are left out like the api does.
+func (s *Server) foods(r *http.Request) ([]byte, int) {
+	var req struct {
+		FDCIDs []int `json:"fdcIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.FDCIDs) > 20 {
+		return nil, http.StatusBadRequest
+	}
+
+	foods := make([]json.RawMessage, 0, len(req.FDCIDs))
+	for _, id := range req.FDCIDs {
+		if b, status := s.details(strconv.Itoa(id)); status == http.StatusOK {
+			foods = append(foods, b)
+		}
+	}
+
+	b, err := json.Marshal(foods)
+	if err != nil {
+		return nil, http.StatusInternalServerError
+	}
+	return b, http.StatusOK
+}
+
+// error answers with the error body of Food Data Central api.
+func (s *Server) error(w http.ResponseWriter, r *http.Request, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+		Path    string `json:"path"`
+	}{
+		Error:   http.StatusText(status),
+		Message: "fdctest: " + http.StatusText(status),
+		Path:    r.URL.Path,
+	})
+}
+
+// apiKeyError answers with the error body of api.data.gov, which stands in
+// front of Food Data Central api and checks the consumer keys.
+func (s *Server) apiKeyError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(`{"error":{"code":"` + code + `","message":"fdctest: ` + http.StatusText(status) + `"}}`))
+}
+
+var nonAlnum = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slug returns the name of the search fixture for given search input.
+func Slug(searchInput string) string {
+	return strings.Trim(nonAlnum.ReplaceAllString(strings.ToLower(searchInput), "-"), "-")
+}
+
+// isID reports whether path segment is the fdcID.
+func isID(s string) bool {
+	if s == "" {
+		return false
+	}
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
@@ -0,0 +1,213 @@
+package fdc
+
+import (
+	"bufio"
+	"expvar"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// keyHealth holds health of every consumer key under /debug/vars, by the label
+// of the key. Keys themselves are never published.
+var keyHealth = expvar.NewMap("fdc_keys")
+
+// consumerKey is the consumer key of the pool with its own quota.
+type consumerKey struct {
+	value string
+	label string
+	quota *quota
+
+	// limitedAt is when the api limited or refused the key the last time,
+	// usedAt is when the key was picked the last time.
+	limitedAt time.Time
+	usedAt    time.Time
+
+	requests  *expvar.Int
+	limited   *expvar.Int
+	forbidden *expvar.Int
+}
+
+// keyPool rotates calls across consumer keys. The key limited least recently
+// is picked, so the load moves away from keys which hit their quota, and keys
+// are put on cooldown when the api answers 429 or 403 for them.
+type keyPool struct {
+	mu   sync.Mutex
+	keys []*consumerKey
+
+	// forbiddenCooldown is how long the key refused with 403 is not used.
+	forbiddenCooldown time.Duration
+}
+
+// newKeyPool constructs the pool of given keys, each with quota built by
+// newQuota.
+func newKeyPool(keys []string, forbiddenCooldown time.Duration, newQuota func() *quota) *keyPool {
+	p := keyPool{
+		keys:              make([]*consumerKey, len(keys)),
+		forbiddenCooldown: forbiddenCooldown,
+	}
+	for i, v := range keys {
+		k := consumerKey{
+			value:     v,
+			label:     keyLabel(i, v),
+			quota:     newQuota(),
+			requests:  new(expvar.Int),
+			limited:   new(expvar.Int),
+			forbidden: new(expvar.Int),
+		}
+		p.keys[i] = &k
+
+		health := new(expvar.Map).Init()
+		health.Set("requests", k.requests)
+		health.Set("limited", k.limited)
+		health.Set("forbidden", k.forbidden)
+		health.Set("cooldown_seconds", expvar.Func(func() interface{} {
+			return int64(k.quota.retryIn().Seconds())
+		}))
+		health.Set("remaining", expvar.Func(func() interface{} {
+			k.quota.mu.Lock()
+			defer k.quota.mu.Unlock()
+			return k.quota.remaining
+		}))
+		keyHealth.Set(k.label, health)
+	}
+	return &p
+}
+
+// pick returns the key to make the call with. Of the keys not on cooldown the
+// one limited least recently is picked, and the one used least recently when
+// there are several. ErrQuotaExhausted is returned when every key is on
+// cooldown.
+func (p *keyPool) pick() (*consumerKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var picked *consumerKey
+	retryIn := time.Duration(-1)
+	for _, k := range p.keys {
+		if d := k.quota.retryIn(); d > 0 {
+			if retryIn < 0 || d < retryIn {
+				retryIn = d
+			}
+			continue
+		}
+		if picked == nil || k.limitedAt.Before(picked.limitedAt) ||
+			(k.limitedAt.Equal(picked.limitedAt) && k.usedAt.Before(picked.usedAt)) {
+			picked = k
+		}
+	}
+
+	if picked == nil {
+		m.exhausted.Add(1)
+		return nil, &ErrQuotaExhausted{RetryAfter: retryIn}
+	}
+	picked.usedAt = time.Now()
+	return picked, nil
+}
+
+// observe records the response of the api to the call made with the key.
+func (p *keyPool) observe(k *consumerKey, resp *http.Response) {
+	k.quota.observe(resp)
+	k.requests.Add(1)
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		k.limited.Add(1)
+	case http.StatusForbidden:
+		k.forbidden.Add(1)
+		k.quota.block(p.forbiddenCooldown)
+	default:
+		return
+	}
+
+	p.mu.Lock()
+	k.limitedAt = time.Now()
+	p.mu.Unlock()
+}
+
+// available reports whether any key is not on cooldown.
+func (p *keyPool) available() bool {
+	for _, k := range p.keys {
+		if k.quota.retryIn() <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// retryIn returns how long every key stays on cooldown.
+func (p *keyPool) retryIn() time.Duration {
+	var min time.Duration
+	for i, k := range p.keys {
+		if d := k.quota.retryIn(); i == 0 || d < min {
+			min = d
+		}
+	}
+	return min
+}
+
+// keyLabel returns the name of the key which is safe to publish.
+func keyLabel(i int, key string) string {
+	suffix := key
+	if len(suffix) > 4 {
+		suffix = suffix[len(suffix)-4:]
+	}
+	return "key" + strconv.Itoa(i+1) + "-" + suffix
+}
+
+// consumerKeys returns the keys of the config without duplicates. Keys are
+// taken from ConsumerKey, ConsumerKeys and ConsumerKeysFile, in this order,
+// ConsumerKey is only used when it is set.
+func consumerKeys(cfg Config) ([]string, error) {
+	var keys []string
+	if cfg.ConsumerKey != "" {
+		keys = append(keys, cfg.ConsumerKey)
+	}
+	keys = append(keys, cfg.ConsumerKeys...)
+
+	if cfg.ConsumerKeysFile != "" {
+		f, err := os.Open(cfg.ConsumerKeysFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "opening consumer keys file")
+		}
+		defer f.Close()
+
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			keys = append(keys, s.Text())
+		}
+		if err := s.Err(); err != nil {
+			return nil, errors.Wrap(err, "reading consumer keys file")
+		}
+	}
+
+	seen := make(map[string]bool, len(keys))
+	unique := keys[:0]
+	for _, k := range keys {
+		k = strings.TrimSpace(k)
+		if k == "" || strings.HasPrefix(k, "#") || seen[k] {
+			continue
+		}
+		seen[k] = true
+		unique = append(unique, k)
+	}
+	return unique, nil
+}
+
+// withKey returns the url with consumer key replaced by given one.
+func withKey(rawURL, key string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	q.Set("api_key", key)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
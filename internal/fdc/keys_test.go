@@ -0,0 +1,126 @@
+package fdc
+
+import (
+	"expvar"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/sugar/internal/tests"
+)
+
+func TestKeyPool(t *testing.T) {
+	t.Log("Given the need to rotate calls across several consumer keys.")
+	{
+		var mu sync.Mutex
+		used := make(map[string]int)
+		status := make(map[string]int)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.URL.Query().Get("api_key")
+			mu.Lock()
+			used[key]++
+			s := status[key]
+			mu.Unlock()
+			if s != 0 {
+				w.WriteHeader(s)
+				return
+			}
+			w.Write([]byte(`{"description":"apple, raw"}`))
+		}))
+		defer srv.Close()
+
+		f, err := ioutil.TempFile("", "keys")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create keys file : %s.", failed, err)
+		}
+		defer os.Remove(f.Name())
+		f.WriteString("# spare keys\nkey-b\n\nkey-a\n")
+		f.Close()
+
+		if _, err := Connect(Config{ConsumerKeys: []string{" ", "# none"}, APIURL: srv.URL + "/"}); err != ErrNoConsumerKey {
+			t.Fatalf("\t%s\tShould not connect without consumer keys, got %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould not connect without consumer keys.", success)
+
+		client, err := Connect(Config{
+			ConsumerKey:      "key-a",
+			ConsumerKeysFile: f.Name(),
+			APIURL:           srv.URL + "/",
+		})
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to connect to Food Data Center client : %s.", failed, err)
+		}
+		if len(client.keys.keys) != 2 {
+			t.Fatalf("\t%s\tShould read keys without duplicates, got %d keys.", failed, len(client.keys.keys))
+		}
+		t.Logf("\t%s\tShould read keys without duplicates.", success)
+
+		t.Log("\tWhen the keys are healthy.")
+		{
+			for i := 0; i < 4; i++ {
+				if _, err := Details(tests.Context(), client, 1); err != nil {
+					t.Fatalf("\t%s\tShould be able to get details : %s.", failed, err)
+				}
+			}
+			if used["key-a"] != 2 || used["key-b"] != 2 {
+				t.Fatalf("\t%s\tShould spread calls across keys, got %v.", failed, used)
+			}
+			t.Logf("\t%s\tShould spread calls across keys.", success)
+		}
+
+		t.Log("\tWhen the api limits one of the keys.")
+		{
+			mu.Lock()
+			status["key-a"] = http.StatusTooManyRequests
+			mu.Unlock()
+
+			for i := 0; i < 3; i++ {
+				if _, err := Details(tests.Context(), client, 1); err != nil {
+					t.Fatalf("\t%s\tShould get details with another key : %s.", failed, err)
+				}
+			}
+			if used["key-a"] != 3 || used["key-b"] != 5 {
+				t.Fatalf("\t%s\tShould put the limited key on cooldown, got %v.", failed, used)
+			}
+			t.Logf("\t%s\tShould put the limited key on cooldown.", success)
+
+			health := keyHealth.Get(client.keys.keys[0].label).(*expvar.Map)
+			if got := health.Get("limited").String(); got != "1" {
+				t.Fatalf("\t%s\tShould publish limited calls of the key, got %s.", failed, got)
+			}
+			if got := health.Get("cooldown_seconds").String(); got == "0" {
+				t.Fatalf("\t%s\tShould publish cooldown of the key.", failed)
+			}
+			if strings.Contains(keyHealth.String(), "key-a") {
+				t.Fatalf("\t%s\tShould not publish the key itself.", failed)
+			}
+			t.Logf("\t%s\tShould publish health of the key.", success)
+		}
+
+		t.Log("\tWhen the api refuses the other key too.")
+		{
+			mu.Lock()
+			status["key-b"] = http.StatusForbidden
+			mu.Unlock()
+
+			_, err := Details(tests.Context(), client, 1)
+			if err == nil {
+				t.Fatalf("\t%s\tShould fail with the key refused.", failed)
+			}
+			_, err = Details(tests.Context(), client, 1)
+			if _, ok := errors.Cause(err).(*ErrQuotaExhausted); !ok {
+				t.Fatalf("\t%s\tShould get ErrQuotaExhausted with every key on cooldown, got %v.", failed, err)
+			}
+			if used["key-b"] != 6 {
+				t.Fatalf("\t%s\tShould not call the api with every key on cooldown, got %v.", failed, used)
+			}
+			t.Logf("\t%s\tShould get ErrQuotaExhausted with every key on cooldown.", success)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package fdc
 
+import "encoding/json"
+
 // SearchRequest represents a request query to our api
 type SearchRequest struct {
 	// SearchInput is the search string for given food
@@ -18,7 +20,7 @@ type DetailsRequest struct {
 	FDCID int `json:"fdc_id"`
 
 	// SearchInput word which was used before get FDCID
-	SearchInput string `json:"search_input, omitempty"`
+	SearchInput string `json:"search_input,omitempty"`
 }
 
 // DetailsResponse
@@ -40,32 +42,32 @@ type SearchInternalRequest struct {
 
 	// Specific data types to include in search e.g.
 	// ["Survey (FNDDS)", "Foundation", "Branded"]
-	IncludeDataTypeList []string `json:"includeDataTypeList"`
+	IncludeDataTypeList []string `json:"includeDataTypeList,omitempty"`
 
 	// Ingredients The list of ingredients (as it appears on the product label)
-	Ingredients string `json:"ingredients"`
+	Ingredients string `json:"ingredients,omitempty"`
 
 	// Brand owner for the food
-	BrandOwner string `json:"brandOwner"`
+	BrandOwner string `json:"brandOwner,omitempty"`
 
 	// RequireAllWords bool flag, used to include all words from general search
 	// input to search query.
 	// When true, the search will only return foods that contain all of the
 	// words that were entered in the search field.
 	// Should be converted from bool to string.
-	RequireAllWords string `json:"requireAllWords"`
+	RequireAllWords string `json:"requireAllWords,omitempty"`
 
 	// PageNumber the page of results to return. Should be converted to string
 	// from int.
-	PageNumber string `json:"pageNumber"`
+	PageNumber string `json:"pageNumber,omitempty"`
 
 	// SortField is name of the field by which to sort.
 	// Possible sorting options: lowercaseDescription.keyword, dataType.keyword,
 	// publishedDate, fdcId. E.g. "sortField":"publishedDate"
-	SortField string `json:"sortField"`
+	SortField string `json:"sortField,omitempty"`
 
 	// SortDirection the direction of the sorting, either "asc" or "desc".
-	SortDirection string `json:"sortDirection"`
+	SortDirection string `json:"sortDirection,omitempty"`
 }
 
 // SearchInternalResponse represents the request result from food data center
@@ -108,17 +110,164 @@ type Food struct {
 	Description string `json:"description"`
 	// BrandOwner brand owner for the food
 	BrandOwner string `json:"brandOwner"`
+	// GTINUPC is GTIN or UPC code of branded food
+	GTINUPC string `json:"gtinUpc"`
+	// DataType is the data type of the food e.g. Branded, Foundation
+	DataType string `json:"dataType"`
+	// PublishedDate is the date the food was published to FoodData Central
+	PublishedDate string `json:"publishedDate"`
+	// FoodCategory is the category of the food e.g. Fruits and Fruit Juices
+	FoodCategory string `json:"foodCategory"`
 }
 
 type DetailsInternalRequest struct {
 	FDCID int `json:"fdcId"`
 }
 
+// DetailsBatchInternalRequest represents the request of details of multiple
+// foods which send to food data central api.
+type DetailsBatchInternalRequest struct {
+	// FDCIDs list of ids of the foods, the api accepts up to 20 of them.
+	FDCIDs []int `json:"fdcIds"`
+}
+
+// DetailsInternalResponse represents details of the food got from food data
+// central api. The api answers with different schema for every data type, so
+// the fields common to all of them are decoded here, and the fields of the
+// data type of the food are decoded into one of Branded, Survey or Foundation.
 type DetailsInternalResponse struct {
-	FoodClass     string         `json:"foodClass"`
-	Description   string         `json:"description"`
-	FoodNutrients []FoodNutrient `json:"foodNutrients"`
-	FoodPortions  []FoodPortion  `json:"foodPortions"`
+	FDCID           int            `json:"fdcId"`
+	DataType        string         `json:"dataType"`
+	FoodClass       string         `json:"foodClass"`
+	Description     string         `json:"description"`
+	PublicationDate string         `json:"publicationDate"`
+	BrandOwner      string         `json:"brandOwner"`
+	GTINUPC         string         `json:"gtinUpc"`
+	FoodNutrients   []FoodNutrient `json:"foodNutrients"`
+	FoodPortions    []FoodPortion  `json:"foodPortions"`
+
+	Branded    *BrandedDetails    `json:"-"`
+	Survey     *SurveyDetails     `json:"-"`
+	Foundation *FoundationDetails `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler, it discriminates the schema of the
+// details by dataType.
+func (d *DetailsInternalResponse) UnmarshalJSON(b []byte) error {
+	type common DetailsInternalResponse
+	if err := json.Unmarshal(b, (*common)(d)); err != nil {
+		return err
+	}
+
+	var v interface{}
+	switch d.DataType {
+	case DataTypeBranded:
+		d.Branded = new(BrandedDetails)
+		v = d.Branded
+	case DataTypeSurvey:
+		d.Survey = new(SurveyDetails)
+		v = d.Survey
+	case DataTypeFoundation, DataTypeSRLegacy:
+		d.Foundation = new(FoundationDetails)
+		v = d.Foundation
+	default:
+		return nil
+	}
+	return json.Unmarshal(b, v)
+}
+
+// BrandedDetails represents the fields of details of Branded food, taken from
+// the label of the product.
+type BrandedDetails struct {
+	BrandedFoodCategory string `json:"brandedFoodCategory"`
+
+	// Ingredients is the list of ingredients as it appears on the label.
+	Ingredients string `json:"ingredients"`
+
+	// ServingSize is the amount of the labelled serving in ServingSizeUnit,
+	// which is g or ml, HouseholdServingFullText describes it e.g. "1 bar".
+	ServingSize              float64 `json:"servingSize"`
+	ServingSizeUnit          string  `json:"servingSizeUnit"`
+	HouseholdServingFullText string  `json:"householdServingFullText"`
+
+	// LabelNutrients are amounts of nutrients per labelled serving.
+	LabelNutrients LabelNutrients `json:"labelNutrients"`
+}
+
+// LabelNutrients represents the nutrition facts of the label, nutrients not on
+// the label are nil.
+type LabelNutrients struct {
+	Calories      *LabelNutrient `json:"calories"`
+	Fat           *LabelNutrient `json:"fat"`
+	SaturatedFat  *LabelNutrient `json:"saturatedFat"`
+	TransFat      *LabelNutrient `json:"transFat"`
+	Cholesterol   *LabelNutrient `json:"cholesterol"`
+	Sodium        *LabelNutrient `json:"sodium"`
+	Carbohydrates *LabelNutrient `json:"carbohydrates"`
+	Fiber         *LabelNutrient `json:"fiber"`
+	Sugars        *LabelNutrient `json:"sugars"`
+	Protein       *LabelNutrient `json:"protein"`
+	Calcium       *LabelNutrient `json:"calcium"`
+	Iron          *LabelNutrient `json:"iron"`
+	Potassium     *LabelNutrient `json:"potassium"`
+}
+
+// LabelNutrient represents the amount of nutrient per labelled serving.
+type LabelNutrient struct {
+	Value float64 `json:"value"`
+}
+
+// SurveyDetails represents the fields of details of Survey (FNDDS) food, which
+// is the food as eaten, made of input foods.
+type SurveyDetails struct {
+	FoodCode          string            `json:"foodCode"`
+	WWEIAFoodCategory WWEIAFoodCategory `json:"wweiaFoodCategory"`
+	InputFoods        []InputFood       `json:"inputFoods"`
+}
+
+// WWEIAFoodCategory represents the category of What We Eat In America survey.
+type WWEIAFoodCategory struct {
+	Code        int    `json:"wweiaFoodCategoryCode"`
+	Description string `json:"wweiaFoodCategoryDescription"`
+}
+
+// InputFood represents the ingredient of Survey (FNDDS) food.
+type InputFood struct {
+	ID                    int     `json:"id"`
+	FoodDescription       string  `json:"foodDescription"`
+	IngredientCode        int     `json:"ingredientCode"`
+	IngredientDescription string  `json:"ingredientDescription"`
+	IngredientWeight      float64 `json:"ingredientWeight"`
+	PortionCode           string  `json:"portionCode"`
+	PortionDescription    string  `json:"portionDescription"`
+	Amount                float64 `json:"amount"`
+	Unit                  string  `json:"unit"`
+	SequenceNumber        int     `json:"sequenceNumber"`
+}
+
+// FoundationDetails represents the fields of details of Foundation and SR
+// Legacy foods, which are analyzed by USDA.
+type FoundationDetails struct {
+	FoodCategory              FoodCategory               `json:"foodCategory"`
+	NutrientConversionFactors []NutrientConversionFactor `json:"nutrientConversionFactors"`
+}
+
+// FoodCategory represents the category of Foundation and SR Legacy foods.
+type FoodCategory struct {
+	ID          int    `json:"id"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// NutrientConversionFactor represents the factor used to calculate protein or
+// energy of the food. Type is .ProteinConversionFactor with Value set, or
+// .CalorieConversionFactor with protein, fat and carbohydrate values set.
+type NutrientConversionFactor struct {
+	Type              string  `json:"type"`
+	Value             float64 `json:"value"`
+	ProteinValue      float64 `json:"proteinValue"`
+	FatValue          float64 `json:"fatValue"`
+	CarbohydrateValue float64 `json:"carbohydrateValue"`
 }
 
 // FoodDataCentralErrorResponse used to serialize error response from fdc api
@@ -136,6 +285,7 @@ type FoodNutrient struct {
 }
 
 type Nutrient struct {
+	Number   string `json:"number"`
 	Name     string `json:"name"`
 	Rank     int    `json:"rank"`
 	UnitName string `json:"unitName"`
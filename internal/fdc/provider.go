@@ -0,0 +1,230 @@
+package fdc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/sugar/internal/nutrients"
+	"github.com/igomonov88/sugar/internal/provider"
+)
+
+// Provider is the provider.FoodProvider backed by food data central api.
+type Provider struct {
+	client *Client
+}
+
+// NewProvider constructs the provider calling the api with given client.
+func NewProvider(client *Client) *Provider {
+	return &Provider{client: client}
+}
+
+// Name implements provider.FoodProvider.
+func (p *Provider) Name() string {
+	return provider.FDC
+}
+
+// Search implements provider.FoodProvider.
+func (p *Provider) Search(ctx context.Context, q provider.Query) (*provider.SearchResult, error) {
+	req := SearchInternalRequest{
+		GeneralSearchInput:  q.SearchInput,
+		IncludeDataTypeList: q.DataTypes,
+		BrandOwner:          q.BrandOwner,
+		Ingredients:         q.Ingredients,
+		SortField:           q.SortField,
+		SortDirection:       q.SortDirection,
+	}
+	if q.Page > 0 {
+		req.PageNumber = strconv.Itoa(q.Page)
+	}
+	if q.RequireAllWords {
+		req.RequireAllWords = strconv.FormatBool(q.RequireAllWords)
+	}
+
+	sr, err := SearchOutput(ctx, p.client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := provider.SearchResult{
+		TotalHits:   sr.TotalHits,
+		CurrentPage: sr.CurrentPage,
+		TotalPages:  sr.TotalPages,
+		Foods:       make([]provider.Item, len(sr.Foods)),
+	}
+	for i := range sr.Foods {
+		result.Foods[i] = provider.Item{
+			Provider:      provider.FDC,
+			ID:            strconv.Itoa(sr.Foods[i].FDCID),
+			Description:   sr.Foods[i].Description,
+			BrandOwner:    sr.Foods[i].BrandOwner,
+			Barcode:       sr.Foods[i].GTINUPC,
+			DataType:      sr.Foods[i].DataType,
+			PublishedDate: sr.Foods[i].PublishedDate,
+			FoodCategory:  sr.Foods[i].FoodCategory,
+		}
+	}
+	return &result, nil
+}
+
+// Details implements provider.FoodProvider, id is the fdcID of the food.
+func (p *Provider) Details(ctx context.Context, id string) (*provider.Food, error) {
+	fdcID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, errors.Wrapf(provider.ErrNotFound, "fdc id %q", id)
+	}
+
+	d, err := Details(ctx, p.client, fdcID)
+	if err != nil {
+		return nil, err
+	}
+	return FoodFromDetails(d), nil
+}
+
+// DetailsBatch implements provider.BatchProvider, ids are fdcIDs of the foods.
+// Ids which are not fdcIDs are left out like foods the api does not know.
+func (p *Provider) DetailsBatch(ctx context.Context, ids []string) ([]*provider.Food, error) {
+	fdcIDs := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if fdcID, err := strconv.Atoi(id); err == nil {
+			fdcIDs = append(fdcIDs, fdcID)
+		}
+	}
+
+	list, err := DetailsBatch(ctx, p.client, fdcIDs)
+	foods := make([]*provider.Food, len(list))
+	for i := range list {
+		foods[i] = FoodFromDetails(&list[i])
+	}
+	if err != nil {
+		be := provider.BatchError{Err: err}
+		failed := fdcIDs
+		if bi, ok := err.(*ErrBatchIncomplete); ok {
+			be.Err, failed = bi.Err, bi.FDCIDs
+		}
+		for _, fdcID := range failed {
+			be.IDs = append(be.IDs, strconv.Itoa(fdcID))
+		}
+		return foods, &be
+	}
+	return foods, nil
+}
+
+// Lookup implements provider.FoodProvider. The api has no barcode lookup, so
+// branded foods are searched for the barcode and the food with the same
+// GTIN or UPC is taken. Most foods of the api have 12 digit UPC codes, so the
+// barcode is searched for without leading zeros beyond them.
+func (p *Provider) Lookup(ctx context.Context, barcode string) (*provider.Food, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.FoodDataCenter.Lookup")
+	defer span.End()
+
+	input := provider.NormalizeBarcode(barcode)
+	for len(input) > 12 && input[0] == '0' {
+		input = input[1:]
+	}
+
+	req := SearchInternalRequest{
+		GeneralSearchInput:  input,
+		IncludeDataTypeList: []string{DataTypeBranded},
+	}
+	sr, err := SearchOutput(ctx, p.client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	want := provider.NormalizeBarcode(barcode)
+	for i := range sr.Foods {
+		if sr.Foods[i].GTINUPC == "" || provider.NormalizeBarcode(sr.Foods[i].GTINUPC) != want {
+			continue
+		}
+		d, err := Details(ctx, p.client, sr.Foods[i].FDCID)
+		if err != nil {
+			return nil, err
+		}
+		return FoodFromDetails(d), nil
+	}
+
+	return nil, errors.Wrapf(provider.ErrNotFound, "barcode %s", barcode)
+}
+
+// FoodFromDetails converts details of the food got from the api to the food of
+// the provider.
+func FoodFromDetails(d *DetailsInternalResponse) *provider.Food {
+	f := provider.Food{
+		Provider:    provider.FDC,
+		ID:          strconv.Itoa(d.FDCID),
+		Description: d.Description,
+		BrandOwner:  d.BrandOwner,
+		Barcode:     d.GTINUPC,
+		Nutrients:   make([]provider.Nutrient, len(d.FoodNutrients)),
+		Portions:    make([]provider.Portion, len(d.FoodPortions)),
+	}
+	for i, n := range d.FoodNutrients {
+		f.Nutrients[i] = provider.Nutrient{
+			Number:   n.Nutrient.Number,
+			Name:     n.Nutrient.Name,
+			Amount:   n.Amount,
+			UnitName: n.Nutrient.UnitName,
+			Rank:     n.Nutrient.Rank,
+		}
+	}
+	for i, fp := range d.FoodPortions {
+		f.Portions[i] = provider.Portion{
+			GramWeight:  fp.GramWeight,
+			Description: fp.PortionDescription,
+		}
+	}
+	switch {
+	case d.Foundation != nil:
+		f.FoodCategory = d.Foundation.FoodCategory.Description
+	case d.Branded != nil:
+		f.FoodCategory = d.Branded.BrandedFoodCategory
+	case d.Survey != nil:
+		f.FoodCategory = d.Survey.WWEIAFoodCategory.Description
+	}
+	if d.Branded != nil && d.Branded.ServingSize > 0 {
+		f.Serving = &provider.Serving{
+			Size:        d.Branded.ServingSize,
+			Unit:        strings.ToLower(d.Branded.ServingSizeUnit),
+			Description: d.Branded.HouseholdServingFullText,
+			Nutrients:   labelNutrients(d.Branded.LabelNutrients),
+		}
+	}
+	return &f
+}
+
+// labelNutrients converts nutrients of the label to nutrients named, numbered
+// and measured like nutrients of the food are. Nutrients not on the label are
+// left out.
+func labelNutrients(ln LabelNutrients) []provider.Nutrient {
+	label := []struct {
+		n      *LabelNutrient
+		number string
+	}{
+		{ln.Calories, nutrients.Energy},
+		{ln.Fat, nutrients.Fat},
+		{ln.SaturatedFat, nutrients.SaturatedFat},
+		{ln.TransFat, nutrients.TransFat},
+		{ln.Cholesterol, nutrients.Cholesterol},
+		{ln.Sodium, nutrients.Sodium},
+		{ln.Carbohydrates, nutrients.CarbohydrateByDifference},
+		{ln.Fiber, nutrients.Fiber},
+		{ln.Sugars, nutrients.Sugars},
+		{ln.Protein, nutrients.Protein},
+		{ln.Calcium, nutrients.Calcium},
+		{ln.Iron, nutrients.Iron},
+		{ln.Potassium, nutrients.Potassium},
+	}
+
+	var list []provider.Nutrient
+	for _, l := range label {
+		if l.n == nil {
+			continue
+		}
+		list = append(list, nutrients.MustLookup(l.number).Amount(l.n.Value))
+	}
+	return list
+}
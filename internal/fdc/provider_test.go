@@ -0,0 +1,43 @@
+package fdc
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/sugar/internal/fdc/fdctest"
+	"github.com/igomonov88/sugar/internal/provider"
+	"github.com/igomonov88/sugar/internal/tests"
+)
+
+func TestProviderLookup(t *testing.T) {
+	t.Log("Given the need to look branded foods up by barcode.")
+	{
+		ctx := tests.Context()
+
+		srv := fdctest.StartServer(t)
+		defer srv.Close()
+
+		client, err := Connect(Config{ConsumerKey: "test", APIURL: srv.APIURL()})
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to connect to Food Data Center client : %s.", failed, err)
+		}
+		p := NewProvider(client)
+
+		for _, barcode := range []string{"040000002635", "0040000002635", "00040000002635"} {
+			f, err := p.Lookup(ctx, barcode)
+			if err != nil {
+				t.Fatalf("\t%s\tShould find the food by %s : %s.", failed, barcode, err)
+			}
+			if f.ID != "1104067" || f.Provider != provider.FDC || f.Barcode != "040000002635" {
+				t.Fatalf("\t%s\tShould get the food with the barcode : %+v.", failed, f)
+			}
+		}
+		t.Logf("\t%s\tShould find the food by UPC-A, EAN-13 and GTIN-14 forms of the barcode.", success)
+
+		if _, err := p.Lookup(ctx, "3017620422003"); errors.Cause(err) != provider.ErrNotFound {
+			t.Fatalf("\t%s\tShould get ErrNotFound for unknown barcode : %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould get ErrNotFound for unknown barcode.", success)
+	}
+}
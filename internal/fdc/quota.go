@@ -0,0 +1,181 @@
+package fdc
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// m contains the program counters of food data central api usage. They are
+// exposed under /debug/vars and picked up by the metrics sidecar.
+var m = struct {
+	limit     *expvar.Int
+	remaining *expvar.Int
+	requests  *expvar.Int
+	throttled *expvar.Int
+	exhausted *expvar.Int
+
+	circuitState    *expvar.Int
+	circuitOpened   *expvar.Int
+	circuitRejected *expvar.Int
+}{
+	limit:     expvar.NewInt("fdc_quota_limit"),
+	remaining: expvar.NewInt("fdc_quota_remaining"),
+	requests:  expvar.NewInt("fdc_requests"),
+	throttled: expvar.NewInt("fdc_throttled"),
+	exhausted: expvar.NewInt("fdc_quota_exhausted"),
+
+	circuitState:    expvar.NewInt("fdc_circuit_state"),
+	circuitOpened:   expvar.NewInt("fdc_circuit_opened"),
+	circuitRejected: expvar.NewInt("fdc_circuit_rejected"),
+}
+
+// ErrQuotaExhausted is returned when the consumer key has no requests left,
+// either by the account of food data central or by the client side limiter.
+// RetryAfter tells when it makes sense to try again.
+type ErrQuotaExhausted struct {
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *ErrQuotaExhausted) Error() string {
+	return fmt.Sprintf("food data central quota exhausted, retry after %v", e.RetryAfter)
+}
+
+// quota tracks the requests left for the consumer key, as reported by the
+// X-RateLimit headers of api.data.gov, and throttles the client with a token
+// bucket so traffic spikes do not burn the key.
+type quota struct {
+	mu sync.Mutex
+
+	// rate is the number of tokens added per second, burst is the capacity of
+	// the bucket.
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	// maxWait is the longest the caller is throttled before the quota is
+	// reported as exhausted.
+	maxWait time.Duration
+
+	// cooldown is used when the api reports no requests left but does not
+	// say when the window resets.
+	cooldown       time.Duration
+	exhaustedUntil time.Time
+
+	limit     int
+	remaining int
+}
+
+// newQuota constructs quota for the key allowed to make requestsPerHour.
+func newQuota(requestsPerHour, burst int, maxWait, cooldown time.Duration) *quota {
+	return &quota{
+		rate:      float64(requestsPerHour) / time.Hour.Seconds(),
+		burst:     float64(burst),
+		tokens:    float64(burst),
+		last:      time.Now(),
+		maxWait:   maxWait,
+		cooldown:  cooldown,
+		limit:     -1,
+		remaining: -1,
+	}
+}
+
+// wait blocks until the request is allowed by the limiter. It returns
+// ErrQuotaExhausted without waiting when the api reported no requests left, or
+// when the request would be throttled longer than maxWait.
+func (q *quota) wait(ctx context.Context) error {
+	q.mu.Lock()
+	now := time.Now()
+
+	if now.Before(q.exhaustedUntil) {
+		q.mu.Unlock()
+		m.exhausted.Add(1)
+		return &ErrQuotaExhausted{RetryAfter: q.exhaustedUntil.Sub(now)}
+	}
+
+	// Refill the bucket with tokens earned since the last call.
+	q.tokens += now.Sub(q.last).Seconds() * q.rate
+	if q.tokens > q.burst {
+		q.tokens = q.burst
+	}
+	q.last = now
+
+	var delay time.Duration
+	if q.tokens < 1 {
+		delay = time.Duration((1 - q.tokens) / q.rate * float64(time.Second))
+		if delay > q.maxWait {
+			q.mu.Unlock()
+			m.exhausted.Add(1)
+			return &ErrQuotaExhausted{RetryAfter: delay}
+		}
+	}
+
+	// Take the token now, so concurrent callers queue behind this one.
+	q.tokens--
+	q.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	m.throttled.Add(1)
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// observe records the quota reported in the response of the api.
+func (q *quota) observe(resp *http.Response) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	m.requests.Add(1)
+
+	if v, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit")); err == nil {
+		q.limit = v
+		m.limit.Set(int64(v))
+	}
+
+	if v, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		q.remaining = v
+		m.remaining.Set(int64(v))
+		if v <= 0 {
+			q.exhaustedUntil = time.Now().Add(q.cooldown)
+		}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		d, ok := retryAfter(resp)
+		if !ok {
+			d = q.cooldown
+		}
+		q.exhaustedUntil = time.Now().Add(d)
+	}
+}
+
+// block makes the quota exhausted for d, unless it is exhausted longer.
+func (q *quota) block(d time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if until := time.Now().Add(d); until.After(q.exhaustedUntil) {
+		q.exhaustedUntil = until
+	}
+}
+
+// retryIn returns how long the quota stays exhausted.
+func (q *quota) retryIn() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return time.Until(q.exhaustedUntil)
+}
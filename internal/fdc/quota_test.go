@@ -0,0 +1,87 @@
+package fdc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/sugar/internal/tests"
+)
+
+func TestQuota(t *testing.T) {
+	t.Log("Given the need to stay within the quota of the consumer key.")
+	{
+		var calls int32
+		var remaining atomic.Value
+		remaining.Store("1")
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.Header().Set("X-RateLimit-Limit", "1000")
+			w.Header().Set("X-RateLimit-Remaining", remaining.Load().(string))
+			w.Write([]byte(`{"description":"apple, raw"}`))
+		}))
+		defer srv.Close()
+
+		t.Log("\tWhen the api reports no requests left.")
+		{
+			client, err := Connect(Config{ConsumerKey: "test", APIURL: srv.URL + "/"})
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to connect to Food Data Center client : %s.", failed, err)
+			}
+
+			if _, err := Details(tests.Context(), client, 1); err != nil {
+				t.Fatalf("\t%s\tShould be able to get details while quota is left : %s.", failed, err)
+			}
+			if got := m.remaining.Value(); got != 1 {
+				t.Fatalf("\t%s\tShould publish remaining quota, got %d.", failed, got)
+			}
+			t.Logf("\t%s\tShould publish remaining quota.", success)
+
+			remaining.Store("0")
+			if _, err := Details(tests.Context(), client, 1); err != nil {
+				t.Fatalf("\t%s\tShould be able to get details with the last request : %s.", failed, err)
+			}
+
+			_, err = Details(tests.Context(), client, 1)
+			qe, ok := errors.Cause(err).(*ErrQuotaExhausted)
+			if !ok {
+				t.Fatalf("\t%s\tShould get ErrQuotaExhausted, got %v.", failed, err)
+			}
+			if qe.RetryAfter <= 0 {
+				t.Fatalf("\t%s\tShould tell when to retry, got %v.", failed, qe.RetryAfter)
+			}
+			if got := atomic.LoadInt32(&calls); got != 2 {
+				t.Fatalf("\t%s\tShould not call the api with exhausted quota, made %d calls.", failed, got)
+			}
+			t.Logf("\t%s\tShould get ErrQuotaExhausted without calling the api.", success)
+		}
+
+		t.Log("\tWhen the client makes more requests than the limiter allows.")
+		{
+			remaining.Store("100")
+			client, err := Connect(Config{
+				ConsumerKey:     "test",
+				APIURL:          srv.URL + "/",
+				RequestsPerHour: 1,
+				Burst:           1,
+				MaxThrottleWait: time.Millisecond,
+			})
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to connect to Food Data Center client : %s.", failed, err)
+			}
+
+			if _, err := Details(tests.Context(), client, 1); err != nil {
+				t.Fatalf("\t%s\tShould be able to make the first request : %s.", failed, err)
+			}
+			_, err = Details(tests.Context(), client, 1)
+			if _, ok := errors.Cause(err).(*ErrQuotaExhausted); !ok {
+				t.Fatalf("\t%s\tShould be throttled by the limiter, got %v.", failed, err)
+			}
+			t.Logf("\t%s\tShould be throttled by the limiter.", success)
+		}
+	}
+}
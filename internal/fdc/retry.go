@@ -0,0 +1,194 @@
+package fdc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// do makes the web call to food data central api with given method, url and
+// body, unless the circuit breaker is open. The outcome of the call, retries
+// included, is reported to the circuit breaker.
+func (c *Client) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.retry(ctx, method, url, body)
+
+	o := callSucceeded
+	switch {
+	case err != nil && (ctx.Err() != nil || replayMiss(errors.Cause(err))):
+		o = callIgnored
+	case err != nil:
+		if _, ok := errors.Cause(err).(*ErrQuotaExhausted); ok {
+			o = callIgnored
+		} else {
+			o = callFailed
+		}
+	case resp.StatusCode >= http.StatusInternalServerError:
+		o = callFailed
+	}
+	c.breaker.done(o)
+
+	return resp, err
+}
+
+// retry makes the web call with given method, url and body. Calls failed
+// with network error or 5xx status are retried with exponential backoff and
+// jitter, calls failed with 429 status are retried after the delay asked by
+// Retry-After header. Every attempt is made with the consumer key picked from
+// the pool and throttled by the limiter of the key, and ErrQuotaExhausted is
+// returned when no key allows the call soon enough. Calls failed with 429 or
+// 403 status are retried at once with another key when there is one not on
+// cooldown, such retries do not count as attempts.
+//
+// When all attempts are spent the last response is returned as is, so the
+// caller can decode the error body.
+func (c *Client) retry(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.FoodDataCenter.do")
+	defer span.End()
+
+	// rotated counts the attempts retried at once with another key, they are
+	// not counted against MaxRetries.
+	rotated := 0
+	for attempt := 0; ; attempt++ {
+		var rb io.Reader
+		if body != nil {
+			rb = bytes.NewReader(body)
+		}
+
+		k, err := c.keys.pick()
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequest(method, withKey(url, k.value), rb)
+		if err != nil {
+			return nil, errors.Wrapf(err, "request url: %s", redactURL(url))
+		}
+
+		// Bind the new context into the request.
+		req = req.WithContext(ctx)
+
+		// Set appropriate Content-Type to request
+		if body != nil {
+			req.Header.Add("Content-Type", "application/json")
+		}
+
+		// Wait for the limiter of the key before spending the quota.
+		if err := k.quota.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		// Make the web call. Do will handle the context level timeout.
+		resp, err := c.http.Do(req)
+		if resp != nil {
+			c.keys.observe(k, resp)
+		}
+
+		last := attempt-rotated >= c.cfg.MaxRetries
+		rotate := rotated < len(c.keys.keys)-1 && c.keys.available()
+		wait := backoff(c.cfg.RetryWaitMin, c.cfg.RetryWaitMax, attempt-rotated)
+		switch {
+		case err != nil:
+			if last || ctx.Err() != nil || replayMiss(err) {
+				return nil, errors.Wrap(err, "failed on making request")
+			}
+		case resp.StatusCode == http.StatusTooManyRequests:
+			drain(resp)
+			if rotate {
+				rotated++
+				wait = 0
+				break
+			}
+			d, ok := retryAfter(resp)
+			if !ok {
+				d = c.keys.retryIn()
+			}
+			if last || d > c.cfg.MaxThrottleWait {
+				return nil, &ErrQuotaExhausted{RetryAfter: d}
+			}
+			wait = d
+		case resp.StatusCode == http.StatusForbidden && rotate:
+			// The key is refused, e.g. revoked, so another key takes the call.
+			drain(resp)
+			rotated++
+			wait = 0
+		case resp.StatusCode >= http.StatusInternalServerError:
+			if last {
+				return resp, nil
+			}
+			drain(resp)
+		default:
+			return resp, nil
+		}
+
+		span.Annotatef(nil, "retrying in %v after attempt %d", wait, attempt+1)
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, errors.Wrap(ctx.Err(), "waiting for retry")
+		case <-t.C:
+		}
+	}
+}
+
+// drain reads the rest of the body and closes it so the connection can be
+// reused.
+func drain(resp *http.Response) {
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// backoff returns the delay before the next attempt. The delay doubles with
+// every attempt up to max, and a random half of it is used as jitter so
+// clients failed together do not retry together.
+func backoff(min, max time.Duration, attempt int) time.Duration {
+	d := min
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryAfter returns the delay asked by Retry-After header of the response. The
+// header holds either the number of seconds or the http date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		if s < 0 {
+			return 0, false
+		}
+		return time.Duration(s) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	d := time.Until(t)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
@@ -0,0 +1,129 @@
+package fdc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/igomonov88/sugar/internal/tests"
+)
+
+func TestClientRetry(t *testing.T) {
+	t.Log("Given the need to retry failed calls to food data central api.")
+	{
+		var calls int32
+		var responses atomic.Value
+		responses.Store([]int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&calls, 1)
+			statuses := responses.Load().([]int)
+			status := statuses[len(statuses)-1]
+			if int(n) <= len(statuses) {
+				status = statuses[n-1]
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(`{"description":"apple, raw"}`))
+		}))
+		defer srv.Close()
+
+		cfg := Config{
+			ConsumerKey:  "test",
+			APIURL:       srv.URL + "/",
+			MaxRetries:   3,
+			RetryWaitMin: time.Millisecond,
+			RetryWaitMax: 5 * time.Millisecond,
+		}
+		client, err := Connect(cfg)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to connect to Food Data Center client : %s.", failed, err)
+		}
+
+		t.Log("\tWhen the api fails with 5xx status before responding.")
+		{
+			resp, err := Details(tests.Context(), client, 1)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to get details after retries : %s.", failed, err)
+			}
+			if resp.Description != "apple, raw" {
+				t.Fatalf("\t%s\tShould get the response of the last attempt : %q.", failed, resp.Description)
+			}
+			if got := atomic.LoadInt32(&calls); got != 3 {
+				t.Fatalf("\t%s\tShould make 3 attempts, made %d.", failed, got)
+			}
+			t.Logf("\t%s\tShould be able to get details after retries.", success)
+		}
+
+		t.Log("\tWhen the api keeps failing with 5xx status.")
+		{
+			atomic.StoreInt32(&calls, 0)
+			responses.Store([]int{http.StatusInternalServerError})
+			if _, err := Details(tests.Context(), client, 1); err == nil {
+				t.Fatalf("\t%s\tShould get an error after retries are spent.", failed)
+			}
+			if got := atomic.LoadInt32(&calls); got != int32(cfg.MaxRetries+1) {
+				t.Fatalf("\t%s\tShould make %d attempts, made %d.", failed, cfg.MaxRetries+1, got)
+			}
+			t.Logf("\t%s\tShould get an error after retries are spent.", success)
+		}
+
+		t.Log("\tWhen the api responds with 4xx status.")
+		{
+			atomic.StoreInt32(&calls, 0)
+			responses.Store([]int{http.StatusNotFound})
+			if _, err := Details(tests.Context(), client, 1); err == nil {
+				t.Fatalf("\t%s\tShould get an error for not found food.", failed)
+			}
+			if got := atomic.LoadInt32(&calls); got != 1 {
+				t.Fatalf("\t%s\tShould not retry 4xx status, made %d attempts.", failed, got)
+			}
+			t.Logf("\t%s\tShould not retry 4xx status.", success)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Log("Given the need to respect Retry-After header of 429 response.")
+	{
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer srv.Close()
+
+		client, err := Connect(Config{
+			ConsumerKey:  "test",
+			APIURL:       srv.URL + "/",
+			MaxRetries:   1,
+			RetryWaitMin: time.Millisecond,
+			RetryWaitMax: time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to connect to Food Data Center client : %s.", failed, err)
+		}
+
+		t.Log("\tWhen the api asks to retry after one second.")
+		{
+			start := time.Now()
+			if _, err := Details(tests.Context(), client, 1); err == nil {
+				t.Fatalf("\t%s\tShould get an error while the api keeps limiting.", failed)
+			}
+			if d := time.Since(start); d < time.Second {
+				t.Fatalf("\t%s\tShould wait for Retry-After before retrying, waited %v.", failed, d)
+			}
+			t.Logf("\t%s\tShould wait for Retry-After before retrying.", success)
+		}
+
+		t.Log("\tWhen Retry-After holds the http date.")
+		{
+			resp := http.Response{Header: http.Header{}}
+			resp.Header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+			d, ok := retryAfter(&resp)
+			if !ok || d <= 0 || d > time.Minute {
+				t.Fatalf("\t%s\tShould parse Retry-After http date : %v.", failed, d)
+			}
+			t.Logf("\t%s\tShould parse Retry-After http date.", success)
+		}
+	}
+}
@@ -1,25 +1,50 @@
 package fdc
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
-	"time"
 
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 )
 
+// Data types which food data central can restrict a search to.
+const (
+	DataTypeBranded    = "Branded"
+	DataTypeFoundation = "Foundation"
+	DataTypeSurvey     = "Survey (FNDDS)"
+	DataTypeSRLegacy   = "SR Legacy"
+)
+
+// Sort directions supported by food data central search.
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// DataTypes is the set of data types accepted in IncludeDataTypeList.
+var DataTypes = map[string]bool{
+	DataTypeBranded:    true,
+	DataTypeFoundation: true,
+	DataTypeSurvey:     true,
+	DataTypeSRLegacy:   true,
+}
+
+// SortFields is the set of fields food data central can sort search results
+// by.
+var SortFields = map[string]bool{
+	"lowercaseDescription.keyword": true,
+	"dataType.keyword":             true,
+	"publishedDate":                true,
+	"fdcId":                        true,
+}
+
 // SearchOutput is returning food with given request parameters.
-func SearchOutput(ctx context.Context, client *Client, search string) (*SearchInternalResponse, error) {
+func SearchOutput(ctx context.Context, client *Client, search SearchInternalRequest) (*SearchInternalResponse, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.FoodDataCenter.Search")
 	defer span.End()
 
-	// Create a context with a timeout of 10 seconds.
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
 	// Make http call to external api.
 	resp, err := foodSearchHTTPRequest(ctx, client, search)
 	if err != nil {
@@ -32,18 +57,11 @@ func SearchOutput(ctx context.Context, client *Client, search string) (*SearchIn
 			return nil, errors.Wrap(err, "error while search request")
 		}
 	}
+	defer resp.Body.Close()
 
 	// Check response status code. If it's not 200 return error.
 	if resp.StatusCode != http.StatusOK {
-		errResp := FoodDataCentralErrorResponse{}
-		err := json.NewDecoder(resp.Body).Decode(&errResp)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to decode response body.")
-		}
-		return nil, errors.Wrapf(
-			ErrFromExternalAPI,
-			"status code: %v, error: %v, message: %v, path: %v",
-			resp.StatusCode, errResp.Error, errResp.Message, errResp.Path)
+		return nil, apiError(resp)
 	}
 
 	// Compose internal response value.
@@ -59,43 +77,26 @@ func SearchOutput(ctx context.Context, client *Client, search string) (*SearchIn
 // given req parameter to get response.
 //
 // If we got an error during the function execution we just pull it upstears.
-func foodSearchHTTPRequest(ctx context.Context, c *Client, search string) (*http.Response, error) {
+func foodSearchHTTPRequest(ctx context.Context, c *Client, request SearchInternalRequest) (*http.Response, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.FoodDataCenter.foodSearchHttpRequest")
 	defer span.End()
 
 	// Create request url with given client parameters.
-	url, err := buildRequestURL(c.cfg.APIURL, c.cfg.ConsumerKey, foodSearchMethod, search)
+	url, err := buildRequestURL(c.cfg.APIURL, foodSearchMethod, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	request := SearchInternalRequest{
-		GeneralSearchInput: search,
-	}
 	// Marshall incoming request to json.
 	b, err := json.Marshal(&request)
 	if err != nil {
 		return nil, errors.Wrapf(err, "request value %v", request)
 	}
 
-	// Creating new http request.
-	buf := bytes.NewBuffer(b)
-	req, err := http.NewRequest(http.MethodPost, url, buf)
-	if err != nil {
-		return nil, errors.Wrapf(err, "request url: %s", url)
-	}
-
-	// Bind the new context into the request.
-	req = req.WithContext(ctx)
-
-	// Set appropriate Content-Type to request
-	req.Header.Add("Content-Type", "application/json")
-
-	// Make the web call and return any error. Do will handle the
-	// context level timeout.
-	resp, err := http.DefaultClient.Do(req)
+	// Make the web call and return any error.
+	resp, err := c.do(ctx, http.MethodPost, url, b)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed on making request")
+		return nil, err
 	}
 
 	return resp, nil
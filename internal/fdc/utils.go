@@ -1,25 +1,62 @@
 package fdc
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
 
-// buildRequestURL knows how to build url for food data center api based on
-// given parameters.
-func buildRequestURL(apiURL string, consumerKey string, searchMethod string,
-	requestParam interface{}) (string, error) {
+	"github.com/pkg/errors"
+)
 
-	if apiURL == "" || consumerKey == "" {
+// buildRequestURL knows how to build url for food data center api based on
+// given parameters. The url has no consumer key, the key of every attempt is
+// set by withKey.
+func buildRequestURL(apiURL string, searchMethod string, requestParam interface{}) (string, error) {
+	if apiURL == "" {
 		return "", ErrInvalidConfig
 	}
 	switch searchMethod {
 	case foodSearchMethod:
-		return fmt.Sprintf("%ssearch?api_key=%s", apiURL, consumerKey), nil
+		return fmt.Sprintf("%ssearch", apiURL), nil
 	case foodDetailMethod:
 		fdcID, ok := requestParam.(int)
 		if !ok {
 			return "", ErrFailedToComposeURL
 		}
-		return fmt.Sprintf("%s%v?api_key=%s", apiURL, fdcID, consumerKey), nil
+		return fmt.Sprintf("%s%v", apiURL, fdcID), nil
+	case foodsMethod:
+		return fmt.Sprintf("%sfoods", apiURL), nil
 	default:
 		return "", ErrMethodNotSupported
 	}
 }
+
+// redactURL hides consumer key in the url, so it can be used in logs and
+// errors.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	if q.Get("api_key") == "" {
+		return rawURL
+	}
+	q.Set("api_key", "REDACTED")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// apiError composes the error from not 200OK response of the external api. The
+// body is decoded when the api respond with its json error, gateways in front
+// of it may respond with anything else.
+func apiError(resp *http.Response) error {
+	errResp := FoodDataCentralErrorResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+		return errors.Wrapf(ErrFromExternalAPI, "status code: %v", resp.StatusCode)
+	}
+	return errors.Wrapf(ErrFromExternalAPI,
+		"status code: %v, error: %v, message: %v, path: %v",
+		resp.StatusCode, errResp.Error, errResp.Message, errResp.Path)
+}
@@ -0,0 +1,127 @@
+// Package fpu computes fat-protein units of the Warsaw method. Fat and protein
+// raise glucose hours after the meal, so insulin for them is given as the
+// extended bolus, which lasts longer the more units the meal has.
+package fpu
+
+import (
+	"math"
+	"strings"
+
+	"github.com/igomonov88/sugar/internal/nutrients"
+	"github.com/igomonov88/sugar/internal/provider"
+)
+
+// Method is the name of the method units are computed with.
+const Method = "warsaw"
+
+// Energy of fat and protein in kcal per gram, and kcal of fat and protein one
+// unit is.
+const (
+	kcalPerFat     = 9
+	kcalPerProtein = 4
+	kcalPerUnit    = 100
+)
+
+// CarbsPerUnit is grams of carbohydrates one unit is dosed as.
+const CarbsPerUnit = 10
+
+// Basis is the weight in grams fat and protein of the food are given for.
+const Basis = 100
+
+// extensions is the Warsaw method table of extended bolus duration in hours,
+// by the least number of units it is used for.
+var extensions = []struct {
+	units float64
+	hours int
+}{
+	{4, 8},
+	{3, 5},
+	{2, 4},
+	{1, 3},
+}
+
+// FPU is fat-protein units of the food.
+type FPU struct {
+	// Fat and Protein are in grams, Kcal is energy of both of them.
+	Fat     float64 `json:"fat"`
+	Protein float64 `json:"protein"`
+	Kcal    float64 `json:"kcal"`
+
+	// Units is fat-protein units, 100 kcal of fat and protein each, dosed
+	// as CarbEquivalent grams of carbohydrates.
+	Units          float64 `json:"units"`
+	CarbEquivalent float64 `json:"carb_equivalent"`
+
+	// ExtendedHours is the recommended duration of the extended bolus, zero
+	// when the food has less than one unit and no extended bolus is needed.
+	ExtendedHours int    `json:"extended_hours"`
+	Method        string `json:"method"`
+}
+
+// New returns fat-protein units of given grams of fat and protein.
+func New(fat, protein float64) FPU {
+	f := FPU{
+		Fat:     round(fat),
+		Protein: round(protein),
+		Method:  Method,
+	}
+	kcal := fat*kcalPerFat + protein*kcalPerProtein
+	f.Kcal = round(kcal)
+	f.Units = round(kcal / kcalPerUnit)
+	f.CarbEquivalent = round(kcal / kcalPerUnit * CarbsPerUnit)
+	f.ExtendedHours = ExtendedHours(f.Units)
+	return f
+}
+
+// Retrieve returns fat-protein units of the food per Basis grams. False is
+// returned when the food states neither fat nor protein in grams.
+func Retrieve(list []provider.Nutrient) (FPU, bool) {
+	fat, okFat := grams(list, nutrients.Fat)
+	protein, okProtein := grams(list, nutrients.Protein)
+	if !okFat && !okProtein {
+		return FPU{}, false
+	}
+	return New(fat, protein), true
+}
+
+// ForWeight returns fat-protein units in given grams of the food, scaled from
+// units per Basis grams.
+func (f FPU) ForWeight(grams float64) FPU {
+	return New(f.Fat*grams/Basis, f.Protein*grams/Basis)
+}
+
+// Sum returns fat-protein units of the meal made of the foods, the extended
+// bolus is planned for the meal as a whole.
+func Sum(foods ...FPU) FPU {
+	var fat, protein float64
+	for _, f := range foods {
+		fat += f.Fat
+		protein += f.Protein
+	}
+	return New(fat, protein)
+}
+
+// ExtendedHours returns duration of the extended bolus in hours for given
+// units by the Warsaw method table.
+func ExtendedHours(units float64) int {
+	for _, e := range extensions {
+		if units >= e.units {
+			return e.hours
+		}
+	}
+	return 0
+}
+
+// grams returns the amount of the nutrient with given number in grams.
+func grams(list []provider.Nutrient, number string) (float64, bool) {
+	n, ok := nutrients.Find(list, nutrients.MustLookup(number))
+	if !ok || !strings.EqualFold(n.UnitName, "g") {
+		return 0, false
+	}
+	return n.Amount, true
+}
+
+// round rounds the amount to 2 decimal places.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,57 @@
+package fpu
+
+import (
+	"testing"
+
+	"github.com/igomonov88/sugar/internal/provider"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestFPU(t *testing.T) {
+	t.Log("Given the need to plan extended bolus for fat and protein.")
+	{
+		t.Log("\tWhen the food is pizza.")
+		{
+			list := []provider.Nutrient{
+				{Number: "203", Name: "Protein", Amount: 11, UnitName: "g"},
+				{Number: "204", Name: "Total lipid (fat)", Amount: 10, UnitName: "g"},
+				{Number: "208", Name: "Energy", Amount: 266, UnitName: "kcal"},
+			}
+			per100, ok := Retrieve(list)
+			if !ok || per100.Kcal != 134 || per100.Units != 1.34 || per100.ExtendedHours != 3 {
+				t.Fatalf("\t%s\tShould compute units per 100 grams: %+v", failed, per100)
+			}
+			t.Logf("\t%s\tShould compute units per 100 grams.", success)
+
+			slice := per100.ForWeight(300)
+			if slice.Units != 4.02 || slice.CarbEquivalent != 40.2 || slice.ExtendedHours != 8 {
+				t.Fatalf("\t%s\tShould compute units of the portion: %+v", failed, slice)
+			}
+			t.Logf("\t%s\tShould compute units of the portion.", success)
+		}
+
+		t.Log("\tWhen the foods make the meal.")
+		{
+			meal := Sum(New(10, 5), New(5, 15))
+			if meal.Fat != 15 || meal.Protein != 20 || meal.Units != 2.15 || meal.ExtendedHours != 4 {
+				t.Fatalf("\t%s\tShould compute units of the meal: %+v", failed, meal)
+			}
+			t.Logf("\t%s\tShould compute units of the meal.", success)
+		}
+
+		t.Log("\tWhen the food has no fat and protein.")
+		{
+			if f, ok := Retrieve([]provider.Nutrient{{Number: "205", Amount: 13.8, UnitName: "g"}}); ok {
+				t.Fatalf("\t%s\tShould not compute units: %+v", failed, f)
+			}
+			if h := ExtendedHours(0.8); h != 0 {
+				t.Fatalf("\t%s\tShould not extend bolus for less than one unit: %d", failed, h)
+			}
+			t.Logf("\t%s\tShould not compute units.", success)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Package glycemic reads glycemic index tables, which are published as CSV
+// files, so glycemic index of the foods can be imported to storage.
+package glycemic
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/sugar/internal/storage"
+)
+
+// Columns of the table. The table has gi column and fdc_id or food_category
+// column at least, food_category is the category of Food Data Central e.g.
+// Fruits and Fruit Juices.
+const (
+	colFDCID        = "fdc_id"
+	colFoodCategory = "food_category"
+	colDescription  = "description"
+	colGI           = "gi"
+	colSource       = "source"
+)
+
+// maxGI is the highest glycemic index accepted, glucose is 100 and few foods
+// are measured above it.
+const maxGI = 150
+
+// Read reads glycemic indexes from the CSV table. Every row is linked to the
+// food by fdc_id, or to the food category when it has no fdc_id. Rows without
+// source column value are given source.
+func Read(r io.Reader, source string) ([]storage.GlycemicIndex, error) {
+
+	// Files saved by spreadsheet editors may start with the byte order mark,
+	// it would become the part of the first column name.
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(3); err == nil && string(b) == "\ufeff" {
+		br.Discard(3)
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading header")
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := cols[colGI]; !ok {
+		return nil, errors.Errorf("column %q is missing", colGI)
+	}
+	_, hasID := cols[colFDCID]
+	_, hasCategory := cols[colFoodCategory]
+	if !hasID && !hasCategory {
+		return nil, errors.Errorf("column %q or %q is missing", colFDCID, colFoodCategory)
+	}
+
+	var indexes []storage.GlycemicIndex
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return indexes, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading line %d", line)
+		}
+
+		value := func(col string) string {
+			i, ok := cols[col]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		gi := storage.GlycemicIndex{
+			FoodCategory: value(colFoodCategory),
+			Description:  value(colDescription),
+			Source:       value(colSource),
+		}
+		if gi.Source == "" {
+			gi.Source = source
+		}
+		if gi.Source == "" {
+			return nil, errors.Errorf("line %d: source is missing", line)
+		}
+
+		if v := value(colFDCID); v != "" {
+			if gi.FDCID, err = strconv.Atoi(v); err != nil || gi.FDCID <= 0 {
+				return nil, errors.Errorf("line %d: fdc_id %q is not valid", line, v)
+			}
+		}
+		if gi.FDCID == 0 && gi.FoodCategory == "" {
+			return nil, errors.Errorf("line %d: fdc_id or food_category is missing", line)
+		}
+
+		v := value(colGI)
+		if gi.GI, err = strconv.ParseFloat(v, 64); err != nil || gi.GI <= 0 || gi.GI > maxGI {
+			return nil, errors.Errorf("line %d: gi %q is not valid", line, v)
+		}
+
+		indexes = append(indexes, gi)
+	}
+}
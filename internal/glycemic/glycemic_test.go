@@ -0,0 +1,56 @@
+package glycemic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/igomonov88/sugar/internal/storage"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestRead(t *testing.T) {
+	t.Log("Given the need to read glycemic index tables.")
+	{
+		t.Log("\tWhen rows are linked to foods and categories.")
+		{
+			const table = "\ufeffFDC_ID,food_category,description,GI,source\n" +
+				"171688,,\"Apples, raw\",36,\n" +
+				",Breakfast Cereals,,74,Sydney GI database\n"
+
+			got, err := Read(strings.NewReader(table), "International tables 2021")
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to read the table : %s.", failed, err)
+			}
+			want := []storage.GlycemicIndex{
+				{FDCID: 171688, Description: "Apples, raw", GI: 36, Source: "International tables 2021"},
+				{FoodCategory: "Breakfast Cereals", GI: 74, Source: "Sydney GI database"},
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Fatalf("\t%s\tShould get glycemic indexes of the rows : %s.", failed, diff)
+			}
+			t.Logf("\t%s\tShould get glycemic indexes of the rows.", success)
+		}
+
+		t.Log("\tWhen rows are not valid.")
+		{
+			tables := []string{
+				"description,gi\napple,36\n",
+				"fdc_id,gi\n171688,0\n",
+				"fdc_id,gi\n,36\n",
+				"fdc_id,gi\napple,36\n",
+			}
+			for _, table := range tables {
+				if _, err := Read(strings.NewReader(table), "tables"); err == nil {
+					t.Fatalf("\t%s\tShould get an error for table %q.", failed, table)
+				}
+			}
+			t.Logf("\t%s\tShould get an error for not valid rows.", success)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package insulin models activity of rapid-acting insulin, so insulin still
+// active from the doses given before, insulin on board, is known at any time.
+package insulin
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Models of insulin activity.
+const (
+	// ModelExponential is the exponential curve used by OpenAPS and Loop.
+	ModelExponential = "exponential"
+
+	// ModelBilinear is the activity rising linearly to the peak and falling
+	// linearly to the end of the duration.
+	ModelBilinear = "bilinear"
+)
+
+// Insulin is the insulin with its activity settings.
+type Insulin struct {
+	Name string
+
+	// Peak is the time from the dose to the highest activity, Duration is
+	// the time the dose is active for.
+	Peak     time.Duration
+	Duration time.Duration
+}
+
+// presets are rapid-acting insulins with peaks of their OpenAPS curves.
+var presets = map[string]Insulin{
+	"novorapid": {Name: "novorapid", Peak: 75 * time.Minute, Duration: 5 * time.Hour},
+	"fiasp":     {Name: "fiasp", Peak: 55 * time.Minute, Duration: 5 * time.Hour},
+	"lyumjev":   {Name: "lyumjev", Peak: 55 * time.Minute, Duration: 5 * time.Hour},
+}
+
+// DefaultInsulin is the name of the insulin used when none is given.
+const DefaultInsulin = "novorapid"
+
+// Preset returns the insulin with given name e.g. fiasp.
+func Preset(name string) (Insulin, error) {
+	in, ok := presets[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Insulin{}, errors.Errorf("unknown insulin %q", name)
+	}
+	return in, nil
+}
+
+// Curve is the activity of one unit of insulin over the time since the dose.
+type Curve interface {
+
+	// Activity returns the share of the dose acting per minute.
+	Activity(t time.Duration) float64
+
+	// Remaining returns the share of the dose still to act, from 1 at the
+	// time of the dose to 0 at the end of its duration.
+	Remaining(t time.Duration) float64
+}
+
+// NewCurve returns the curve of given model for the insulin.
+func NewCurve(model string, in Insulin) (Curve, error) {
+	if in.Peak <= 0 || in.Duration <= 0 {
+		return nil, errors.New("peak and duration must be positive")
+	}
+
+	switch strings.ToLower(model) {
+	case ModelExponential, "":
+		if in.Peak*2 >= in.Duration {
+			return nil, errors.New("peak must be less than half of duration")
+		}
+		return newExponential(in.Peak, in.Duration), nil
+	case ModelBilinear:
+		if in.Peak >= in.Duration {
+			return nil, errors.New("peak must be less than duration")
+		}
+		return bilinear{peak: in.Peak.Minutes(), end: in.Duration.Minutes()}, nil
+	}
+	return nil, errors.Errorf("unknown model %q", model)
+}
+
+// exponential is the exponential activity curve. The formula is the one of
+// OpenAPS, https://github.com/LoopKit/Loop/issues/388.
+type exponential struct {
+	td, tau, a, s float64
+}
+
+func newExponential(peak, duration time.Duration) exponential {
+	tp, td := peak.Minutes(), duration.Minutes()
+	tau := tp * (1 - tp/td) / (1 - 2*tp/td)
+	a := 2 * tau / td
+	s := 1 / (1 - a + (1+a)*math.Exp(-td/tau))
+	return exponential{td: td, tau: tau, a: a, s: s}
+}
+
+func (e exponential) Activity(t time.Duration) float64 {
+	m := t.Minutes()
+	if m <= 0 || m >= e.td {
+		return 0
+	}
+	return e.s / (e.tau * e.tau) * m * (1 - m/e.td) * math.Exp(-m/e.tau)
+}
+
+func (e exponential) Remaining(t time.Duration) float64 {
+	m := t.Minutes()
+	switch {
+	case m <= 0:
+		return 1
+	case m >= e.td:
+		return 0
+	}
+	return 1 - e.s*(1-e.a)*((m*m/(e.tau*e.td*(1-e.a))-m/e.tau-1)*math.Exp(-m/e.tau)+1)
+}
+
+// bilinear is the triangle activity curve, in minutes.
+type bilinear struct {
+	peak, end float64
+}
+
+func (b bilinear) Activity(t time.Duration) float64 {
+	m := t.Minutes()
+	switch {
+	case m <= 0 || m >= b.end:
+		return 0
+	case m < b.peak:
+		return 2 / b.end * m / b.peak
+	}
+	return 2 / b.end * (b.end - m) / (b.end - b.peak)
+}
+
+func (b bilinear) Remaining(t time.Duration) float64 {
+	m := t.Minutes()
+	switch {
+	case m <= 0:
+		return 1
+	case m >= b.end:
+		return 0
+	case m < b.peak:
+		return 1 - m*m/(b.end*b.peak)
+	}
+	rest := b.end - m
+	return rest * rest / (b.end * (b.end - b.peak))
+}
+
+// Dose is units of insulin given at the time.
+type Dose struct {
+	Units float64
+	Time  time.Time
+}
+
+// State is insulin on board and its activity at the time.
+type State struct {
+	// OnBoard is units of insulin still to act, Activity is units acting
+	// per hour.
+	OnBoard  float64
+	Activity float64
+
+	// Remaining is units still to act of every dose, in order of the doses.
+	Remaining []float64
+}
+
+// At returns insulin on board and activity of the doses at given time. Doses
+// given after the time are not counted.
+func At(c Curve, doses []Dose, at time.Time) State {
+	st := State{Remaining: make([]float64, len(doses))}
+	for i, d := range doses {
+		t := at.Sub(d.Time)
+		if t < 0 {
+			continue
+		}
+		st.Remaining[i] = round(d.Units * c.Remaining(t))
+		st.OnBoard += d.Units * c.Remaining(t)
+		st.Activity += d.Units * c.Activity(t) * 60
+	}
+	st.OnBoard = round(st.OnBoard)
+	st.Activity = round(st.Activity)
+	return st
+}
+
+// round rounds the units to 2 decimal places.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
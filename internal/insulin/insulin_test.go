@@ -0,0 +1,98 @@
+package insulin
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestCurves(t *testing.T) {
+	t.Log("Given the need to model activity of rapid-acting insulin.")
+	{
+		for _, model := range []string{ModelExponential, ModelBilinear} {
+			for _, name := range []string{"novorapid", "Fiasp", "lyumjev"} {
+				t.Logf("\tWhen %s is modelled by %s curve.", name, model)
+				{
+					in, err := Preset(name)
+					if err != nil {
+						t.Fatalf("\t%s\tShould know the insulin : %s.", failed, err)
+					}
+					c, err := NewCurve(model, in)
+					if err != nil {
+						t.Fatalf("\t%s\tShould be able to create the curve : %s.", failed, err)
+					}
+
+					if c.Remaining(0) != 1 || c.Remaining(in.Duration) != 0 {
+						t.Fatalf("\t%s\tShould act from the dose to the end of duration.", failed)
+					}
+
+					var acted float64
+					for m := 0; m < int(in.Duration.Minutes()); m++ {
+						acted += c.Activity(time.Duration(m) * time.Minute)
+						if rest := 1 - c.Remaining(time.Duration(m+1)*time.Minute); math.Abs(acted-rest) > 0.01 {
+							t.Fatalf("\t%s\tShould get remaining insulin as the rest of activity at %d minutes : %g acted, %g remaining.", failed, m, acted, rest)
+						}
+					}
+
+					peak := c.Activity(in.Peak)
+					if c.Activity(in.Peak-10*time.Minute) > peak || c.Activity(in.Peak+10*time.Minute) > peak {
+						t.Fatalf("\t%s\tShould get the highest activity at the peak.", failed)
+					}
+					t.Logf("\t%s\tShould model activity of the insulin.", success)
+				}
+			}
+		}
+
+		t.Log("\tWhen the settings are not valid.")
+		{
+			tt := []struct {
+				model string
+				in    Insulin
+			}{
+				{"exponential", Insulin{Peak: 3 * time.Hour, Duration: 5 * time.Hour}},
+				{"bilinear", Insulin{Peak: 5 * time.Hour, Duration: 5 * time.Hour}},
+				{"linear", Insulin{Peak: time.Hour, Duration: 5 * time.Hour}},
+			}
+			for _, tc := range tt {
+				if _, err := NewCurve(tc.model, tc.in); err == nil {
+					t.Fatalf("\t%s\tShould not create %s curve for %+v.", failed, tc.model, tc.in)
+				}
+			}
+			if _, err := Preset("insulatard"); err == nil {
+				t.Fatalf("\t%s\tShould not know the insulin.", failed)
+			}
+			t.Logf("\t%s\tShould not create the curve.", success)
+		}
+	}
+}
+
+func TestAt(t *testing.T) {
+	t.Log("Given the need to know insulin on board.")
+	{
+		in, _ := Preset(DefaultInsulin)
+		c, _ := NewCurve(ModelExponential, in)
+
+		now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+		doses := []Dose{
+			{Units: 4, Time: now.Add(-6 * time.Hour)},
+			{Units: 5, Time: now.Add(-2 * time.Hour)},
+			{Units: 2, Time: now},
+			{Units: 3, Time: now.Add(time.Hour)},
+		}
+
+		st := At(c, doses, now)
+		want := round(5*c.Remaining(2*time.Hour)) + 2
+		if st.OnBoard != want || st.Remaining[0] != 0 || st.Remaining[2] != 2 || st.Remaining[3] != 0 {
+			t.Fatalf("\t%s\tShould count doses still acting only : %+v.", failed, st)
+		}
+		if st.Activity <= 0 {
+			t.Fatalf("\t%s\tShould get activity of the doses : %+v.", failed, st)
+		}
+		t.Logf("\t%s\tShould count doses still acting only.", success)
+	}
+}
@@ -0,0 +1,185 @@
+// Package nutrients is the registry of nutrients the service knows. Nutrients
+// are keyed by their number in USDA FoodData Central, which every provider
+// uses to tell its nutrients apart, and carry canonical names, units and
+// INFOODS tags.
+package nutrients
+
+import (
+	"strings"
+
+	"github.com/igomonov88/sugar/internal/provider"
+)
+
+// Numbers of the nutrients in FoodData Central.
+const (
+	Protein                  = "203"
+	Fat                      = "204"
+	CarbohydrateByDifference = "205"
+	CarbohydrateBySummation  = "205.2"
+	Energy                   = "208"
+	Starch                   = "209"
+	Sucrose                  = "210"
+	Glucose                  = "211"
+	Fructose                 = "212"
+	Lactose                  = "213"
+	Maltose                  = "214"
+	Alcohol                  = "221"
+	Water                    = "255"
+	EnergyKJ                 = "268"
+	Sugars                   = "269"
+	Fiber                    = "291"
+	SugarAlcohols            = "299"
+	Calcium                  = "301"
+	Iron                     = "303"
+	Potassium                = "306"
+	Sodium                   = "307"
+	AddedSugars              = "539"
+	Cholesterol              = "601"
+	TransFat                 = "605"
+	SaturatedFat             = "606"
+	MonounsaturatedFat       = "645"
+	PolyunsaturatedFat       = "646"
+)
+
+// AvailableCarbohydrate is the tag of available carbohydrates, which labels in
+// Europe state. FoodData Central has no number for them, so they are known by
+// the tag only.
+const AvailableCarbohydrate = "CHOAVL"
+
+// Nutrient is the nutrient of the registry.
+type Nutrient struct {
+	// Number is the number of the nutrient in FoodData Central, empty for
+	// nutrients it does not have.
+	Number string
+
+	// Key is the short name of the nutrient used in the api of the
+	// service, e.g. fiber.
+	Key string
+
+	// Name and Unit are the name and unit FoodData Central uses.
+	Name string
+	Unit string
+
+	// Tag is the INFOODS tagname of the nutrient, e.g. CHOCDF.
+	Tag string
+}
+
+// registry holds the nutrients in the order FoodData Central lists them.
+var registry = []Nutrient{
+	{Energy, "energy", "Energy", "kcal", "ENERC_KCAL"},
+	{EnergyKJ, "energy_kj", "Energy", "kJ", "ENERC_KJ"},
+	{Water, "water", "Water", "g", "WATER"},
+	{Protein, "protein", "Protein", "g", "PROCNT"},
+	{Fat, "fat", "Total lipid (fat)", "g", "FAT"},
+	{SaturatedFat, "saturated_fat", "Fatty acids, total saturated", "g", "FASAT"},
+	{MonounsaturatedFat, "monounsaturated_fat", "Fatty acids, total monounsaturated", "g", "FAMS"},
+	{PolyunsaturatedFat, "polyunsaturated_fat", "Fatty acids, total polyunsaturated", "g", "FAPU"},
+	{TransFat, "trans_fat", "Fatty acids, total trans", "g", "FATRN"},
+	{Cholesterol, "cholesterol", "Cholesterol", "mg", "CHOLE"},
+	{CarbohydrateByDifference, "carbs", "Carbohydrate, by difference", "g", "CHOCDF"},
+	{CarbohydrateBySummation, "carbs_by_summation", "Carbohydrate, by summation", "g", "CHOCSM"},
+	{"", "available_carbs", "Carbohydrates", "g", AvailableCarbohydrate},
+	{Fiber, "fiber", "Fiber, total dietary", "g", "FIBTG"},
+	{Sugars, "sugars", "Sugars, total including NLEA", "g", "SUGAR"},
+	{AddedSugars, "added_sugars", "Sugars, added", "g", "SUGAD"},
+	{Sucrose, "sucrose", "Sucrose", "g", "SUCS"},
+	{Glucose, "glucose", "Glucose", "g", "GLUS"},
+	{Fructose, "fructose", "Fructose", "g", "FRUS"},
+	{Lactose, "lactose", "Lactose", "g", "LACS"},
+	{Maltose, "maltose", "Maltose", "g", "MALS"},
+	{Starch, "starch", "Starch", "g", "STARCH"},
+	{SugarAlcohols, "sugar_alcohols", "Total sugar alcohols", "g", "POLYL"},
+	{Alcohol, "alcohol", "Alcohol, ethyl", "g", "ALC"},
+	{Calcium, "calcium", "Calcium, Ca", "mg", "CA"},
+	{Iron, "iron", "Iron, Fe", "mg", "FE"},
+	{Potassium, "potassium", "Potassium, K", "mg", "K"},
+	{Sodium, "sodium", "Sodium, Na", "mg", "NA"},
+}
+
+var (
+	byNumber = make(map[string]Nutrient)
+	byKey    = make(map[string]Nutrient)
+	byTag    = make(map[string]Nutrient)
+)
+
+func init() {
+	for _, n := range registry {
+		if n.Number != "" {
+			byNumber[n.Number] = n
+		}
+		byKey[n.Key] = n
+		byTag[n.Tag] = n
+	}
+}
+
+// All returns every nutrient of the registry.
+func All() []Nutrient {
+	all := make([]Nutrient, len(registry))
+	copy(all, registry)
+	return all
+}
+
+// Lookup returns the nutrient with given number.
+func Lookup(number string) (Nutrient, bool) {
+	n, ok := byNumber[number]
+	return n, ok
+}
+
+// ByKey returns the nutrient with given key.
+func ByKey(key string) (Nutrient, bool) {
+	n, ok := byKey[key]
+	return n, ok
+}
+
+// ByTag returns the nutrient with given INFOODS tag.
+func ByTag(tag string) (Nutrient, bool) {
+	n, ok := byTag[tag]
+	return n, ok
+}
+
+// MustLookup returns the nutrient with given number, it panics when the
+// registry does not know it. It is meant for numbers defined by this package.
+func MustLookup(number string) Nutrient {
+	n, ok := Lookup(number)
+	if !ok {
+		panic("nutrients: unknown number " + number)
+	}
+	return n
+}
+
+// Identify returns the nutrient of the registry the nutrient of the provider
+// is. Nutrients are identified by number, and by name and unit when they
+// have no number.
+func Identify(pn provider.Nutrient) (Nutrient, bool) {
+	if pn.Number != "" {
+		return Lookup(pn.Number)
+	}
+	for _, n := range registry {
+		if strings.EqualFold(n.Name, pn.Name) && strings.EqualFold(n.Unit, pn.UnitName) {
+			return n, true
+		}
+	}
+	return Nutrient{}, false
+}
+
+// Find returns the record of given nutrient from the list of nutrients of the
+// food. Amount and unit of the result come from the same record of the list.
+func Find(list []provider.Nutrient, n Nutrient) (provider.Nutrient, bool) {
+	for _, pn := range list {
+		if got, ok := Identify(pn); ok && got.Tag == n.Tag && got.Number == n.Number {
+			return pn, true
+		}
+	}
+	return provider.Nutrient{}, false
+}
+
+// Amount returns the nutrient with given amount, named and measured the way
+// the registry does.
+func (n Nutrient) Amount(amount float64) provider.Nutrient {
+	return provider.Nutrient{
+		Number:   n.Number,
+		Name:     n.Name,
+		Amount:   amount,
+		UnitName: n.Unit,
+	}
+}
@@ -0,0 +1,71 @@
+package nutrients
+
+import (
+	"testing"
+
+	"github.com/igomonov88/sugar/internal/provider"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Log("Given the need to know nutrients by FDC number, key and tag.")
+	{
+		keys := make(map[string]bool)
+		for _, n := range All() {
+			if keys[n.Key] || n.Name == "" || n.Unit == "" || n.Tag == "" {
+				t.Fatalf("\t%s\tShould have unique keys, names, units and tags: %+v", failed, n)
+			}
+			keys[n.Key] = true
+		}
+		t.Logf("\t%s\tShould have unique keys, names, units and tags.", success)
+
+		n, ok := Lookup(CarbohydrateByDifference)
+		if !ok || n.Key != "carbs" || n.Unit != "g" || n.Tag != "CHOCDF" {
+			t.Fatalf("\t%s\tShould know carbohydrates by difference: %+v", failed, n)
+		}
+		t.Logf("\t%s\tShould know carbohydrates by difference.", success)
+	}
+}
+
+func TestFind(t *testing.T) {
+	t.Log("Given the need to find nutrients of the food.")
+	{
+		list := []provider.Nutrient{
+			{Number: "208", Name: "Energy", Amount: 52, UnitName: "kcal"},
+			{Number: "205", Name: "Carbohydrate, by difference", Amount: 13.8, UnitName: "g"},
+			{Name: "Carbohydrates", Amount: 11.4, UnitName: "g"},
+			{Number: "291", Name: "Fiber, total dietary", Amount: 2.4, UnitName: "g"},
+		}
+
+		t.Log("\tWhen the nutrient has a number.")
+		{
+			n, ok := Find(list, MustLookup(CarbohydrateByDifference))
+			if !ok || n.Amount != 13.8 || n.UnitName != "g" {
+				t.Fatalf("\t%s\tShould find amount and unit of the same record: %+v", failed, n)
+			}
+			t.Logf("\t%s\tShould find amount and unit of the same record.", success)
+		}
+
+		t.Log("\tWhen the nutrient has no number.")
+		{
+			available, _ := ByTag(AvailableCarbohydrate)
+			n, ok := Find(list, available)
+			if !ok || n.Amount != 11.4 {
+				t.Fatalf("\t%s\tShould find the nutrient by name and unit: %+v", failed, n)
+			}
+			t.Logf("\t%s\tShould find the nutrient by name and unit.", success)
+		}
+
+		t.Log("\tWhen the food has no such nutrient.")
+		{
+			if n, ok := Find(list, MustLookup(Sugars)); ok {
+				t.Fatalf("\t%s\tShould not find the nutrient: %+v", failed, n)
+			}
+			t.Logf("\t%s\tShould not find the nutrient.", success)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// Package off provides foods of Open Food Facts, read from the JSONL dump of
+// its products database, e.g. openfoodfacts-products.jsonl.gz. Most products
+// sold in Europe are there and not in Food Data Central.
+package off
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/sugar/internal/nutrients"
+	"github.com/igomonov88/sugar/internal/provider"
+)
+
+// pageSize is the number of foods on one page of search result, the same as
+// Food Data Central uses.
+const pageSize = 50
+
+// Provider is the provider.FoodProvider backed by Open Food Facts dump. The
+// dump is read into memory once, keeping only the fields the service uses.
+type Provider struct {
+	products []product
+	byCode   map[string]int
+}
+
+// product is the product of the dump.
+type product struct {
+	code        string
+	name        string
+	brand       string
+	servingSize string
+	servingGram float64
+	nutrients   []provider.Nutrient
+
+	// text is the lowercase name and brands the search input is matched
+	// against, brands and ingredients are lowercase texts of the dump.
+	text        string
+	brands      string
+	ingredients string
+}
+
+// Open reads the dump at given path. Dumps compressed with gzip are read when
+// the path ends with .gz. Lines which are not valid products are skipped.
+func Open(path string) (*Provider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening open food facts dump")
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading open food facts dump")
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	return Read(r)
+}
+
+// Read reads the dump from r.
+func Read(r io.Reader) (*Provider, error) {
+	p := Provider{
+		byCode: make(map[string]int),
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for s.Scan() {
+		var rec record
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil || rec.Code == "" || rec.ProductName == "" {
+			continue
+		}
+		key := provider.NormalizeBarcode(rec.Code)
+		if _, ok := p.byCode[key]; ok {
+			continue
+		}
+		p.byCode[key] = len(p.products)
+		p.products = append(p.products, rec.product())
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading open food facts dump")
+	}
+
+	return &p, nil
+}
+
+// Name implements provider.FoodProvider.
+func (p *Provider) Name() string {
+	return provider.OpenFoodFacts
+}
+
+// Search implements provider.FoodProvider. Products match when their name or
+// brand holds any word of the search input, or every word when all words are
+// required. Data types other than Branded match nothing, as every product of
+// Open Food Facts is a branded one. Products can be sorted by description only.
+func (p *Provider) Search(ctx context.Context, q provider.Query) (*provider.SearchResult, error) {
+	_, span := trace.StartSpan(ctx, "internal.off.Search")
+	defer span.End()
+
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+	result := provider.SearchResult{CurrentPage: page}
+
+	if len(q.DataTypes) != 0 && !contains(q.DataTypes, "Branded") {
+		return &result, nil
+	}
+
+	words := strings.Fields(strings.ToLower(q.SearchInput))
+	if len(words) == 0 {
+		return &result, nil
+	}
+	brand := strings.ToLower(q.BrandOwner)
+	ingredients := strings.ToLower(q.Ingredients)
+
+	var found []int
+	for i := range p.products {
+		pr := &p.products[i]
+		if brand != "" && !strings.Contains(pr.brands, brand) {
+			continue
+		}
+		if ingredients != "" && !strings.Contains(pr.ingredients, ingredients) {
+			continue
+		}
+		if matches(pr.text, words, q.RequireAllWords) {
+			found = append(found, i)
+		}
+	}
+
+	if q.SortField == "lowercaseDescription.keyword" {
+		sort.SliceStable(found, func(i, j int) bool {
+			a, b := strings.ToLower(p.products[found[i]].name), strings.ToLower(p.products[found[j]].name)
+			if q.SortDirection == "desc" {
+				return a > b
+			}
+			return a < b
+		})
+	}
+
+	result.TotalHits = len(found)
+	result.TotalPages = (len(found) + pageSize - 1) / pageSize
+
+	start := (page - 1) * pageSize
+	if start > len(found) {
+		start = len(found)
+	}
+	end := start + pageSize
+	if end > len(found) {
+		end = len(found)
+	}
+	for _, i := range found[start:end] {
+		pr := &p.products[i]
+		result.Foods = append(result.Foods, provider.Item{
+			Provider:    provider.OpenFoodFacts,
+			ID:          pr.code,
+			Description: pr.name,
+			BrandOwner:  pr.brand,
+			Barcode:     pr.code,
+			DataType:    "Branded",
+		})
+	}
+
+	return &result, nil
+}
+
+// Details implements provider.FoodProvider, id is the barcode of the product.
+func (p *Provider) Details(ctx context.Context, id string) (*provider.Food, error) {
+	return p.Lookup(ctx, id)
+}
+
+// Lookup implements provider.FoodProvider.
+func (p *Provider) Lookup(ctx context.Context, barcode string) (*provider.Food, error) {
+	i, ok := p.byCode[provider.NormalizeBarcode(barcode)]
+	if !ok {
+		return nil, errors.Wrapf(provider.ErrNotFound, "barcode %s", barcode)
+	}
+	pr := &p.products[i]
+
+	f := provider.Food{
+		Provider:    provider.OpenFoodFacts,
+		ID:          pr.code,
+		Description: pr.name,
+		BrandOwner:  pr.brand,
+		Barcode:     pr.code,
+		Nutrients:   pr.nutrients,
+	}
+	if pr.servingGram > 0 {
+		desc := pr.servingSize
+		if desc == "" {
+			desc = "1 serving"
+		}
+		f.Portions = []provider.Portion{{GramWeight: pr.servingGram, Description: desc}}
+	}
+	return &f, nil
+}
+
+// matches reports whether text holds any of words, or all of them.
+func matches(text string, words []string, all bool) bool {
+	for _, w := range words {
+		ok := strings.Contains(text, w)
+		if ok && !all {
+			return true
+		}
+		if !ok && all {
+			return false
+		}
+	}
+	return all
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// record is the product as the dump holds it.
+type record struct {
+	Code            string    `json:"code"`
+	ProductName     string    `json:"product_name"`
+	Brands          string    `json:"brands"`
+	IngredientsText string    `json:"ingredients_text"`
+	ServingSize     string    `json:"serving_size"`
+	ServingQuantity number    `json:"serving_quantity"`
+	Nutriments      nutriment `json:"nutriments"`
+}
+
+// nutriment holds amounts of nutrients per 100 grams.
+type nutriment struct {
+	Energy        *number `json:"energy-kcal_100g"`
+	Proteins      *number `json:"proteins_100g"`
+	Fat           *number `json:"fat_100g"`
+	Carbohydrates *number `json:"carbohydrates_100g"`
+	Sugars        *number `json:"sugars_100g"`
+	Fiber         *number `json:"fiber_100g"`
+	Sodium        *number `json:"sodium_100g"`
+}
+
+// product converts the record to the product kept in memory. Nutrients are
+// named and numbered like Food Data Central does, so they are read the same
+// way. Carbohydrates have no number, as labels in Europe give available
+// carbohydrates, not carbohydrates by difference.
+func (r record) product() product {
+	pr := product{
+		code:        r.Code,
+		name:        strings.TrimSpace(r.ProductName),
+		brand:       firstBrand(r.Brands),
+		servingSize: strings.TrimSpace(r.ServingSize),
+		servingGram: float64(r.ServingQuantity),
+		brands:      strings.ToLower(r.Brands),
+		ingredients: strings.ToLower(r.IngredientsText),
+	}
+	pr.text = strings.ToLower(pr.name) + " " + pr.brands
+
+	add := func(v *number, nt nutrients.Nutrient, scale float64) {
+		if v == nil {
+			return
+		}
+		pr.nutrients = append(pr.nutrients, nt.Amount(float64(*v)*scale))
+	}
+	available, _ := nutrients.ByTag(nutrients.AvailableCarbohydrate)
+
+	n := r.Nutriments
+	add(n.Proteins, nutrients.MustLookup(nutrients.Protein), 1)
+	add(n.Fat, nutrients.MustLookup(nutrients.Fat), 1)
+	add(n.Carbohydrates, available, 1)
+	add(n.Energy, nutrients.MustLookup(nutrients.Energy), 1)
+	add(n.Sugars, nutrients.MustLookup(nutrients.Sugars), 1)
+	add(n.Fiber, nutrients.MustLookup(nutrients.Fiber), 1)
+	add(n.Sodium, nutrients.MustLookup(nutrients.Sodium), 1000)
+
+	return pr
+}
+
+// firstBrand returns the first of comma separated brands.
+func firstBrand(brands string) string {
+	if i := strings.Index(brands, ","); i >= 0 {
+		brands = brands[:i]
+	}
+	return strings.TrimSpace(brands)
+}
+
+// number is the number the dump holds either as json number or as string.
+type number float64
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *number) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil {
+		// Values which are not numbers are left out like missing ones.
+		return nil
+	}
+	*n = number(f)
+	return nil
+}
@@ -0,0 +1,95 @@
+package off
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/sugar/internal/provider"
+	"github.com/igomonov88/sugar/internal/tests"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestProvider(t *testing.T) {
+	t.Log("Given the need to look foods up in Open Food Facts dump.")
+	{
+		ctx := tests.Context()
+
+		p, err := Open("testdata/products.jsonl")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to read the dump : %s.", failed, err)
+		}
+		if len(p.products) != 4 {
+			t.Fatalf("\t%s\tShould skip invalid lines, read %d products.", failed, len(p.products))
+		}
+		t.Logf("\t%s\tShould be able to read the dump.", success)
+
+		t.Log("\tWhen searching for the products.")
+		{
+			sr, err := p.Search(ctx, provider.Query{SearchInput: "mars"})
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to search : %s.", failed, err)
+			}
+			if sr.TotalHits != 2 || sr.Foods[0].Provider != provider.OpenFoodFacts {
+				t.Fatalf("\t%s\tShould find 2 products of open food facts : %+v.", failed, sr)
+			}
+
+			sr, _ = p.Search(ctx, provider.Query{SearchInput: "mars chocolate", RequireAllWords: true})
+			if sr.TotalHits != 1 || sr.Foods[0].Barcode != "0040000002635" {
+				t.Fatalf("\t%s\tShould require all words : %+v.", failed, sr)
+			}
+
+			sr, _ = p.Search(ctx, provider.Query{SearchInput: "chocolate", BrandOwner: "ferrero"})
+			if sr.TotalHits != 1 || sr.Foods[0].BrandOwner != "Kinder" {
+				t.Fatalf("\t%s\tShould filter by brand : %+v.", failed, sr)
+			}
+
+			sr, _ = p.Search(ctx, provider.Query{SearchInput: "mars", DataTypes: []string{"SR Legacy"}})
+			if sr.TotalHits != 0 {
+				t.Fatalf("\t%s\tShould find nothing but branded foods : %+v.", failed, sr)
+			}
+			t.Logf("\t%s\tShould be able to search.", success)
+		}
+
+		t.Log("\tWhen looking the product up by barcode.")
+		{
+			f, err := p.Lookup(ctx, "3017620422003")
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to look the product up : %s.", failed, err)
+			}
+			if f.Description != "Nutella" || len(f.Portions) != 1 || f.Portions[0].GramWeight != 15 {
+				t.Fatalf("\t%s\tShould get the product with serving : %+v.", failed, f)
+			}
+			var carbs, sodium float64
+			for _, n := range f.Nutrients {
+				switch {
+				case strings.EqualFold(n.Name, "carbohydrates"):
+					carbs = n.Amount
+				case n.Number == "307":
+					sodium = n.Amount
+				}
+			}
+			if carbs != 57.5 || sodium < 42.7 || sodium > 42.9 {
+				t.Fatalf("\t%s\tShould get nutrients per 100 g : carbs %v, sodium %v mg.", failed, carbs, sodium)
+			}
+
+			if f, err := p.Lookup(ctx, "4008400401621"); err != nil || f.Nutrients[0].Amount != 53.5 || f.Portions[0].GramWeight != 12.5 {
+				t.Fatalf("\t%s\tShould read numbers given as strings : %+v, %v.", failed, f, err)
+			}
+
+			if f, err := p.Lookup(ctx, "040000002635"); err != nil || f.ID != "0040000002635" {
+				t.Fatalf("\t%s\tShould match UPC with EAN form of the barcode : %+v, %v.", failed, f, err)
+			}
+
+			if _, err := p.Lookup(ctx, "1"); errors.Cause(err) != provider.ErrNotFound {
+				t.Fatalf("\t%s\tShould get ErrNotFound for unknown barcode : %v.", failed, err)
+			}
+			t.Logf("\t%s\tShould be able to look the product up by barcode.", success)
+		}
+	}
+}
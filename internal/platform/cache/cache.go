@@ -115,6 +115,17 @@ func get(cache *Cache, key string) (interface{}, bool) {
 	return nil, false
 }
 
+// Delete removes the item with given key from the cache, if it is there.
+func (c *Cache) Delete(key string) {
+	c.lock.Lock()
+	if element, ok := c.items[key]; ok {
+		c.entryList.Remove(element)
+		delete(c.items, key)
+		c.currentSize--
+	}
+	c.lock.Unlock()
+}
+
 // Purge knows hot to purge cache
 func (c *Cache) Purge() {
 	c.lock.Lock()
@@ -40,6 +40,18 @@ func TestCache(t *testing.T) {
 			t.Logf("\t%s\t Should be able to get the same value as was pushed.", success)
 		}
 
+		{
+			cache.Add("other", 1)
+			cache.Delete("key")
+			if _, exist := cache.Get("key"); exist {
+				t.Fatalf("\t%s\t Should be able to delete item from the cache.", failed)
+			}
+			if _, exist := cache.Get("other"); !exist {
+				t.Fatalf("\t%s\t Should keep other items when deleting item from the cache.", failed)
+			}
+			t.Logf("\t%s\t Should be able to delete item from the cache.", success)
+		}
+
 		{
 			cache.Purge()
 			if _, exist := cache.Get("key"); exist {
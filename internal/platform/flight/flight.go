@@ -0,0 +1,152 @@
+// Package flight coalesces concurrent calls made for the same key, so the
+// expensive work behind them, like a call to external api, is done only once.
+package flight
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+)
+
+// ErrNoResult is the error of the key fn of DoBatch returned no result for.
+var ErrNoResult = errors.New("no result for the key")
+
+// call is the work in progress for one key.
+type call struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// Group coalesces calls made for the same key while the first of them is in
+// progress.
+type Group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+
+	// coalesced counts calls which shared the result of another call, by
+	// kind of the call.
+	coalesced *expvar.Map
+}
+
+// New constructs the group which counts coalesced calls by kind in given
+// expvar map. The counter is not kept when coalesced is nil.
+func New(coalesced *expvar.Map) *Group {
+	return &Group{
+		calls:     make(map[string]*call),
+		coalesced: coalesced,
+	}
+}
+
+// Do calls fn and returns its result. Calls made for the same kind and key
+// while fn is in progress wait for it and get the same result, shared reports
+// whether the result was shared with other callers. Kind is a fixed name of
+// the call e.g. search, coalesced calls are counted by it, as keys may be
+// anything the users ask for.
+func (g *Group) Do(kind, key string, fn func() (interface{}, error)) (value interface{}, err error, shared bool) {
+	key = kind + ":" + key
+
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		if g.coalesced != nil {
+			g.coalesced.Add(kind, 1)
+		}
+		c.wg.Wait()
+		return c.value, c.err, true
+	}
+
+	c := call{}
+	c.wg.Add(1)
+	g.calls[key] = &c
+	g.mu.Unlock()
+
+	// Forget the key even when fn panics, so later calls are not stuck.
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.value, c.err = fn()
+	return c.value, c.err, false
+}
+
+// Result is the result of the call for one key of DoBatch.
+type Result struct {
+	Value  interface{}
+	Err    error
+	Shared bool
+}
+
+// DoBatch is Do for several keys of the same kind. Keys which are in progress,
+// whether by Do or by DoBatch, wait for their calls, the rest are given to fn
+// in one call, which returns results by key. Calls made for these keys while
+// fn is in progress wait for it. Results are returned by key, keys fn gave no
+// result for get ErrNoResult.
+func (g *Group) DoBatch(kind string, keys []string, fn func(keys []string) map[string]Result) map[string]Result {
+	waiting := make(map[string]*call)
+	owned := make(map[string]*call)
+	var own []string
+
+	g.mu.Lock()
+	for _, key := range keys {
+		if _, ok := owned[key]; ok {
+			continue
+		}
+		if c, ok := g.calls[kind+":"+key]; ok {
+			waiting[key] = c
+			continue
+		}
+		c := call{err: ErrNoResult}
+		c.wg.Add(1)
+		g.calls[kind+":"+key] = &c
+		owned[key] = &c
+		own = append(own, key)
+	}
+	g.mu.Unlock()
+	if g.coalesced != nil && len(waiting) != 0 {
+		g.coalesced.Add(kind, int64(len(waiting)))
+	}
+
+	results := make(map[string]Result, len(keys))
+	if len(own) != 0 {
+		g.doOwned(kind, owned, own, fn)
+		for _, key := range own {
+			results[key] = Result{Value: owned[key].value, Err: owned[key].err}
+		}
+	}
+
+	// Waiting comes after fn, so batches waiting for keys of each other can
+	// not get stuck.
+	for key, c := range waiting {
+		c.wg.Wait()
+		results[key] = Result{Value: c.value, Err: c.err, Shared: true}
+	}
+
+	return results
+}
+
+// doOwned calls fn for the keys DoBatch registered calls for and sets results
+// of the calls.
+func (g *Group) doOwned(kind string, owned map[string]*call, own []string, fn func(keys []string) map[string]Result) {
+
+	// Forget the keys even when fn panics, so later calls are not stuck.
+	defer func() {
+		g.mu.Lock()
+		for _, key := range own {
+			delete(g.calls, kind+":"+key)
+		}
+		g.mu.Unlock()
+		for _, c := range owned {
+			c.wg.Done()
+		}
+	}()
+
+	for key, r := range fn(own) {
+		if c, ok := owned[key]; ok {
+			c.value, c.err = r.Value, r.Err
+		}
+	}
+}
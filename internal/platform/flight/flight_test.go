@@ -0,0 +1,156 @@
+package flight
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestGroup(t *testing.T) {
+	t.Log("Given the need to coalesce concurrent calls for the same key.")
+	{
+		coalesced := new(expvar.Map).Init()
+		g := New(coalesced)
+
+		t.Log("\tWhen many calls for the same key are made at once.")
+		{
+			var calls int32
+			release := make(chan struct{})
+
+			const n = 10
+			var wg sync.WaitGroup
+			values := make([]interface{}, n)
+			wg.Add(n)
+			for i := 0; i < n; i++ {
+				go func(i int) {
+					defer wg.Done()
+					values[i], _, _ = g.Do("search", "apple", func() (interface{}, error) {
+						atomic.AddInt32(&calls, 1)
+						<-release
+						return "apple, raw", nil
+					})
+				}(i)
+			}
+
+			// Let the waiters line up behind the first call.
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			if got := atomic.LoadInt32(&calls); got != 1 {
+				t.Fatalf("\t%s\tShould call fn once, called %d times.", failed, got)
+			}
+			for i := range values {
+				if values[i] != "apple, raw" {
+					t.Fatalf("\t%s\tShould share the result with every caller : %v.", failed, values[i])
+				}
+			}
+			if v, ok := coalesced.Get("search").(*expvar.Int); !ok || v.Value() != n-1 {
+				t.Fatalf("\t%s\tShould count %d coalesced calls : %v.", failed, n-1, coalesced.Get("search"))
+			}
+			t.Logf("\t%s\tShould call fn once and share the result.", success)
+		}
+
+		t.Log("\tWhen the call for the key is over.")
+		{
+			want := errors.New("failed")
+			_, err, shared := g.Do("search", "apple", func() (interface{}, error) {
+				return nil, want
+			})
+			if err != want || shared {
+				t.Fatalf("\t%s\tShould call fn again : %v, shared %v.", failed, err, shared)
+			}
+			t.Logf("\t%s\tShould call fn again.", success)
+		}
+	}
+}
+
+func TestGroupBatch(t *testing.T) {
+	t.Log("Given the need to coalesce batch calls with calls for single keys.")
+	{
+		coalesced := new(expvar.Map).Init()
+		g := New(coalesced)
+
+		t.Log("\tWhen one of the keys is in progress.")
+		{
+			release := make(chan struct{})
+			started := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				g.Do("details", "1", func() (interface{}, error) {
+					close(started)
+					<-release
+					return "apple, raw", nil
+				})
+			}()
+			<-started
+
+			var got []string
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				close(release)
+			}()
+			results := g.DoBatch("details", []string{"1", "2", "3", "2"}, func(keys []string) map[string]Result {
+				got = keys
+				return map[string]Result{"2": {Value: "banana, raw"}}
+			})
+			<-done
+
+			if len(got) != 2 || got[0] != "2" || got[1] != "3" {
+				t.Fatalf("\t%s\tShould call fn for the keys not in progress once : %v.", failed, got)
+			}
+			if r := results["1"]; r.Value != "apple, raw" || r.Err != nil || !r.Shared {
+				t.Fatalf("\t%s\tShould share the result of the key in progress : %+v.", failed, r)
+			}
+			if r := results["2"]; r.Value != "banana, raw" || r.Err != nil || r.Shared {
+				t.Fatalf("\t%s\tShould return the result of fn : %+v.", failed, r)
+			}
+			if r := results["3"]; r.Err != ErrNoResult {
+				t.Fatalf("\t%s\tShould return ErrNoResult for the key fn left out : %+v.", failed, r)
+			}
+			if v, ok := coalesced.Get("details").(*expvar.Int); !ok || v.Value() != 1 {
+				t.Fatalf("\t%s\tShould count 1 coalesced call : %v.", failed, coalesced.Get("details"))
+			}
+			t.Logf("\t%s\tShould wait for the key in progress and fetch the rest.", success)
+		}
+
+		t.Log("\tWhen a single call is made for a key of the batch in progress.")
+		{
+			release := make(chan struct{})
+			started := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				g.DoBatch("details", []string{"1", "2"}, func(keys []string) map[string]Result {
+					close(started)
+					<-release
+					return map[string]Result{"1": {Value: "apple, raw"}, "2": {Value: "banana, raw"}}
+				})
+			}()
+			<-started
+
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				close(release)
+			}()
+			v, err, shared := g.Do("details", "2", func() (interface{}, error) {
+				return nil, errors.New("called")
+			})
+			<-done
+
+			if v != "banana, raw" || err != nil || !shared {
+				t.Fatalf("\t%s\tShould share the result of the batch : %v, %v, shared %v.", failed, v, err, shared)
+			}
+			t.Logf("\t%s\tShould share the result of the batch.", success)
+		}
+	}
+}
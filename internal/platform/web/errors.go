@@ -11,7 +11,7 @@ type FieldError struct {
 // ErrorResponse is the form used for API responses from failures in the API.
 type ErrorResponse struct {
 	Error  string       `json:"error"`
-	Fields []FieldError `json:"fields, omitempty"`
+	Fields []FieldError `json:"fields,omitempty"`
 }
 
 // Error is used to pass an error during the request through the
@@ -55,6 +55,7 @@ func ResponseError(ctx context.Context, w http.ResponseWriter, err error) error
 		if err := Respond(ctx, w, er, webErr.Status); err != nil {
 			return err
 		}
+		return nil
 	}
 
 	// If not, the handler sent any arbitrary error value so use 500.
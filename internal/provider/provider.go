@@ -0,0 +1,240 @@
+// Package provider describes the sources of food data the service looks foods
+// up in, so handlers do not depend on any of them directly.
+package provider
+
+import (
+	"context"
+	"errors"
+)
+
+// Names of the providers, also recorded in storage with every food.
+const (
+	FDC           = "fdc"
+	OpenFoodFacts = "off"
+)
+
+var (
+	// ErrNotFound is returned when the provider does not know the food.
+	ErrNotFound = errors.New("food not found")
+
+	// ErrInvalidBarcode is returned when the barcode is not a valid EAN-8,
+	// UPC-A, EAN-13 or GTIN-14 code.
+	ErrInvalidBarcode = errors.New("invalid barcode")
+)
+
+// FoodProvider is the source of food data.
+type FoodProvider interface {
+	// Name returns the name of the provider.
+	Name() string
+
+	// Search returns foods matching the query. Providers apply the criteria
+	// they support and ignore the rest.
+	Search(ctx context.Context, q Query) (*SearchResult, error)
+
+	// Details returns the food with given id of the provider.
+	Details(ctx context.Context, id string) (*Food, error)
+
+	// Lookup returns the food with given barcode, i.e. GTIN, UPC or EAN.
+	Lookup(ctx context.Context, barcode string) (*Food, error)
+}
+
+// BatchProvider is implemented by providers which return several foods in one
+// call.
+type BatchProvider interface {
+	// DetailsBatch returns the foods with given ids of the provider, foods
+	// the provider does not know are left out. When the call fails part way,
+	// foods got before are returned along with *BatchError.
+	DetailsBatch(ctx context.Context, ids []string) ([]*Food, error)
+}
+
+// BatchError is returned by DetailsBatch for ids which were not looked up,
+// Err is the error they were not looked up because of.
+type BatchError struct {
+	IDs []string
+	Err error
+}
+
+// Error implements the error interface.
+func (e *BatchError) Error() string {
+	return e.Err.Error()
+}
+
+// Cause returns the error the ids were not looked up because of, so
+// errors.Cause gets to it.
+func (e *BatchError) Cause() error {
+	return e.Err
+}
+
+// Query holds the criteria of the food search.
+type Query struct {
+	SearchInput     string
+	DataTypes       []string
+	BrandOwner      string
+	Ingredients     string
+	RequireAllWords bool
+	Page            int
+	SortField       string
+	SortDirection   string
+}
+
+// SearchResult is the page of foods found by the provider.
+type SearchResult struct {
+	TotalHits   int
+	CurrentPage int
+	TotalPages  int
+	Foods       []Item
+}
+
+// Item is the food found by the search.
+type Item struct {
+	Provider    string
+	ID          string
+	Description string
+	BrandOwner  string
+	Barcode     string
+
+	// DataType is the data type of Food Data Central the food belongs to,
+	// e.g. Branded or Foundation. Products of other providers are Branded.
+	DataType string
+
+	// PublishedDate is the date the food was published in YYYY-MM-DD form,
+	// and FoodCategory is the category of the food, when known.
+	PublishedDate string
+	FoodCategory  string
+}
+
+// Food is the food with its nutrients and portions. Nutrient amounts are per
+// 100 grams.
+type Food struct {
+	Provider    string
+	ID          string
+	Description string
+	BrandOwner  string
+	Barcode     string
+	Nutrients   []Nutrient
+	Portions    []Portion
+
+	// FoodCategory is the category of the food, when known.
+	FoodCategory string
+
+	// Serving is the serving stated on the label of the product, nil when the
+	// food has no label.
+	Serving *Serving
+}
+
+// Serving is the serving of the product as its label states it.
+type Serving struct {
+	// Size is the amount of the serving in Unit, which is g or ml.
+	Size float64
+	Unit string
+
+	// Description is the serving in household measure, e.g. "1 bar".
+	Description string
+
+	// Nutrients are amounts of nutrients per serving as labelled, named and
+	// numbered the same way Nutrients of the food are.
+	Nutrients []Nutrient
+}
+
+// Nutrient is the amount of the nutrient in the food.
+type Nutrient struct {
+	// Number is the nutrient number used by USDA, e.g. "205" for
+	// carbohydrates, when known.
+	Number   string
+	Name     string
+	Amount   float64
+	UnitName string
+
+	// Rank is the position of the nutrient in the lists of USDA, 0 when
+	// not known.
+	Rank int
+}
+
+// Portion is the common measure of the food.
+type Portion struct {
+	GramWeight  float64
+	Description string
+}
+
+// Merge merges search results of providers given in priority order. The same
+// product found by several providers, as told by its barcode, is kept only
+// from the provider with the highest priority. Failed providers are given as
+// nil results.
+func Merge(results ...*SearchResult) *SearchResult {
+	var merged SearchResult
+	seen := make(map[string]bool)
+
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		merged.TotalHits += r.TotalHits
+		if r.TotalPages > merged.TotalPages {
+			merged.TotalPages = r.TotalPages
+		}
+		if r.CurrentPage > merged.CurrentPage {
+			merged.CurrentPage = r.CurrentPage
+		}
+		for _, item := range r.Foods {
+			if item.Barcode != "" {
+				key := NormalizeBarcode(item.Barcode)
+				if seen[key] {
+					merged.TotalHits--
+					continue
+				}
+				seen[key] = true
+			}
+			merged.Foods = append(merged.Foods, item)
+		}
+	}
+
+	return &merged
+}
+
+// NormalizeBarcode returns the barcode as GTIN-14, so UPC-A, EAN-13 and
+// GTIN-14 forms of the same code are equal. Barcodes which are not numeric
+// are returned as is.
+func NormalizeBarcode(barcode string) string {
+	for _, r := range barcode {
+		if r < '0' || r > '9' {
+			return barcode
+		}
+	}
+	const gtin14 = "00000000000000"
+	if len(barcode) >= len(gtin14) {
+		return barcode
+	}
+	return gtin14[len(barcode):] + barcode
+}
+
+// GTIN validates EAN-8, UPC-A, EAN-13 or GTIN-14 barcode by its check digit and
+// returns it as GTIN-14.
+func GTIN(barcode string) (string, error) {
+	switch len(barcode) {
+	case 8, 12, 13, 14:
+	default:
+		return "", ErrInvalidBarcode
+	}
+
+	// Digits are weighted 3 and 1 alternately, starting with 3 from the digit
+	// next to the check digit.
+	sum := 0
+	for i := len(barcode) - 1; i >= 0; i-- {
+		d := int(barcode[i] - '0')
+		if d < 0 || d > 9 {
+			return "", ErrInvalidBarcode
+		}
+		if i == len(barcode)-1 {
+			continue
+		}
+		if (len(barcode)-1-i)%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	if check := (10 - sum%10) % 10; check != int(barcode[len(barcode)-1]-'0') {
+		return "", ErrInvalidBarcode
+	}
+
+	return NormalizeBarcode(barcode), nil
+}
@@ -0,0 +1,72 @@
+package provider
+
+import "testing"
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestMerge(t *testing.T) {
+	t.Log("Given the need to merge search results of providers in priority order.")
+	{
+		fdc := SearchResult{
+			TotalHits:   2,
+			CurrentPage: 1,
+			TotalPages:  1,
+			Foods: []Item{
+				{Provider: FDC, ID: "1104067", Description: "MARS CHOCOLATE BAR", Barcode: "040000002635"},
+				{Provider: FDC, ID: "171688", Description: "Apples, raw, with skin"},
+			},
+		}
+		off := SearchResult{
+			TotalHits:   2,
+			CurrentPage: 1,
+			TotalPages:  3,
+			Foods: []Item{
+				{Provider: OpenFoodFacts, ID: "0040000002635", Description: "Mars", Barcode: "0040000002635"},
+				{Provider: OpenFoodFacts, ID: "5000159407236", Description: "Mars bar", Barcode: "5000159407236"},
+			},
+		}
+
+		m := Merge(&fdc, nil, &off)
+
+		if len(m.Foods) != 3 || m.TotalHits != 3 {
+			t.Fatalf("\t%s\tShould drop the product found by both providers : %+v.", failed, m)
+		}
+		if m.Foods[0].Provider != FDC || m.Foods[2].ID != "5000159407236" {
+			t.Fatalf("\t%s\tShould keep the items in priority order : %+v.", failed, m.Foods)
+		}
+		if m.TotalPages != 3 {
+			t.Fatalf("\t%s\tShould report the most pages of providers : %d.", failed, m.TotalPages)
+		}
+		t.Logf("\t%s\tShould merge search results in priority order.", success)
+	}
+}
+
+func TestGTIN(t *testing.T) {
+	t.Log("Given the need to validate barcodes.")
+	{
+		valid := map[string]string{
+			"040000002635":   "00040000002635",
+			"0040000002635":  "00040000002635",
+			"00040000002635": "00040000002635",
+			"3017620422003":  "03017620422003",
+			"96385074":       "00000096385074",
+		}
+		for barcode, want := range valid {
+			got, err := GTIN(barcode)
+			if err != nil || got != want {
+				t.Fatalf("\t%s\tShould accept %s as %s : %s, %v.", failed, barcode, want, got, err)
+			}
+		}
+		t.Logf("\t%s\tShould accept valid barcodes as GTIN-14.", success)
+
+		for _, barcode := range []string{"040000002636", "3017620422", "30176204220O3", ""} {
+			if _, err := GTIN(barcode); err != ErrInvalidBarcode {
+				t.Fatalf("\t%s\tShould reject %q : %v.", failed, barcode, err)
+			}
+		}
+		t.Logf("\t%s\tShould reject invalid barcodes.", success)
+	}
+}
@@ -71,4 +71,148 @@ var migrations = []darwin.Migration{
 		FOREIGN KEY (fdc_id) REFERENCES food(fdc_id));
 	`,
 	},
+	{
+		Version:     6,
+		Description: "Add unique indexes to carbohydrates and portions tables",
+		Script: `
+	DELETE FROM carbohydrates AS a USING carbohydrates AS b
+		WHERE a.id > b.id AND a.fdc_id = b.fdc_id;
+	CREATE UNIQUE INDEX IF NOT EXISTS uidx_carbohydrates_fdc_id ON carbohydrates(fdc_id);
+	DELETE FROM portions AS a USING portions AS b
+		WHERE a.id > b.id AND a.fdc_id = b.fdc_id
+		AND a.gram_weight = b.gram_weight AND a.description = b.description;
+	CREATE UNIQUE INDEX IF NOT EXISTS uidx_portions_fdc_id_gram_weight_description
+		ON portions(fdc_id, gram_weight, description);`,
+	},
+	{
+		Version:     7,
+		Description: "Add import_progress table",
+		Script: `
+	CREATE TABLE IF NOT EXISTS import_progress (
+		file VARCHAR PRIMARY KEY,
+		rows BIGINT NOT NULL DEFAULT 0,
+		done BOOLEAN NOT NULL DEFAULT FALSE,
+		date_updated TIMESTAMP NOT NULL DEFAULT now()
+	);`,
+	},
+	{
+		Version:     8,
+		Description: "Add provider and barcode to food table",
+		Script: `
+	ALTER TABLE food ADD COLUMN IF NOT EXISTS provider VARCHAR NOT NULL DEFAULT 'fdc';
+	ALTER TABLE food ADD COLUMN IF NOT EXISTS barcode VARCHAR;
+	ALTER TABLE food ALTER COLUMN fdc_id DROP NOT NULL;
+	CREATE UNIQUE INDEX IF NOT EXISTS uidx_food_provider_barcode
+		ON food(provider, barcode) WHERE fdc_id IS NULL;
+	ALTER TABLE search_food ADD COLUMN IF NOT EXISTS food_id INT REFERENCES food(id);
+	UPDATE search_food AS s SET food_id = f.id FROM food AS f WHERE f.fdc_id = s.fdc_id;
+	ALTER TABLE search_food ALTER COLUMN fdc_id DROP NOT NULL;`,
+	},
+	{
+		Version:     9,
+		Description: "Add gtin column to food table",
+		Script: `
+	ALTER TABLE food ADD COLUMN IF NOT EXISTS gtin VARCHAR(14);
+	UPDATE food SET gtin = lpad(barcode, 14, '0') WHERE barcode ~ '^[0-9]{8,14}$';
+	CREATE INDEX IF NOT EXISTS idx_food_gtin ON food(gtin);`,
+	},
+	{
+		Version:     10,
+		Description: "Add data type and publication metadata to food table",
+		Script: `
+	ALTER TABLE food ADD COLUMN IF NOT EXISTS data_type VARCHAR;
+	ALTER TABLE food ADD COLUMN IF NOT EXISTS published_date DATE;
+	ALTER TABLE food ADD COLUMN IF NOT EXISTS food_category VARCHAR;
+	UPDATE food SET data_type = 'Branded' WHERE data_type IS NULL AND brand_owner IS NOT NULL;`,
+	},
+	{
+		Version:     11,
+		Description: "Add servings table",
+		Script: `
+	CREATE TABLE IF NOT EXISTS servings (
+		fdc_id INT PRIMARY KEY,
+		size FLOAT NOT NULL,
+		unit VARCHAR NOT NULL,
+		description VARCHAR,
+		carbohydrates FLOAT,
+		carbohydrates_unit VARCHAR,
+		FOREIGN KEY (fdc_id) REFERENCES food(fdc_id));`,
+	},
+	{
+		Version:     12,
+		Description: "Add nutrients and food_nutrients tables",
+		Script: `
+	CREATE TABLE IF NOT EXISTS nutrients (
+		id SERIAL PRIMARY KEY,
+		number INT,
+		name VARCHAR NOT NULL,
+		rank INT,
+		unit_name VARCHAR NOT NULL,
+		UNIQUE (name, unit_name)
+	);
+	CREATE TABLE IF NOT EXISTS food_nutrients (
+		fdc_id INT NOT NULL,
+		nutrient_id INT NOT NULL,
+		type VARCHAR,
+		amount FLOAT NOT NULL,
+		PRIMARY KEY (fdc_id, nutrient_id),
+		FOREIGN KEY (fdc_id) REFERENCES food(fdc_id),
+		FOREIGN KEY (nutrient_id) REFERENCES nutrients(id));`,
+	},
+	{
+		Version:     13,
+		Description: "Add glycemic_index table",
+		Script: `
+	CREATE TABLE IF NOT EXISTS glycemic_index (
+		id SERIAL PRIMARY KEY,
+		fdc_id INT,
+		food_category VARCHAR,
+		description VARCHAR,
+		gi FLOAT NOT NULL,
+		source VARCHAR NOT NULL,
+		CHECK (fdc_id IS NOT NULL OR food_category IS NOT NULL)
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS glycemic_index_food_idx
+		ON glycemic_index (source, COALESCE(fdc_id, 0), COALESCE(food_category, ''));
+	CREATE INDEX IF NOT EXISTS glycemic_index_fdc_id_idx ON glycemic_index (fdc_id);
+	CREATE INDEX IF NOT EXISTS glycemic_index_food_category_idx ON glycemic_index (food_category);`,
+	},
+	{
+		Version:     14,
+		Description: "Add recipes and recipe_ingredients tables",
+		Script: `
+	CREATE SEQUENCE IF NOT EXISTS recipe_fdc_id_seq START WITH 1000000000;
+	CREATE TABLE IF NOT EXISTS recipes (
+		fdc_id INT PRIMARY KEY,
+		name VARCHAR NOT NULL,
+		yield_grams FLOAT NOT NULL CHECK (yield_grams > 0),
+		servings FLOAT NOT NULL CHECK (servings > 0),
+		date_created TIMESTAMP NOT NULL DEFAULT now(),
+		date_updated TIMESTAMP NOT NULL DEFAULT now(),
+		FOREIGN KEY (fdc_id) REFERENCES food(fdc_id)
+	);
+	CREATE TABLE IF NOT EXISTS recipe_ingredients (
+		recipe_id INT NOT NULL,
+		position INT NOT NULL,
+		fdc_id INT NOT NULL,
+		grams FLOAT NOT NULL CHECK (grams > 0),
+		PRIMARY KEY (recipe_id, position),
+		FOREIGN KEY (recipe_id) REFERENCES recipes(fdc_id) ON DELETE CASCADE,
+		FOREIGN KEY (fdc_id) REFERENCES food(fdc_id)
+	);
+	CREATE INDEX IF NOT EXISTS recipe_ingredients_fdc_id_idx ON recipe_ingredients (fdc_id);`,
+	},
+	{
+		Version:     15,
+		Description: "Add trigram index on food description",
+		Script: `
+	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+	CREATE INDEX IF NOT EXISTS food_description_trgm_idx ON food USING GIN (description gin_trgm_ops);`,
+	},
+	{
+		Version:     16,
+		Description: "Add preference to carbohydrates",
+		Script: `
+	ALTER TABLE carbohydrates ADD COLUMN IF NOT EXISTS preference INT;`,
+	},
 }
@@ -2,61 +2,136 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/sugar/internal/provider"
 )
 
-// List used for getting the list of Food items.
-func List(ctx context.Context, db *sqlx.DB, searchInput string) ([]Food, error) {
+// listLimit is the most foods List returns, so broad search input does not
+// read a whole imported dataset.
+const listLimit = 200
+
+// List used for getting the list of Food items, ranked by given policy. Foods
+// are found by search input they were saved for, and by their description,
+// so foods of bulk imported dataset are found too. Recipes are not listed.
+//
+// Broad search input finds more foods than List returns, so foods are ranked
+// in the query before they are cut to the limit. Whether the search input
+// names the brand is only known from the foods found, so the query keeps the
+// best foods by both generic and branded order of the policy, and Rank picks
+// from them.
+func List(ctx context.Context, db *sqlx.DB, searchInput string, policy RankPolicy) ([]Food, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.storage.Search")
 	defer span.End()
 
 	var foods []Food
 
 	const selectFood = `
-	SELECT * FROM food WHERE fdc_id IN (
-	    SELECT fdc_id FROM search_food WHERE search_input LIKE '%' || $1 ||'%');`
+	WITH found AS (
+		SELECT id, COALESCE(fdc_id, 0) AS fdc_id, COALESCE(description, '') AS description,
+			COALESCE(brand_owner, '') AS brand_owner, provider, COALESCE(barcode, '') AS barcode,
+			COALESCE(data_type, '') AS data_type, published_date,
+			COALESCE(food_category, '') AS food_category
+		FROM food WHERE provider <> $2 AND (id IN (
+		    SELECT food_id FROM search_food WHERE search_input LIKE '%' || $1 ||'%')
+			OR description ILIKE '%' || $1 || '%')
+	), generic AS (
+		SELECT * FROM found
+		ORDER BY COALESCE(array_position($4::text[], data_type::text), cardinality($4::text[]) + 1),
+			published_date DESC NULLS LAST, id
+		LIMIT $3
+	), branded AS (
+		SELECT * FROM found
+		ORDER BY EXISTS (SELECT 1 FROM unnest($6::text[]) AS w WHERE lower(brand_owner) ~ ('\m' || w || '\M')) DESC,
+			COALESCE(array_position($5::text[], data_type::text), cardinality($5::text[]) + 1),
+			published_date DESC NULLS LAST, id
+		LIMIT $3
+	)
+	SELECT id, fdc_id, description, brand_owner, provider, barcode, data_type,
+		COALESCE(to_char(published_date, 'YYYY-MM-DD'), '') AS published_date, food_category
+	FROM (SELECT * FROM generic UNION SELECT * FROM branded) AS ranked;`
 
-	err := db.SelectContext(ctx, &foods, selectFood, searchInput)
+	args := []interface{}{
+		searchInput, RecipeProvider, listLimit,
+		pq.Array(policy.Generic), pq.Array(policy.Branded), pq.Array(rankWords(searchInput)),
+	}
+	if err := db.SelectContext(ctx, &foods, selectFood, args...); err != nil {
+		return nil, err
+	}
+	policy.Rank(foods, searchInput)
+	if len(foods) > listLimit {
+		foods = foods[:listLimit]
+	}
 
-	return foods, err
+	return foods, nil
 }
 
 // SaveSearchInput is saved provided food item with associated search input.
+// Foods of Food Data Central are identified by FDCID, foods of other providers
+// by provider and barcode. Foods already in storage are linked to the search
+// input as is.
 func SaveSearchInput(ctx context.Context, db *sqlx.DB, food Food, input string) error {
 	ctx, span := trace.StartSpan(ctx, "internal,storage.AddFood")
 	defer span.End()
 
 	const (
+		addFDCFood = `INSERT INTO food 
+		(fdc_id, description, brand_owner, provider, barcode, gtin, data_type, published_date, food_category)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''), NULLIF($8, '')::date, NULLIF($9, ''))
+		ON CONFLICT (fdc_id) DO UPDATE SET barcode = COALESCE(food.barcode, EXCLUDED.barcode),
+		gtin = COALESCE(food.gtin, EXCLUDED.gtin), data_type = COALESCE(EXCLUDED.data_type, food.data_type),
+		published_date = COALESCE(EXCLUDED.published_date, food.published_date),
+		food_category = COALESCE(EXCLUDED.food_category, food.food_category)
+		RETURNING id`
+
 		addFood = `INSERT INTO food 
-		(fdc_id, description, brand_owner) VALUES ($1, $2, $3)`
+		(description, brand_owner, provider, barcode, gtin, data_type, published_date, food_category)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, '')::date, NULLIF($8, ''))
+		ON CONFLICT (provider, barcode) WHERE fdc_id IS NULL DO UPDATE SET description = EXCLUDED.description
+		RETURNING id`
 
 		addFoodSearch = `INSERT INTO search_food 
-		(search_input, fdc_id) VALUES ($1, $2)`
+		(search_input, fdc_id, food_id) SELECT $1, NULLIF($2, 0), $3
+		WHERE NOT EXISTS (SELECT 1 FROM search_food WHERE search_input = $1 AND food_id = $3)`
 	)
 
+	if food.Provider == "" {
+		food.Provider = "fdc"
+	}
+	if food.FDCID == 0 && food.Barcode == "" {
+		return errors.New("food has neither fdc id nor barcode")
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
 		return errors.Wrap(err, "begin transaction")
 	}
 
-	_, err = tx.Exec(addFood, food.FDCID, food.Description, food.BrandOwner)
+	var id int
+	if food.FDCID != 0 {
+		err = tx.QueryRow(addFDCFood, food.FDCID, food.Description, food.BrandOwner, food.Provider, food.Barcode, gtin(food.Barcode),
+			food.DataType, food.PublishedDate, food.FoodCategory).Scan(&id)
+	} else {
+		err = tx.QueryRow(addFood, food.Description, food.BrandOwner, food.Provider, food.Barcode, gtin(food.Barcode),
+			food.DataType, food.PublishedDate, food.FoodCategory).Scan(&id)
+	}
 	if err != nil {
 		// TODO: handle rollback error
 		tx.Rollback()
 		return errors.Wrap(err, "inserting food to food")
 	}
-	_, err = tx.Exec(addFoodSearch, input, food.FDCID)
+	_, err = tx.Exec(addFoodSearch, input, food.FDCID, id)
 	if err != nil {
 		// TODO: handle rollback error
 		tx.Rollback()
 		return errors.Wrap(err, "inserting food search_food")
 	}
-	// TODO: handle commit error
-	tx.Commit()
-	return nil
+	return errors.Wrap(tx.Commit(), "commit transaction")
 }
 
 // RetrieveDetails returns Details and error if we got it.
@@ -66,7 +141,7 @@ func RetrieveDetails(ctx context.Context, db *sqlx.DB, fdcID int) (*DetailsRef,
 
 	const (
 		descriptionAndCarbsInfo = `
-		SELECT f.description, c.amount, c.unit_name FROM food AS f 
+		SELECT f.description, f.provider, c.amount, c.unit_name FROM food AS f 
 		INNER JOIN carbohydrates AS c ON f.fdc_id = c.fdc_id and c.fdc_id = $1 
 		FOR UPDATE;`
 		portionsInfo = `
@@ -97,9 +172,160 @@ func RetrieveDetails(ctx context.Context, db *sqlx.DB, fdcID int) (*DetailsRef,
 		return nil, err
 	}
 
+	details.Serving, err = retrieveServing(ctx, db, fdcID)
+	if err != nil {
+		return nil, err
+	}
+
+	nutrients, err := retrieveNutrientsBatch(ctx, db, []int{fdcID})
+	if err != nil {
+		return nil, err
+	}
+	details.Nutrients = nutrients[fdcID]
+
 	return &details, nil
 }
 
+// retrieveServing returns the labelled serving of the food, or nil when the
+// food has no label.
+func retrieveServing(ctx context.Context, db *sqlx.DB, fdcID int) (*Serving, error) {
+	const q = `
+	SELECT fdc_id, size, unit, COALESCE(description, '') AS description,
+		carbohydrates, COALESCE(carbohydrates_unit, '') AS carbohydrates_unit
+	FROM servings WHERE fdc_id = $1;`
+
+	var s Serving
+	switch err := db.GetContext(ctx, &s, q, fdcID); err {
+	case nil:
+		return &s, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// RetrievePortion returns the portion with given id.
+func RetrievePortion(ctx context.Context, db *sqlx.DB, id int) (*Portion, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.RetrievePortion")
+	defer span.End()
+
+	const q = `
+	SELECT id, fdc_id, gram_weight, description
+	FROM portions WHERE id = $1;`
+
+	var p Portion
+	if err := db.GetContext(ctx, &p, q, id); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// RetrieveDetailsBatch returns details of the foods with given fdcIDs in one
+// round trip to database. Foods which are not in storage are missing from the
+// result.
+func RetrieveDetailsBatch(ctx context.Context, db *sqlx.DB, fdcIDs []int) (map[int]*DetailsRef, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.RetrieveDetailsBatch")
+	defer span.End()
+
+	const q = `
+	SELECT f.fdc_id, f.description, f.provider, c.amount, c.unit_name,
+		p.id AS portion_id, p.gram_weight, p.description AS portion_description,
+		s.size AS serving_size, s.unit AS serving_unit, s.description AS serving_description,
+		s.carbohydrates AS serving_carbohydrates, s.carbohydrates_unit AS serving_carbohydrates_unit
+	FROM food AS f
+	INNER JOIN carbohydrates AS c ON f.fdc_id = c.fdc_id
+	LEFT JOIN portions AS p ON f.fdc_id = p.fdc_id
+	LEFT JOIN servings AS s ON f.fdc_id = s.fdc_id
+	WHERE f.fdc_id = ANY($1)
+	ORDER BY f.fdc_id, p.id;`
+
+	ids := make([]int64, len(fdcIDs))
+	for i := range fdcIDs {
+		ids[i] = int64(fdcIDs[i])
+	}
+
+	var rows []struct {
+		FDCID              int             `db:"fdc_id"`
+		Description        string          `db:"description"`
+		Provider           string          `db:"provider"`
+		Amount             float64         `db:"amount"`
+		UnitName           string          `db:"unit_name"`
+		PortionID          sql.NullInt64   `db:"portion_id"`
+		GramWeight         sql.NullFloat64 `db:"gram_weight"`
+		PortionDescription sql.NullString  `db:"portion_description"`
+
+		ServingSize              sql.NullFloat64 `db:"serving_size"`
+		ServingUnit              sql.NullString  `db:"serving_unit"`
+		ServingDescription       sql.NullString  `db:"serving_description"`
+		ServingCarbohydrates     sql.NullFloat64 `db:"serving_carbohydrates"`
+		ServingCarbohydratesUnit sql.NullString  `db:"serving_carbohydrates_unit"`
+	}
+	if err := db.SelectContext(ctx, &rows, q, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	details := make(map[int]*DetailsRef, len(fdcIDs))
+	for _, r := range rows {
+		d, ok := details[r.FDCID]
+		if !ok {
+			d = &DetailsRef{
+				Description: r.Description,
+				Provider:    r.Provider,
+				Carbohydrates: Carbohydrates{
+					FDCID:    r.FDCID,
+					Amount:   r.Amount,
+					UnitName: r.UnitName,
+				},
+			}
+			if r.ServingSize.Valid {
+				d.Serving = &Serving{
+					FDCID:             r.FDCID,
+					Size:              r.ServingSize.Float64,
+					Unit:              r.ServingUnit.String,
+					Description:       r.ServingDescription.String,
+					Carbohydrates:     r.ServingCarbohydrates,
+					CarbohydratesUnit: r.ServingCarbohydratesUnit.String,
+				}
+			}
+			details[r.FDCID] = d
+		}
+		if r.PortionID.Valid {
+			d.Portions = append(d.Portions, Portion{
+				ID:          int(r.PortionID.Int64),
+				FDCID:       r.FDCID,
+				GramWeight:  r.GramWeight.Float64,
+				Description: r.PortionDescription.String,
+			})
+		}
+	}
+
+	nutrients, err := retrieveNutrientsBatch(ctx, db, fdcIDs)
+	if err != nil {
+		return nil, err
+	}
+	for id, d := range details {
+		d.Nutrients = nutrients[id]
+	}
+
+	return details, nil
+}
+
+// SaveFood saves provided food item unless it is already in storage.
+func SaveFood(ctx context.Context, db *sqlx.DB, food Food) error {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.SaveFood")
+	defer span.End()
+
+	const addFood = `INSERT INTO food (fdc_id, description, brand_owner, barcode, gtin, food_category)
+	VALUES ($1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, '')) ON CONFLICT (fdc_id) DO UPDATE
+	SET food_category = COALESCE(food.food_category, EXCLUDED.food_category);`
+
+	if _, err := db.Exec(addFood, food.FDCID, food.Description, food.BrandOwner, food.Barcode, gtin(food.Barcode), food.FoodCategory); err != nil {
+		return errors.Wrap(err, "inserting food")
+	}
+	return nil
+}
+
 // SaveDetails save provided details to database.
 func SaveDetails(ctx context.Context, db *sqlx.DB, fdcID int, carbs Carbohydrates, portions []Portion) error {
 	ctx, span := trace.StartSpan(ctx, "internal.storage.SaveDetails")
@@ -144,3 +370,49 @@ func SaveDetails(ctx context.Context, db *sqlx.DB, fdcID int, carbs Carbohydrate
 	tx.Commit()
 	return nil
 }
+
+// SaveServing saves the labelled serving of the food unless it is already in
+// storage.
+func SaveServing(ctx context.Context, db *sqlx.DB, s Serving) error {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.SaveServing")
+	defer span.End()
+
+	const addServing = `INSERT INTO servings
+	(fdc_id, size, unit, description, carbohydrates, carbohydrates_unit)
+	VALUES ($1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, '')) ON CONFLICT (fdc_id) DO NOTHING;`
+
+	if _, err := db.Exec(addServing, s.FDCID, s.Size, s.Unit, s.Description, s.Carbohydrates, s.CarbohydratesUnit); err != nil {
+		return errors.Wrap(err, "inserting serving")
+	}
+	return nil
+}
+
+// FindByGTIN returns the food of Food Data Central with given GTIN-14 code.
+// Foods of other providers are not looked for, as storage has no details of
+// them.
+func FindByGTIN(ctx context.Context, db *sqlx.DB, gtin string) (*Food, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.FindByGTIN")
+	defer span.End()
+
+	const q = `
+	SELECT id, fdc_id, COALESCE(description, '') AS description,
+		COALESCE(brand_owner, '') AS brand_owner, provider, COALESCE(barcode, '') AS barcode
+	FROM food WHERE gtin = $1 AND fdc_id IS NOT NULL
+	ORDER BY id LIMIT 1;`
+
+	var food Food
+	if err := db.GetContext(ctx, &food, q, gtin); err != nil {
+		return nil, err
+	}
+	return &food, nil
+}
+
+// gtin returns the GTIN-14 form of the barcode which is saved for lookups, or
+// NULL when the barcode is not valid.
+func gtin(barcode string) sql.NullString {
+	g, err := provider.GTIN(barcode)
+	if err != nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: g, Valid: true}
+}
@@ -70,7 +70,7 @@ func TestFoodDataStorage(t *testing.T) {
 
 			// Search for Food item in storage and check that everything is OK
 			{
-				foods, err := storage.List(ctx, db, food.Description)
+				foods, err := storage.List(ctx, db, food.Description, storage.DefaultRankPolicy)
 				if err != nil {
 					t.Fatalf("\t%s\tShould be able search food in storage: %s", tests.Failed, err)
 				}
@@ -80,6 +80,60 @@ func TestFoodDataStorage(t *testing.T) {
 				t.Logf("\t%s\tShould be able search food in storage.", tests.Success)
 			}
 
+			// Add Food Item of other provider, which has no fdc id, to storage.
+			{
+				offFood := storage.Food{
+					Description: "bounty milk",
+					BrandOwner:  "mars",
+					Provider:    "off",
+					Barcode:     "5000159461122",
+				}
+				for i := 0; i < 2; i++ {
+					if err := storage.SaveSearchInput(ctx, db, offFood, "bounty"); err != nil {
+						t.Fatalf("\t%s\tShould be able to add food of other provider to storage: %s", tests.Failed, err)
+					}
+				}
+
+				foods, err := storage.List(ctx, db, "bounty", storage.DefaultRankPolicy)
+				if err != nil {
+					t.Fatalf("\t%s\tShould be able search food in storage: %s", tests.Failed, err)
+				}
+				if len(foods) != 2 || foods[1].Provider != "off" || foods[1].Barcode != offFood.Barcode {
+					t.Fatalf("\t%s\tShould get foods with their providers once: %+v", tests.Failed, foods)
+				}
+				t.Logf("\t%s\tShould be able to add food of other provider to storage.", tests.Success)
+			}
+
+			// Search for the generic food among more branded foods than the
+			// list holds.
+			{
+				for i := 0; i < 250; i++ {
+					branded := storage.Food{
+						FDCID:         500000 + i,
+						Description:   "apple juice",
+						BrandOwner:    "juicery",
+						DataType:      "Branded",
+						PublishedDate: "2020-01-01",
+					}
+					if err := storage.SaveSearchInput(ctx, db, branded, "apple juice"); err != nil {
+						t.Fatalf("	%s	Should be able to add branded food to storage: %s", tests.Failed, err)
+					}
+				}
+				generic := storage.Food{FDCID: 171688, Description: "apple, raw", DataType: "SR Legacy"}
+				if err := storage.SaveSearchInput(ctx, db, generic, "apple"); err != nil {
+					t.Fatalf("	%s	Should be able to add generic food to storage: %s", tests.Failed, err)
+				}
+
+				foods, err := storage.List(ctx, db, "apple", storage.DefaultRankPolicy)
+				if err != nil {
+					t.Fatalf("	%s	Should be able search food in storage: %s", tests.Failed, err)
+				}
+				if len(foods) != 200 || foods[0].FDCID != generic.FDCID {
+					t.Fatalf("	%s	Should rank foods before the list is cut, got %d foods, first %+v", tests.Failed, len(foods), foods[0])
+				}
+				t.Logf("	%s	Should rank foods before the list is cut.", tests.Success)
+			}
+
 			// Add Food details to storage and check that everything is OK
 			{
 				cs := storage.Carbohydrates{
@@ -96,6 +150,30 @@ func TestFoodDataStorage(t *testing.T) {
 				t.Logf("\t%s\tShould be able to add food details to storage.", tests.Success)
 			}
 
+			// Add Food nutrients to storage and get them back
+			{
+				for i := 0; i < 2; i++ {
+					if err := storage.SaveNutrients(ctx, db, food.FDCID, fns); err != nil {
+						t.Fatalf("\t%s\tShould be able to add food nutrients to storage: %s", tests.Failed, err)
+					}
+				}
+
+				d, err := storage.RetrieveNutrients(ctx, db, food.FDCID)
+				if err != nil {
+					t.Fatalf("\t%s\tShould be able to get food nutrients from storage: %s", tests.Failed, err)
+				}
+				if d.Description != food.Description || len(d.Nutrients) != len(fns) {
+					t.Fatalf("\t%s\tShould get every food nutrient once: %+v", tests.Failed, d)
+				}
+				for i := range fns {
+					got := d.Nutrients[i]
+					if got.Name != fns[i].Name || got.Number != fns[i].Number || got.Amount != fns[i].Amount || got.Type != fns[i].Type {
+						t.Fatalf("\t%s\tShould get the same food nutrients: %+v", tests.Failed, got)
+					}
+				}
+				t.Logf("\t%s\tShould be able to get food nutrients from storage.", tests.Success)
+			}
+
 			// Get Food details from storage, compare then and check that everything is correct
 			{
 				foodDetails, err := storage.RetrieveDetails(ctx, db, 1234)
@@ -121,6 +199,40 @@ func TestFoodDataStorage(t *testing.T) {
 				t.Logf("%s\tShould be able to get the same food details from storage.", tests.Success)
 
 			}
+
+			// Get details of multiple foods from storage at once.
+			{
+				details, err := storage.RetrieveDetailsBatch(ctx, db, []int{food.FDCID, 4321})
+				if err != nil {
+					t.Fatalf("\t%s\tShould be able to get details batch from storage: %s", tests.Failed, err)
+				}
+				if _, ok := details[food.FDCID]; !ok || len(details) != 1 {
+					t.Fatalf("\t%s\tShould get details of stored foods only: %+v", tests.Failed, details)
+				}
+				t.Logf("\t%s\tShould be able to get details batch from storage.", tests.Success)
+			}
+
+			// Find recipes the food is an ingredient of.
+			{
+				recipe := storage.Recipe{
+					Name:        "bounty pie",
+					YieldGrams:  200,
+					Servings:    2,
+					Ingredients: []storage.RecipeIngredient{{FDCID: food.FDCID, Grams: 100}},
+				}
+				id, err := storage.CreateRecipe(ctx, db, recipe)
+				if err != nil {
+					t.Fatalf("\t%s\tShould be able to create recipe: %s", tests.Failed, err)
+				}
+				ids, err := storage.IngredientRecipes(ctx, db, food.FDCID)
+				if err != nil {
+					t.Fatalf("\t%s\tShould be able to get recipes of the ingredient: %s", tests.Failed, err)
+				}
+				if len(ids) != 1 || ids[0] != id {
+					t.Fatalf("\t%s\tShould get the recipe %d the food is an ingredient of: %v", tests.Failed, id, ids)
+				}
+				t.Logf("\t%s\tShould be able to get recipes of the ingredient.", tests.Success)
+			}
 		}
 	}
 }
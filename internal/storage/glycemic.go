@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// SaveGlycemicIndexes saves glycemic indexes of the table, replacing the ones
+// the same source gave for the same food or category before.
+func SaveGlycemicIndexes(ctx context.Context, db *sqlx.DB, indexes []GlycemicIndex) error {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.SaveGlycemicIndexes")
+	defer span.End()
+
+	const addIndex = `INSERT INTO glycemic_index (fdc_id, food_category, description, gi, source)
+	VALUES (NULLIF($1, 0), NULLIF($2, ''), NULLIF($3, ''), $4, $5)
+	ON CONFLICT (source, COALESCE(fdc_id, 0), COALESCE(food_category, ''))
+	DO UPDATE SET description = EXCLUDED.description, gi = EXCLUDED.gi;`
+
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "creating transaction")
+	}
+
+	for _, gi := range indexes {
+		if _, err := tx.Exec(addIndex, gi.FDCID, gi.FoodCategory, gi.Description, gi.GI, gi.Source); err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "inserting glycemic index")
+		}
+	}
+
+	return errors.Wrap(tx.Commit(), "commit transaction")
+}
+
+// RetrieveGlycemicIndexes returns glycemic indexes of the foods with given
+// fdcIDs, by fdcID. Glycemic index measured for the food is preferred, the
+// one of its category is returned otherwise. The category is given by
+// categories, by fdcID, or taken from the stored food when not given. Foods
+// without glycemic index are left out.
+func RetrieveGlycemicIndexes(ctx context.Context, db *sqlx.DB, categories map[int]string) (map[int]GlycemicIndex, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.RetrieveGlycemicIndexes")
+	defer span.End()
+
+	const selectIndexes = `
+	SELECT DISTINCT ON (f.fdc_id) f.fdc_id, g.id, COALESCE(g.food_category, '') AS food_category,
+		COALESCE(g.description, '') AS description, g.gi, g.source,
+		CASE WHEN g.fdc_id IS NULL THEN 'category' ELSE 'exact' END AS match
+	FROM unnest($1::INT[], $2::VARCHAR[]) AS f(fdc_id, food_category)
+	LEFT JOIN food ON food.fdc_id = f.fdc_id
+	INNER JOIN glycemic_index AS g ON g.fdc_id = f.fdc_id
+		OR (g.fdc_id IS NULL AND g.food_category = COALESCE(NULLIF(f.food_category, ''), food.food_category))
+	ORDER BY f.fdc_id, g.fdc_id NULLS LAST, g.source, g.id;`
+
+	if len(categories) == 0 {
+		return map[int]GlycemicIndex{}, nil
+	}
+
+	ids := make([]int64, 0, len(categories))
+	names := make([]string, 0, len(categories))
+	for id, name := range categories {
+		ids = append(ids, int64(id))
+		names = append(names, name)
+	}
+
+	var rows []GlycemicIndex
+	if err := db.SelectContext(ctx, &rows, selectIndexes, pq.Array(ids), pq.Array(names)); err != nil {
+		return nil, errors.Wrap(err, "selecting glycemic indexes")
+	}
+
+	indexes := make(map[int]GlycemicIndex, len(rows))
+	for _, r := range rows {
+		indexes[r.FDCID] = r
+	}
+	return indexes, nil
+}
@@ -1,24 +1,52 @@
 package storage
 
+import (
+	"database/sql"
+	"time"
+)
+
 // Food represents a information of Food from the search request.
 type Food struct {
 	ID          int    `db:"id"`
 	FDCID       int    `db:"fdc_id"`
 	Description string `db:"description"`
 	BrandOwner  string `db:"brand_owner"`
+
+	// Provider is the name of the provider which supplied the food, foods of
+	// providers other than Food Data Central have no FDCID and are told apart
+	// by Barcode.
+	Provider string `db:"provider"`
+	Barcode  string `db:"barcode"`
+
+	// DataType is the data type of Food Data Central the food belongs to,
+	// PublishedDate is in YYYY-MM-DD form. Both are empty when not known.
+	DataType      string `db:"data_type"`
+	PublishedDate string `db:"published_date"`
+	FoodCategory  string `db:"food_category"`
 }
 
 // Details represents the food details with it's nutritions.
 type Details struct {
-	Description string
+	Description string `db:"description"`
 	Nutrients   []FoodNutrient
 }
 
 // Details represents the food details with it's carbohydrate amount
 type DetailsRef struct {
 	Description string `db:"description"`
+
+	// Provider is the name of the provider which supplied the food, or
+	// RecipeProvider for recipes.
+	Provider string `db:"provider"`
 	Carbohydrates
 	Portions []Portion
+
+	// Serving is the labelled serving of branded food, nil for other foods.
+	Serving *Serving
+
+	// Nutrients is the complete nutrient profile of the food per 100 grams,
+	// empty for foods saved before the profile was stored.
+	Nutrients []FoodNutrient
 }
 
 // Carbohydrates in specified food with provided fdcID
@@ -36,14 +64,28 @@ type Portion struct {
 	Description string  `db:"description"`
 }
 
+// Serving is the serving stated on the label of branded food with the amount
+// of carbohydrates in it.
+type Serving struct {
+	FDCID             int             `db:"fdc_id"`
+	Size              float64         `db:"size"`
+	Unit              string          `db:"unit"`
+	Description       string          `db:"description"`
+	Carbohydrates     sql.NullFloat64 `db:"carbohydrates"`
+	CarbohydratesUnit string          `db:"carbohydrates_unit"`
+}
+
 // FoodNutrient represents nutrients with amunt and type.
 type FoodNutrient struct {
+	FDCID  int     `db:"fdc_id"`
 	Type   string  `db:"type"`
 	Amount float64 `db:"amount"`
 	Nutrient
 }
 
-// Nutrient is the nutrient such as Fat, Ferum and etc.
+// Nutrient is the nutrient such as Fat, Ferum and etc. Nutrients are told
+// apart by name and unit, Number is the nutrient number of USDA, or 0 when
+// the nutrient has none.
 type Nutrient struct {
 	ID       int    `db:"id"`
 	Name     string `db:"name"`
@@ -51,3 +93,37 @@ type Nutrient struct {
 	Number   int    `db:"number"`
 	UnitName string `db:"unit_name"`
 }
+
+// GlycemicIndex is glycemic index of the food with FDCID, or of every food of
+// FoodCategory when FDCID is zero, as Source table gives it.
+type GlycemicIndex struct {
+	ID           int     `db:"id"`
+	FDCID        int     `db:"fdc_id"`
+	FoodCategory string  `db:"food_category"`
+	Description  string  `db:"description"`
+	GI           float64 `db:"gi"`
+	Source       string  `db:"source"`
+
+	// Match tells whether glycemic index was found for the food itself or
+	// for its category, it is set by retrieval only.
+	Match string `db:"match"`
+}
+
+// Recipe is the dish made of foods of storage. Recipes are stored as foods
+// too, with FDCID from RecipeIDBase up, so they are looked up like any food.
+type Recipe struct {
+	FDCID       int       `db:"fdc_id"`
+	Name        string    `db:"name"`
+	YieldGrams  float64   `db:"yield_grams"`
+	Servings    float64   `db:"servings"`
+	DateCreated time.Time `db:"date_created"`
+	DateUpdated time.Time `db:"date_updated"`
+	Ingredients []RecipeIngredient
+}
+
+// RecipeIngredient is grams of the food in the recipe.
+type RecipeIngredient struct {
+	FDCID       int     `db:"fdc_id"`
+	Grams       float64 `db:"grams"`
+	Description string  `db:"description"`
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// selectNutrients selects nutrients of the foods, most important first.
+const selectNutrients = `
+	SELECT fn.fdc_id, COALESCE(fn.type, '') AS type, fn.amount,
+		n.id, n.name, COALESCE(n.rank, 0) AS rank, COALESCE(n.number, 0) AS number, n.unit_name
+	FROM food_nutrients AS fn
+	INNER JOIN nutrients AS n ON n.id = fn.nutrient_id
+	WHERE fn.fdc_id = ANY($1)
+	ORDER BY fn.fdc_id, n.rank NULLS LAST, n.id;`
+
+// SaveNutrients saves the nutrient profile of the food. Nutrients are saved
+// once and shared by foods, amounts already in storage are left as is.
+func SaveNutrients(ctx context.Context, db *sqlx.DB, fdcID int, nutrients []FoodNutrient) error {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.SaveNutrients")
+	defer span.End()
+
+	const (
+		addNutrient = `INSERT INTO nutrients (number, name, rank, unit_name)
+		VALUES (NULLIF($1, 0), $2, NULLIF($3, 0), $4)
+		ON CONFLICT (name, unit_name) DO UPDATE SET number = COALESCE(nutrients.number, EXCLUDED.number),
+		rank = COALESCE(nutrients.rank, EXCLUDED.rank)
+		RETURNING id`
+
+		addFoodNutrient = `INSERT INTO food_nutrients (fdc_id, nutrient_id, type, amount)
+		VALUES ($1, $2, NULLIF($3, ''), $4) ON CONFLICT DO NOTHING;`
+	)
+
+	if len(nutrients) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "creating transaction")
+	}
+
+	for i := range nutrients {
+		n := &nutrients[i]
+
+		var id int
+		err := tx.QueryRow(addNutrient, n.Number, n.Name, n.Rank, n.UnitName).Scan(&id)
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "inserting nutrient")
+		}
+
+		if _, err := tx.Exec(addFoodNutrient, fdcID, id, n.Type, n.Amount); err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "inserting food nutrient")
+		}
+	}
+
+	return errors.Wrap(tx.Commit(), "commit transaction")
+}
+
+// RetrieveNutrients returns the food with given fdcID with its nutrient
+// profile. The profile is empty when it was not saved for the food.
+func RetrieveNutrients(ctx context.Context, db *sqlx.DB, fdcID int) (*Details, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.RetrieveNutrients")
+	defer span.End()
+
+	const selectFood = `SELECT COALESCE(description, '') AS description FROM food WHERE fdc_id = $1;`
+
+	var details Details
+	if err := db.GetContext(ctx, &details, selectFood, fdcID); err != nil {
+		return nil, err
+	}
+
+	nutrients, err := retrieveNutrientsBatch(ctx, db, []int{fdcID})
+	if err != nil {
+		return nil, err
+	}
+	details.Nutrients = nutrients[fdcID]
+
+	return &details, nil
+}
+
+// retrieveNutrientsBatch returns nutrient profiles of the foods with given
+// fdcIDs in one round trip to database, by fdcID.
+func retrieveNutrientsBatch(ctx context.Context, db *sqlx.DB, fdcIDs []int) (map[int][]FoodNutrient, error) {
+	ids := make([]int64, len(fdcIDs))
+	for i := range fdcIDs {
+		ids[i] = int64(fdcIDs[i])
+	}
+
+	var rows []FoodNutrient
+	if err := db.SelectContext(ctx, &rows, selectNutrients, pq.Array(ids)); err != nil {
+		return nil, errors.Wrap(err, "selecting nutrients")
+	}
+
+	nutrients := make(map[int][]FoodNutrient, len(fdcIDs))
+	for _, r := range rows {
+		nutrients[r.FDCID] = append(nutrients[r.FDCID], r)
+	}
+	return nutrients, nil
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// RankPolicy orders foods found in storage by their data type. Generic lists
+// data types most preferred first for generic queries like "apple", Branded
+// does the same for queries naming the brand like "mars". Data types not
+// listed go after the listed ones.
+type RankPolicy struct {
+	Generic []string
+	Branded []string
+}
+
+// DefaultRankPolicy prefers reference foods of USDA for generic queries, so
+// "apple, raw" goes before apple flavoured branded products, and branded
+// foods for queries naming the brand.
+var DefaultRankPolicy = RankPolicy{
+	Generic: []string{"Foundation", "SR Legacy", "Survey (FNDDS)", "Branded"},
+	Branded: []string{"Branded", "Foundation", "SR Legacy", "Survey (FNDDS)"},
+}
+
+// Rank sorts foods found by the search input according to the policy. When the
+// search input names the brand, foods of that brand go first. Foods of the same
+// data type are sorted by published date, the latest first, and then by id.
+func (p RankPolicy) Rank(foods []Food, searchInput string) {
+	words := rankWords(searchInput)
+	brand := brandQuery(foods, words)
+
+	order := p.Generic
+	if brand != "" {
+		order = p.Branded
+	}
+	priority := make(map[string]int, len(order))
+	for i, dt := range order {
+		if _, ok := priority[dt]; !ok {
+			priority[dt] = i
+		}
+	}
+	rank := func(f *Food) int {
+		if p, ok := priority[f.DataType]; ok {
+			return p
+		}
+		return len(order)
+	}
+
+	sort.SliceStable(foods, func(i, j int) bool {
+		a, b := &foods[i], &foods[j]
+		if brand != "" {
+			ab, bb := hasWord(rankWords(a.BrandOwner), brand), hasWord(rankWords(b.BrandOwner), brand)
+			if ab != bb {
+				return ab
+			}
+		}
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra < rb
+		}
+		if a.PublishedDate != b.PublishedDate {
+			return a.PublishedDate > b.PublishedDate
+		}
+		return a.ID < b.ID
+	})
+}
+
+// brandQuery returns the word of the search input which names the brand of
+// some of the foods, or empty string when the search input is generic. The
+// word does not name the brand when foods which are not branded are described
+// by it, e.g. "apple" is the food even though there are brands named so.
+func brandQuery(foods []Food, words []string) string {
+	for _, w := range words {
+		var branded, generic bool
+		for i := range foods {
+			if foods[i].DataType != "" && foods[i].DataType != "Branded" &&
+				strings.Contains(strings.ToLower(foods[i].Description), w) {
+				generic = true
+				break
+			}
+			if hasWord(rankWords(foods[i].BrandOwner), w) {
+				branded = true
+			}
+		}
+		if branded && !generic {
+			return w
+		}
+	}
+	return ""
+}
+
+// rankWords splits the text into lowercase words of letters and digits.
+func rankWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func hasWord(words []string, word string) bool {
+	for _, w := range words {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/igomonov88/sugar/internal/storage"
+	"github.com/igomonov88/sugar/internal/tests"
+)
+
+func TestRank(t *testing.T) {
+	t.Log("Given the need to rank foods found in storage.")
+	{
+		foods := func() []storage.Food {
+			return []storage.Food{
+				{ID: 1, Description: "Apple juice", BrandOwner: "Apple & Eve, LLC", DataType: "Branded", PublishedDate: "2020-11-13"},
+				{ID: 2, Description: "Apple pie", BrandOwner: "Mars Inc.", DataType: "Branded", PublishedDate: "2019-04-01"},
+				{ID: 3, Description: "Apples, raw, with skin", DataType: "SR Legacy", PublishedDate: "2019-04-01"},
+				{ID: 4, Description: "Apples, fuji, with skin, raw", DataType: "Foundation", PublishedDate: "2020-04-01"},
+				{ID: 5, Description: "Apple, NFS", DataType: "Survey (FNDDS)", PublishedDate: "2020-10-30"},
+			}
+		}
+		ids := func(foods []storage.Food) []int {
+			ids := make([]int, len(foods))
+			for i := range foods {
+				ids[i] = foods[i].ID
+			}
+			return ids
+		}
+
+		t.Log("\tWhen the search input is generic.")
+		{
+			f := foods()
+			storage.DefaultRankPolicy.Rank(f, "apple")
+			if got := ids(f); !cmp.Equal(got, []int{4, 3, 5, 1, 2}) {
+				t.Fatalf("\t%s\tShould prefer Foundation and SR Legacy foods, got %v.", tests.Failed, got)
+			}
+			t.Logf("\t%s\tShould prefer Foundation and SR Legacy foods.", tests.Success)
+		}
+
+		t.Log("\tWhen the search input names the brand.")
+		{
+			f := foods()
+			storage.DefaultRankPolicy.Rank(f, "Mars")
+			if got := ids(f); !cmp.Equal(got, []int{2, 1, 4, 3, 5}) {
+				t.Fatalf("\t%s\tShould prefer foods of the brand, got %v.", tests.Failed, got)
+			}
+			t.Logf("\t%s\tShould prefer foods of the brand.", tests.Success)
+		}
+
+		t.Log("\tWhen the policy is configured.")
+		{
+			f := foods()
+			policy := storage.RankPolicy{Generic: []string{"Branded"}}
+			policy.Rank(f, "apple")
+			if got := ids(f); !cmp.Equal(got, []int{1, 2, 5, 4, 3}) {
+				t.Fatalf("\t%s\tShould follow the policy and latest published date, got %v.", tests.Failed, got)
+			}
+			t.Logf("\t%s\tShould follow the policy and latest published date.", tests.Success)
+		}
+	}
+}
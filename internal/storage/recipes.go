@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// RecipeProvider is the provider name foods of recipes are stored with.
+const RecipeProvider = "recipe"
+
+// RecipeIDBase is the first FDCID given to recipes. Foods of Food Data Central
+// have ids far below it, so recipes and foods share one id space.
+const RecipeIDBase = 1000000000
+
+// CreateRecipe saves the recipe along with the food it is stored as, and
+// computes its nutrition. FDCID of the recipe is returned.
+func CreateRecipe(ctx context.Context, db *sqlx.DB, r Recipe) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.CreateRecipe")
+	defer span.End()
+
+	const (
+		nextID  = `SELECT nextval('recipe_fdc_id_seq');`
+		addFood = `INSERT INTO food (fdc_id, description, provider, data_type)
+		VALUES ($1, $2, $3, 'Recipe');`
+		addRecipe = `INSERT INTO recipes (fdc_id, name, yield_grams, servings)
+		VALUES ($1, $2, $3, $4);`
+	)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, errors.Wrap(err, "begin transaction")
+	}
+
+	var id int
+	if err := tx.QueryRow(nextID).Scan(&id); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrap(err, "getting recipe id")
+	}
+	if _, err := tx.Exec(addFood, id, r.Name, RecipeProvider); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrap(err, "inserting recipe food")
+	}
+	if _, err := tx.Exec(addRecipe, id, r.Name, r.YieldGrams, r.Servings); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrap(err, "inserting recipe")
+	}
+	if err := saveIngredients(tx, id, r.Ingredients); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := computeRecipes(tx, []int{id}); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return id, errors.Wrap(tx.Commit(), "commit transaction")
+}
+
+// UpdateRecipe replaces the recipe with given FDCID and recomputes its
+// nutrition. sql.ErrNoRows is returned when there is no such recipe.
+func UpdateRecipe(ctx context.Context, db *sqlx.DB, r Recipe) error {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.UpdateRecipe")
+	defer span.End()
+
+	const (
+		updateRecipe = `UPDATE recipes SET name = $2, yield_grams = $3, servings = $4,
+		date_updated = now() WHERE fdc_id = $1;`
+		updateFood        = `UPDATE food SET description = $2 WHERE fdc_id = $1;`
+		deleteIngredients = `DELETE FROM recipe_ingredients WHERE recipe_id = $1;`
+	)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+
+	res, err := tx.Exec(updateRecipe, r.FDCID, r.Name, r.YieldGrams, r.Servings)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "updating recipe")
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		tx.Rollback()
+		if err != nil {
+			return errors.Wrap(err, "updating recipe")
+		}
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(updateFood, r.FDCID, r.Name); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "updating recipe food")
+	}
+	if _, err := tx.Exec(deleteIngredients, r.FDCID); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "deleting ingredients")
+	}
+	if err := saveIngredients(tx, r.FDCID, r.Ingredients); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := computeRecipes(tx, []int{r.FDCID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return errors.Wrap(tx.Commit(), "commit transaction")
+}
+
+// DeleteRecipe deletes the recipe with given FDCID along with the food it is
+// stored as. sql.ErrNoRows is returned when there is no such recipe.
+func DeleteRecipe(ctx context.Context, db *sqlx.DB, fdcID int) error {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.DeleteRecipe")
+	defer span.End()
+
+	const deleteRecipe = `DELETE FROM recipes WHERE fdc_id = $1;`
+	deleteFood := []string{
+		`DELETE FROM carbohydrates WHERE fdc_id = $1;`,
+		`DELETE FROM portions WHERE fdc_id = $1;`,
+		`DELETE FROM food_nutrients WHERE fdc_id = $1;`,
+		`DELETE FROM food WHERE fdc_id = $1;`,
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+
+	res, err := tx.Exec(deleteRecipe, fdcID)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "deleting recipe")
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		tx.Rollback()
+		if err != nil {
+			return errors.Wrap(err, "deleting recipe")
+		}
+		return sql.ErrNoRows
+	}
+	for _, q := range deleteFood {
+		if _, err := tx.Exec(q, fdcID); err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "deleting recipe food")
+		}
+	}
+
+	return errors.Wrap(tx.Commit(), "commit transaction")
+}
+
+// RetrieveRecipe returns the recipe with given FDCID with its ingredients.
+func RetrieveRecipe(ctx context.Context, db *sqlx.DB, fdcID int) (*Recipe, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.RetrieveRecipe")
+	defer span.End()
+
+	const (
+		selectRecipe = `
+		SELECT fdc_id, name, yield_grams, servings, date_created, date_updated
+		FROM recipes WHERE fdc_id = $1;`
+		selectIngredients = `
+		SELECT i.fdc_id, i.grams, COALESCE(f.description, '') AS description
+		FROM recipe_ingredients AS i
+		INNER JOIN food AS f ON f.fdc_id = i.fdc_id
+		WHERE i.recipe_id = $1
+		ORDER BY i.position;`
+	)
+
+	var r Recipe
+	if err := db.GetContext(ctx, &r, selectRecipe, fdcID); err != nil {
+		return nil, err
+	}
+	if err := db.SelectContext(ctx, &r.Ingredients, selectIngredients, fdcID); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListRecipes returns recipes which names contain the search input, every
+// recipe when it is empty. Ingredients of the recipes are not returned.
+func ListRecipes(ctx context.Context, db *sqlx.DB, searchInput string) ([]Recipe, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.ListRecipes")
+	defer span.End()
+
+	const q = `
+	SELECT fdc_id, name, yield_grams, servings, date_created, date_updated
+	FROM recipes WHERE name ILIKE '%' || $1 || '%'
+	ORDER BY name, fdc_id;`
+
+	var recipes []Recipe
+	if err := db.SelectContext(ctx, &recipes, q, searchInput); err != nil {
+		return nil, err
+	}
+	return recipes, nil
+}
+
+// ComputeRecipes recomputes nutrition of the recipes with given FDCIDs from
+// the details of their ingredients in storage. It is meant to be run after
+// details of the foods change.
+func ComputeRecipes(ctx context.Context, db *sqlx.DB, fdcIDs []int) error {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.ComputeRecipes")
+	defer span.End()
+
+	if len(fdcIDs) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	if err := computeRecipes(tx, fdcIDs); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return errors.Wrap(tx.Commit(), "commit transaction")
+}
+
+// IngredientRecipes returns FDCIDs of the recipes the food with given FDCID is
+// an ingredient of.
+func IngredientRecipes(ctx context.Context, db *sqlx.DB, fdcID int) ([]int, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.storage.IngredientRecipes")
+	defer span.End()
+
+	const q = `SELECT DISTINCT recipe_id FROM recipe_ingredients WHERE fdc_id = $1 ORDER BY recipe_id;`
+
+	var ids []int
+	if err := db.SelectContext(ctx, &ids, q, fdcID); err != nil {
+		return nil, errors.Wrap(err, "selecting recipes of the ingredient")
+	}
+	return ids, nil
+}
+
+// saveIngredients saves ingredients of the recipe in their order.
+func saveIngredients(tx *sql.Tx, recipeID int, ingredients []RecipeIngredient) error {
+	const addIngredient = `INSERT INTO recipe_ingredients (recipe_id, position, fdc_id, grams)
+	VALUES ($1, $2, $3, $4);`
+
+	for i, in := range ingredients {
+		if _, err := tx.Exec(addIngredient, recipeID, i, in.FDCID, in.Grams); err != nil {
+			return errors.Wrap(err, "inserting ingredient")
+		}
+	}
+	return nil
+}
+
+// computeRecipes stores carbohydrates and nutrients of the recipes per 100
+// grams of the cooked dish, and portions of one serving and the whole dish.
+// Amounts are the sum of the ingredients divided by the yield, so ingredients
+// which do not state a nutrient count as having none of it. Ingredients state
+// total carbohydrates by different nutrients, so total carbohydrates of the
+// recipe are the ones to take rather than any of those nutrients.
+func computeRecipes(tx *sql.Tx, fdcIDs []int) error {
+	const (
+		computeCarbohydrates = `
+		INSERT INTO carbohydrates (fdc_id, amount, unit_name)
+		SELECT r.fdc_id, COALESCE(SUM(c.amount * i.grams), 0) / r.yield_grams, 'g'
+		FROM recipes AS r
+		INNER JOIN recipe_ingredients AS i ON i.recipe_id = r.fdc_id
+		LEFT JOIN carbohydrates AS c ON c.fdc_id = i.fdc_id
+		WHERE r.fdc_id = ANY($1)
+		GROUP BY r.fdc_id, r.yield_grams
+		ON CONFLICT (fdc_id) DO UPDATE SET amount = EXCLUDED.amount, unit_name = EXCLUDED.unit_name;`
+
+		deleteNutrients  = `DELETE FROM food_nutrients WHERE fdc_id = ANY($1);`
+		computeNutrients = `
+		INSERT INTO food_nutrients (fdc_id, nutrient_id, amount)
+		SELECT r.fdc_id, fn.nutrient_id, SUM(fn.amount * i.grams) / r.yield_grams
+		FROM recipes AS r
+		INNER JOIN recipe_ingredients AS i ON i.recipe_id = r.fdc_id
+		INNER JOIN food_nutrients AS fn ON fn.fdc_id = i.fdc_id
+		WHERE r.fdc_id = ANY($1)
+		GROUP BY r.fdc_id, r.yield_grams, fn.nutrient_id;`
+
+		deletePortions = `DELETE FROM portions WHERE fdc_id = ANY($1);`
+		addPortions    = `
+		INSERT INTO portions (fdc_id, gram_weight, description)
+		SELECT fdc_id, yield_grams / servings, '1 serving' FROM recipes WHERE fdc_id = ANY($1)
+		UNION ALL
+		SELECT fdc_id, yield_grams, 'whole recipe' FROM recipes WHERE fdc_id = ANY($1) AND servings <> 1;`
+	)
+
+	ids := make([]int64, len(fdcIDs))
+	for i := range fdcIDs {
+		ids[i] = int64(fdcIDs[i])
+	}
+
+	steps := []struct {
+		q    string
+		name string
+	}{
+		{computeCarbohydrates, "computing carbohydrates"},
+		{deleteNutrients, "deleting nutrients"},
+		{computeNutrients, "computing nutrients"},
+		{deletePortions, "deleting portions"},
+		{addPortions, "inserting portions"},
+	}
+	for _, s := range steps {
+		if _, err := tx.Exec(s.q, pq.Array(ids)); err != nil {
+			return errors.Wrap(err, s.name)
+		}
+	}
+	return nil
+}